@@ -0,0 +1,253 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+/*
+ApprovalVerifier definition
+
+ApprovalVerifier checks that a BBcTransaction is signed by the approvers whom the consumed BBcEvent objects demand.
+For each BBcReference object, the BBcEvent object pointed by "TransactionID" and "EventIndexInRef" is resolved from the given past transactions,
+and then the signatures at the positions listed in "SigIndices" are checked against "MandatoryApprovers" and "OptionApprovers" of the BBcEvent.
+All of the MandatoryApprovers and at least "OptionApproverNumNumerator" of the OptionApprovers must have a valid signature.
+
+"PubkeyResolver" must be supplied by the caller, because a userID is not derived from the public key in BBc-1.
+PubkeyResolver returns the public keys which belong to the userID (e.g., from a user directory).
+The owner (userID) of a signature is determined by "SigIndices" of the BBcTransaction object if it is available (i.e., the transaction is built in this process).
+Otherwise (e.g., a deserialized transaction), the owner is the approver whose public keys returned by PubkeyResolver include the public key in the signature.
+In both cases, a signature is valid only if it is verified and its public key belongs to the owner.
+*/
+type (
+	ApprovalVerifier struct {
+		PubkeyResolver PubkeyResolver
+	}
+
+	// ApprovalReport is the result of ApprovalVerifier.Verify for a BBcTransaction object
+	ApprovalReport struct {
+		TransactionID []byte
+		References    []*ReferenceApproval
+	}
+
+	// ReferenceApproval is the result of the approval check for a BBcReference object
+	ReferenceApproval struct {
+		ReferenceIndex      int
+		TransactionID       []byte
+		EventIndexInRef     uint16
+		Resolved            bool
+		MissingMandatory    [][]byte
+		InvalidMandatory    [][]byte
+		MissingOption       [][]byte
+		InvalidOption       [][]byte
+		ValidOptionNum      int
+		RequiredOptionNum   int
+		UnmatchedSigIndices []int
+	}
+
+	// PubkeyResolver returns the public keys of the userID
+	PubkeyResolver func(userID []byte) [][]byte
+)
+
+// NewApprovalVerifier returns an ApprovalVerifier object with the resolver
+func NewApprovalVerifier(resolver PubkeyResolver) *ApprovalVerifier {
+	return &ApprovalVerifier{PubkeyResolver: resolver}
+}
+
+// VerifyApprovals checks the approvals of the transaction with an ApprovalVerifier with the resolver
+func VerifyApprovals(transaction *BBcTransaction, refTransactions []*BBcTransaction, resolver PubkeyResolver) (*ApprovalReport, error) {
+	return NewApprovalVerifier(resolver).Verify(transaction, refTransactions)
+}
+
+// matchPubkey returns true if the public keys are the same (the difference of the compression mode is ignored)
+func matchPubkey(keyType uint32, pubkey1, pubkey2 []byte) bool {
+	return bytes.Equal(pubkey1, pubkey2) || samePublicKey(int(keyType), pubkey1, pubkey2)
+}
+
+// owns returns true if the public key in the signature is one of the keys of the userID
+func (r PubkeyResolver) owns(userID []byte, sig *BBcSignature) bool {
+	for _, pubkey := range r(userID) {
+		if matchPubkey(sig.KeyType, pubkey, sig.Pubkey) {
+			return true
+		}
+	}
+	return false
+}
+
+// Approved returns true if all the BBcReference objects are resolved and approved
+func (r *ApprovalReport) Approved() bool {
+	for _, ref := range r.References {
+		if !ref.Approved() {
+			return false
+		}
+	}
+	return true
+}
+
+// Stringer outputs the content of the object
+func (r *ApprovalReport) Stringer() string {
+	ret := fmt.Sprintf("transaction_id: %x\n", r.TransactionID)
+	ret += fmt.Sprintf("approved: %v\n", r.Approved())
+	for _, ref := range r.References {
+		ret += ref.Stringer()
+	}
+	return ret
+}
+
+// Approved returns true if the BBcReference object is resolved and all the required approvers gave valid signatures
+func (r *ReferenceApproval) Approved() bool {
+	return r.Resolved && len(r.MissingMandatory) == 0 && len(r.InvalidMandatory) == 0 && r.ValidOptionNum >= r.RequiredOptionNum
+}
+
+// Stringer outputs the content of the object
+func (r *ReferenceApproval) Stringer() string {
+	ret := fmt.Sprintf("reference[%d]:\n", r.ReferenceIndex)
+	ret += fmt.Sprintf("  transaction_id: %x\n", r.TransactionID)
+	ret += fmt.Sprintf("  event_index_in_ref: %d\n", r.EventIndexInRef)
+	if !r.Resolved {
+		ret += "  not resolved\n"
+		return ret
+	}
+	for _, u := range r.MissingMandatory {
+		ret += fmt.Sprintf("  missing mandatory approver: %x\n", u)
+	}
+	for _, u := range r.InvalidMandatory {
+		ret += fmt.Sprintf("  invalid signature of mandatory approver: %x\n", u)
+	}
+	for _, u := range r.MissingOption {
+		ret += fmt.Sprintf("  missing option approver: %x\n", u)
+	}
+	for _, u := range r.InvalidOption {
+		ret += fmt.Sprintf("  invalid signature of option approver: %x\n", u)
+	}
+	ret += fmt.Sprintf("  option approvers: %d/%d\n", r.ValidOptionNum, r.RequiredOptionNum)
+	if len(r.UnmatchedSigIndices) > 0 {
+		ret += fmt.Sprintf("  unmatched sig_indices: %v\n", r.UnmatchedSigIndices)
+	}
+	return ret
+}
+
+// resolveEvent finds the BBcEvent object which the BBcReference object points to
+func resolveEvent(ref *BBcReference, refTransactions []*BBcTransaction) *BBcEvent {
+	candidates := refTransactions
+	if ref.RefTransaction != nil {
+		candidates = append([]*BBcTransaction{ref.RefTransaction}, refTransactions...)
+	}
+	for _, txobj := range candidates {
+		if txobj == nil {
+			continue
+		}
+		digest := txobj.Digest()
+		if digest == nil || len(ref.TransactionID) == 0 || !bytes.HasPrefix(digest, ref.TransactionID) {
+			continue
+		}
+		if int(ref.EventIndexInRef) >= len(txobj.Events) || txobj.Events[ref.EventIndexInRef] == nil {
+			return nil
+		}
+		return txobj.Events[ref.EventIndexInRef]
+	}
+	return nil
+}
+
+// Verify checks the approvals of all BBcReference objects in the transaction
+//
+// refTransactions are the past transactions which the BBcReference objects point to.
+// An error is returned only if the transaction itself cannot be processed. Missing or invalid approvals are described in the ApprovalReport.
+func (v *ApprovalVerifier) Verify(transaction *BBcTransaction, refTransactions []*BBcTransaction) (*ApprovalReport, error) {
+	if transaction == nil {
+		return nil, errors.New("transaction must be set")
+	}
+	digest := transaction.Digest()
+	if digest == nil {
+		return nil, errors.New("fail to calculate the digest of the transaction")
+	}
+	if v.PubkeyResolver == nil {
+		return nil, errors.New("PubkeyResolver must be set")
+	}
+
+	report := ApprovalReport{TransactionID: transaction.TransactionID}
+	for i, ref := range transaction.References {
+		result := ReferenceApproval{ReferenceIndex: i}
+		report.References = append(report.References, &result)
+		if ref == nil {
+			continue
+		}
+		result.TransactionID = ref.TransactionID
+		result.EventIndexInRef = ref.EventIndexInRef
+		event := resolveEvent(ref, refTransactions)
+		if event == nil {
+			continue
+		}
+		result.Resolved = true
+		result.RequiredOptionNum = int(event.OptionApproverNumNumerator)
+
+		// signed[k] is 1 if approver k has a valid signature, -1 if only invalid ones
+		approvers := append(append([][]byte{}, event.MandatoryApprovers...), event.OptionApprovers...)
+		signed := make([]int, len(approvers))
+		for _, idx := range ref.SigIndices {
+			if idx < 0 || idx >= len(transaction.Signatures) || transaction.Signatures[idx] == nil {
+				result.UnmatchedSigIndices = append(result.UnmatchedSigIndices, idx)
+				continue
+			}
+			sig := transaction.Signatures[idx]
+			if sig.KeyType == KeyTypeNotInitialized {
+				continue
+			}
+			owner := -1
+			for k, userID := range approvers {
+				if idx < len(transaction.SigIndices) {
+					if bytes.Equal(transaction.SigIndices[idx], transaction.sigUserID(userID)) {
+						owner = k
+						break
+					}
+				} else if v.PubkeyResolver.owns(userID, sig) {
+					owner = k
+					break
+				}
+			}
+			if owner < 0 {
+				result.UnmatchedSigIndices = append(result.UnmatchedSigIndices, idx)
+				continue
+			}
+			if VerifyBBcSignature(digest, sig) && v.PubkeyResolver.owns(approvers[owner], sig) {
+				signed[owner] = 1
+			} else if signed[owner] == 0 {
+				signed[owner] = -1
+			}
+		}
+
+		numMandatory := len(event.MandatoryApprovers)
+		for k, userID := range approvers {
+			switch {
+			case k < numMandatory && signed[k] == 0:
+				result.MissingMandatory = append(result.MissingMandatory, userID)
+			case k < numMandatory && signed[k] < 0:
+				result.InvalidMandatory = append(result.InvalidMandatory, userID)
+			case signed[k] == 0:
+				result.MissingOption = append(result.MissingOption, userID)
+			case signed[k] < 0:
+				result.InvalidOption = append(result.InvalidOption, userID)
+			case k >= numMandatory:
+				result.ValidOptionNum++
+			}
+		}
+	}
+	return &report, nil
+}
@@ -0,0 +1,132 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"testing"
+)
+
+// makeApprovalTestTransactions makes a transaction with an event (mandatory: testUsers[0], option: testUsers[1:], 2 of them are needed)
+// and the transaction which consumes the event signed by the users specified by signers
+func makeApprovalTestTransactions(t *testing.T, signers ...int) (*BBcTransaction, *BBcTransaction) {
+	txobj := makeTestTransaction(t, func(b *TransactionBuilder) {
+		b.Event(testAssetGroupID).Asset(testUsers[0]).BodyString("approval test").
+			Approver(testUsers[0]).OptionApprovers(2, testUsers[1:]...)
+	}, 0)
+	var approvers [][]byte
+	for _, i := range signers {
+		approvers = append(approvers, testUsers[i])
+	}
+	txobj2 := makeTestTransaction(t, func(b *TransactionBuilder) {
+		b.Event(testAssetGroupID).Asset(testUsers[1]).BodyString("approval test 2").
+			Reference(testAssetGroupID, txobj, 0).ReferenceApprover(approvers...)
+	}, signers...)
+	return txobj, txobj2
+}
+
+func TestApprovalVerifier(t *testing.T) {
+	t.Run("approved", func(t *testing.T) {
+		txobj, txobj2 := makeApprovalTestTransactions(t, 0, 1, 3)
+		report, err := VerifyApprovals(txobj2, []*BBcTransaction{txobj}, testPubkeyResolver)
+		if err != nil || !report.Approved() {
+			t.Fatalf("must be approved (%v)\n%s", err, report.Stringer())
+		}
+		if report.References[0].ValidOptionNum != 2 || len(report.References[0].MissingOption) != 1 ||
+			!bytes.Equal(report.References[0].MissingOption[0], testUsers[2]) {
+			t.Fatalf("invalid report\n%s", report.Stringer())
+		}
+	})
+
+	t.Run("option approvers are not enough", func(t *testing.T) {
+		txobj, txobj2 := makeApprovalTestTransactions(t, 0, 2)
+		report, _ := VerifyApprovals(txobj2, []*BBcTransaction{txobj}, testPubkeyResolver)
+		if report.Approved() || report.References[0].ValidOptionNum != 1 || report.References[0].RequiredOptionNum != 2 {
+			t.Fatalf("must not be approved\n%s", report.Stringer())
+		}
+	})
+
+	t.Run("missing mandatory approver", func(t *testing.T) {
+		txobj, txobj2 := makeApprovalTestTransactions(t, 1, 2)
+		report, _ := VerifyApprovals(txobj2, []*BBcTransaction{txobj}, testPubkeyResolver)
+		if report.Approved() || len(report.References[0].MissingMandatory) != 1 ||
+			!bytes.Equal(report.References[0].MissingMandatory[0], testUsers[0]) {
+			t.Fatalf("mandatory approver must be missing\n%s", report.Stringer())
+		}
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		txobj, txobj2 := makeApprovalTestTransactions(t, 0, 1, 2)
+		txobj2.Signatures[0].Signature[5] ^= 0xff
+		report, _ := VerifyApprovals(txobj2, []*BBcTransaction{txobj}, testPubkeyResolver)
+		if report.Approved() || len(report.References[0].InvalidMandatory) != 1 ||
+			!bytes.Equal(report.References[0].InvalidMandatory[0], testUsers[0]) {
+			t.Fatalf("signature of mandatory approver must be invalid\n%s", report.Stringer())
+		}
+	})
+
+	t.Run("deserialized transaction", func(t *testing.T) {
+		txobj, txobj2 := makeApprovalTestTransactions(t, 0, 1, 2, 3)
+		dat, _ := txobj2.Pack()
+		obj := BBcTransaction{}
+		obj.Unpack(&dat)
+
+		report, err := VerifyApprovals(&obj, []*BBcTransaction{txobj}, testPubkeyResolver)
+		if err != nil || !report.Approved() || report.References[0].ValidOptionNum != 3 {
+			t.Fatalf("must be approved (%v)\n%s", err, report.Stringer())
+		}
+
+		report, _ = VerifyApprovals(&obj, nil, testPubkeyResolver)
+		if report.Approved() || report.References[0].Resolved {
+			t.Fatalf("reference must not be resolved\n%s", report.Stringer())
+		}
+
+		verifier := ApprovalVerifier{PubkeyResolver: func(userID []byte) [][]byte { return nil }}
+		report, _ = verifier.Verify(&obj, []*BBcTransaction{txobj})
+		if report.Approved() || len(report.References[0].UnmatchedSigIndices) != 4 {
+			t.Fatalf("signatures must not be matched\n%s", report.Stringer())
+		}
+	})
+
+	t.Run("signature by another keypair", func(t *testing.T) {
+		// testUsers[3] signs in the slot of testUsers[0] with its own key
+		txobj, txobj2 := makeApprovalTestTransactions(t, 0, 1, 2)
+		SignToTransaction(txobj2, &testUsers[0], &testKeypairs[3])
+		if result, _ := txobj2.VerifyAll(); !result {
+			t.Fatal("forged signature itself must be valid")
+		}
+		report, _ := VerifyApprovals(txobj2, []*BBcTransaction{txobj}, testPubkeyResolver)
+		if report.Approved() || len(report.References[0].InvalidMandatory) != 1 {
+			t.Fatalf("signature by another keypair must be invalid\n%s", report.Stringer())
+		}
+
+		dat, _ := txobj2.Pack()
+		obj := BBcTransaction{}
+		obj.Unpack(&dat)
+		report, _ = VerifyApprovals(&obj, []*BBcTransaction{txobj}, testPubkeyResolver)
+		if report.Approved() || len(report.References[0].MissingMandatory) != 1 {
+			t.Fatalf("mandatory approver must be missing in the deserialized transaction\n%s", report.Stringer())
+		}
+	})
+
+	t.Run("no resolver", func(t *testing.T) {
+		txobj, txobj2 := makeApprovalTestTransactions(t, 0, 1, 3)
+		if _, err := VerifyApprovals(txobj2, []*BBcTransaction{txobj}, nil); err == nil {
+			t.Fatal("PubkeyResolver must be required")
+		}
+	})
+}
@@ -20,19 +20,32 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/ugorji/go/codec"
+	"io"
+	"math"
+	"os"
 )
 
 /*
 BBcAsset definition
 
-"IDLength" and "digestCalculating" are not included in a packed data. They are for internal use only.
+"IDLength", "digestCalculating" and "form" are not included in a packed data. They are for internal use only.
 
 "AssetID" is the SHA256 digest of packed BBcAsset data, which contains from "UserID" to "AssetBody".
 The length of "AssetID" and "UserID" is defined by "IDLength".
 "Nonce" is automatically determined with random value.
 BBcAsset can contain a digest of a file, string, map[string]interface{} object as asset.
+
+"AssetBodySize" is packed in 2 bytes in the legacy form, so the asset body must be smaller than 64KiB. Pack returns an error for a larger body.
+The extended form, in which "AssetBodySize" is packed in 4 bytes, is used for the BBcAsset objects in a transaction of header version 2 or later.
+"AssetFileSize" is packed in 4 bytes in both forms, so the file must be smaller than 4GiB. It is packed in 8 bytes in the large file form,
+which is the extended form used in a transaction of header version 2 or later with ExtensionLargeAssetFile.
+"AssetFileSize" is uint64 (uint32 in earlier versions of this module) to hold the size in the large file form.
+The form is selected by the parent transaction in packing and unpacking (see version.go), and a BBcAsset object packed alone uses the legacy form.
+Since AssetID is the digest of the packed data, it depends on the form. AssetID is never changed when the transaction selects the form,
+so packing the transaction fails if AssetID has already been calculated in another form (e.g., Version is changed after Digest).
 */
 type (
 	BBcAsset struct {
@@ -41,14 +54,32 @@ type (
 		AssetID           []byte
 		UserID            []byte
 		Nonce             []byte
-		AssetFileSize     uint32
+		AssetFileSize     uint64
 		AssetFileDigest   []byte
 		AssetBodyType     uint16
-		AssetBodySize     uint16
+		AssetBodySize     uint32
 		AssetBody         []byte
+		form              int
 	}
 )
 
+// Forms of the packed BBcAsset data
+const (
+	assetFormLegacy    = iota // AssetFileSize in 4 bytes and AssetBodySize in 2 bytes
+	assetFormExtended         // AssetFileSize in 4 bytes and AssetBodySize in 4 bytes
+	assetFormLargeFile        // AssetFileSize in 8 bytes and AssetBodySize in 4 bytes
+)
+
+// The maximum sizes of the asset body in the legacy form, the asset file in the legacy and extended forms, and the asset file in the large file form
+const (
+	maxLegacyAssetBodySize = 0xffff
+	maxLegacyAssetFileSize = 0xffffffff
+	maxLargeAssetFileSize  = math.MaxInt64
+)
+
+// ErrAssetFileMismatch is wrapped in the error returned by VerifyFile if the file does not match the asset
+var ErrAssetFileMismatch = errors.New("asset file mismatch")
+
 // An object for messagepack encoding/decoding
 var (
 	mh codec.MsgpackHandle
@@ -65,17 +96,6 @@ func encodeMessagePack(values interface{}) ([]byte, error) {
 	return buf, nil
 }
 
-// decodeMessagePack decodes messagepack data
-func decodeMessagePack(buf []byte) (interface{}, error) {
-	var values interface{}
-
-	err := codec.NewDecoderBytes(buf, &mh).Decode(&values)
-	if err != nil {
-		return nil, err
-	}
-	return values, nil
-}
-
 // Stringer outputs the content of the object
 func (p *BBcAsset) Stringer() string {
 	ret := "  Asset:\n"
@@ -108,36 +128,123 @@ func (p *BBcAsset) Add(userID *[]byte) {
 // AddFile add the digest of file in the BBcAsset object
 // Note that this method adds the SHA256 digest of the file content (not file binary itself)
 func (p *BBcAsset) AddFile(fileContent *[]byte) {
-	p.AssetFileSize = uint32(binary.Size(fileContent))
+	p.AssetFileSize = uint64(len(*fileContent))
 	digest := sha256.Sum256(*fileContent)
 	p.AssetFileDigest = digest[:]
 }
 
+// AddFileFromReader adds the digest and the size of the file content read from r, without loading the whole content in memory
+func (p *BBcAsset) AddFileFromReader(r io.Reader) error {
+	h := sha256.New()
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return err
+	}
+	p.AssetFileSize = uint64(size)
+	p.AssetFileDigest = h.Sum(nil)
+	return nil
+}
+
+// AddFileFromPath adds the digest and the size of the file at the path
+func (p *BBcAsset) AddFileFromPath(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return p.AddFileFromReader(f)
+}
+
+// VerifyFile checks that the content read from r matches AssetFileSize and AssetFileDigest
+//
+// The returned error wraps ErrAssetFileMismatch if the content does not match. Reading stops as soon as the content exceeds AssetFileSize.
+func (p *BBcAsset) VerifyFile(r io.Reader) error {
+	if p.AssetFileDigest == nil {
+		return fmt.Errorf("%w: no file digest in the asset", ErrAssetFileMismatch)
+	}
+	if p.AssetFileSize >= maxLargeAssetFileSize {
+		return fmt.Errorf("%w: AssetFileSize is too large (%d bytes)", ErrAssetFileMismatch, p.AssetFileSize)
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, io.LimitReader(r, int64(p.AssetFileSize)+1))
+	if err != nil {
+		return err
+	}
+	if uint64(size) != p.AssetFileSize {
+		if uint64(size) > p.AssetFileSize {
+			return fmt.Errorf("%w: file is larger than %d bytes", ErrAssetFileMismatch, p.AssetFileSize)
+		}
+		return fmt.Errorf("%w: file size is %d bytes but %d bytes is expected", ErrAssetFileMismatch, size, p.AssetFileSize)
+	}
+	if !bytes.Equal(h.Sum(nil), p.AssetFileDigest) {
+		return fmt.Errorf("%w: digest differs", ErrAssetFileMismatch)
+	}
+	return nil
+}
+
 // AddBodyString sets a string data in the BBcAsset object
 func (p *BBcAsset) AddBodyString(bodyContent string) {
-	p.AssetBodyType = 0
+	p.AssetBodyType = AssetBodyTypeString
 	p.AssetBody = []byte(bodyContent)
-	p.AssetBodySize = uint16(len(bodyContent))
+	p.AssetBodySize = uint32(len(bodyContent))
 }
 
 // AddBodyObject sets an object data in the BBcAsset object and convert it in MessagePack format
 func (p *BBcAsset) AddBodyObject(bodyContent interface{}) error {
-	p.AssetBodyType = 1
-	var err error
-	p.AssetBody, err = encodeMessagePack(bodyContent)
+	return p.AddBodyObjectWithType(AssetBodyTypeMessagePack, bodyContent)
+}
+
+// AddBodyObjectWithType sets an object data in the BBcAsset object encoded by the AssetBodyCodec of the bodyType
+func (p *BBcAsset) AddBodyObjectWithType(bodyType uint16, bodyContent interface{}) error {
+	if bodyType == AssetBodyTypeString {
+		return errors.New("use AddBodyString for a string asset body")
+	}
+	bodyCodec := GetAssetBodyCodec(bodyType)
+	if bodyCodec == nil {
+		return &UnsupportedAssetBodyTypeError{AssetBodyType: bodyType}
+	}
+	body, err := bodyCodec.Encode(bodyContent)
 	if err != nil {
 		return err
 	}
-	p.AssetBodySize = uint16(len(p.AssetBody))
+	p.AssetBodyType = bodyType
+	p.AssetBody = body
+	p.AssetBodySize = uint32(len(p.AssetBody))
 	return nil
 }
 
-// GetBodyObject returns the object which is in MessagePack format
+// GetBodyObject returns the object decoded by the AssetBodyCodec of AssetBodyType (nil for a string asset body)
 func (p *BBcAsset) GetBodyObject() (interface{}, error) {
-	if p.AssetBodyType != 1 {
+	if p.AssetBodyType == AssetBodyTypeString {
 		return nil, nil
 	}
-	return decodeMessagePack(p.AssetBody)
+	var values interface{}
+	if err := p.DecodeBody(&values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// DecodeBody decodes the asset body into v (e.g., a pointer to a struct) by the AssetBodyCodec of AssetBodyType
+//
+// A string asset body can be decoded into *string or *[]byte.
+func (p *BBcAsset) DecodeBody(v interface{}) error {
+	if p.AssetBodyType == AssetBodyTypeString {
+		switch dst := v.(type) {
+		case *string:
+			*dst = string(p.AssetBody)
+		case *[]byte:
+			*dst = append([]byte{}, p.AssetBody...)
+		default:
+			return fmt.Errorf("string asset body cannot be decoded into %T", v)
+		}
+		return nil
+	}
+	bodyCodec := GetAssetBodyCodec(p.AssetBodyType)
+	if bodyCodec == nil {
+		return &UnsupportedAssetBodyTypeError{AssetBodyType: p.AssetBodyType}
+	}
+	return bodyCodec.Decode(p.AssetBody, v)
 }
 
 // Digest calculates the SHA256 digest of the AssetID value of the BBcAsset object
@@ -170,13 +277,33 @@ func (p *BBcAsset) Pack() ([]byte, error) {
 	}
 	PutBigInt(buf, &p.UserID, p.IDLength)
 	PutBigInt(buf, &p.Nonce, len(p.Nonce))
-	Put4byte(buf, p.AssetFileSize)
+	if p.form == assetFormLargeFile {
+		if p.AssetFileSize > maxLargeAssetFileSize {
+			return nil, fmt.Errorf("asset file is too large (%d bytes)", p.AssetFileSize)
+		}
+		Put8byte(buf, int64(p.AssetFileSize))
+	} else {
+		if p.AssetFileSize > maxLegacyAssetFileSize {
+			return nil, fmt.Errorf("asset file is too large for 4 bytes AssetFileSize (%d bytes), use transaction header version 2 or later with ExtensionLargeAssetFile", p.AssetFileSize)
+		}
+		Put4byte(buf, uint32(p.AssetFileSize))
+	}
 	if p.AssetFileSize > 0 {
 		PutBigInt(buf, &p.AssetFileDigest, 32)
 	}
 
+	if int(p.AssetBodySize) != len(p.AssetBody) {
+		return nil, fmt.Errorf("AssetBodySize (%d) differs from the length of AssetBody (%d)", p.AssetBodySize, len(p.AssetBody))
+	}
 	Put2byte(buf, p.AssetBodyType)
-	Put2byte(buf, p.AssetBodySize)
+	if p.form != assetFormLegacy {
+		Put4byte(buf, p.AssetBodySize)
+	} else {
+		if p.AssetBodySize > maxLegacyAssetBodySize {
+			return nil, fmt.Errorf("asset body is too large for the legacy form (%d bytes), use transaction header version 2 or later", p.AssetBodySize)
+		}
+		Put2byte(buf, uint16(p.AssetBodySize))
+	}
 	if p.AssetBodySize > 0 {
 		if err := binary.Write(buf, binary.LittleEndian, p.AssetBody); err != nil {
 			return nil, err
@@ -187,6 +314,11 @@ func (p *BBcAsset) Pack() ([]byte, error) {
 
 // Unpack the BBcAsset object to the binary data
 func (p *BBcAsset) Unpack(dat *[]byte) error {
+	return p.unpack(dat, nil)
+}
+
+// unpack the binary data to the BBcAsset object within the limits (no limit if limits is nil)
+func (p *BBcAsset) unpack(dat *[]byte, limits *DecodeLimits) error {
 	var err error
 	buf := bytes.NewBuffer(*dat)
 
@@ -205,7 +337,17 @@ func (p *BBcAsset) Unpack(dat *[]byte) error {
 		return err
 	}
 
-	p.AssetFileSize, err = Get4byte(buf)
+	if p.form == assetFormLargeFile {
+		var size int64
+		if size, err = Get8byte(buf); err == nil && size < 0 {
+			return fmt.Errorf("invalid asset file size %d", size)
+		}
+		p.AssetFileSize = uint64(size)
+	} else {
+		var size uint32
+		size, err = Get4byte(buf)
+		p.AssetFileSize = uint64(size)
+	}
 	if err != nil {
 		return err
 	}
@@ -220,11 +362,29 @@ func (p *BBcAsset) Unpack(dat *[]byte) error {
 	if err != nil {
 		return err
 	}
-	p.AssetBodySize, err = Get2byte(buf)
+	if p.form != assetFormLegacy {
+		p.AssetBodySize, err = Get4byte(buf)
+	} else {
+		var size uint16
+		size, err = Get2byte(buf)
+		p.AssetBodySize = uint32(size)
+	}
 	if err != nil {
 		return err
 	}
+	if err = limits.checkAssetBodySize(p.AssetBodySize); err != nil {
+		return err
+	}
 	p.AssetBody, err = GetBytes(buf, int(p.AssetBodySize))
 
 	return err
 }
+
+// setForm selects the form of the packed data (an error is returned if AssetID has already been calculated in another form)
+func (p *BBcAsset) setForm(form int) error {
+	if p.form != form && p.AssetID != nil {
+		return errors.New("AssetID has been calculated in another form (set Version and Extensions of the transaction before calculating AssetID)")
+	}
+	p.form = form
+	return nil
+}
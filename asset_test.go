@@ -18,7 +18,13 @@ package bbclib
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -121,3 +127,235 @@ func TestAssetPackUnpack(t *testing.T) {
 
 	})
 }
+
+func TestAssetLargeBody(t *testing.T) {
+	assetGroupID := GetIdentifier("asset_group_id_large", defaultIDLength)
+	u1 := GetIdentifier("user1_789abcdef0123456789abcdef0", defaultIDLength)
+	largeBody := string(bytes.Repeat([]byte("0123456789abcdef"), 100*1024/16))
+	largeObject := map[string]interface{}{"data": bytes.Repeat([]byte{0xab}, 80*1024), "name": "large"}
+
+	t.Run("legacy form rejects a large body", func(t *testing.T) {
+		obj := BBcAsset{IDLength: defaultIDLength}
+		obj.Add(&u1)
+		obj.AddBodyString(largeBody)
+		if int(obj.AssetBodySize) != len(largeBody) {
+			t.Fatalf("AssetBodySize is wrapped around (%d)", obj.AssetBodySize)
+		}
+		if _, err := obj.Pack(); err == nil {
+			t.Fatal("a large body must be rejected in the legacy form")
+		}
+
+		b := NewTransactionBuilder(defaultIDLength).Event(assetGroupID).Asset(u1).BodyString(largeBody)
+		if _, err := b.Build(); err == nil {
+			t.Fatal("Validate must reject a large body in version 1")
+		}
+		txobj := MakeTransaction(1, 0, false, defaultIDLength)
+		AddEventAssetBodyString(txobj, 0, &assetGroupID, &u1, largeBody)
+		if _, err := txobj.Pack(); err == nil {
+			t.Fatal("Pack must fail for a large body in version 1")
+		}
+	})
+
+	t.Run("extended form in version 2", func(t *testing.T) {
+		txobj, err := NewTransactionBuilder(defaultIDLength).Version(TransactionVersion2).
+			Event(assetGroupID).Asset(u1).BodyString(largeBody).Approver(u1).
+			Relation(assetGroupID).Asset(u1).BodyObject(largeObject).
+			Build()
+		if err != nil {
+			t.Fatalf("failed to build (%v)", err)
+		}
+		keypair := GenerateKeypair(KeyTypeEcdsaP256v1, defaultCompressionMode)
+		SignToTransaction(txobj, &u1, &keypair)
+
+		dat, err := Serialize(txobj, FormatZlib)
+		if err != nil {
+			t.Fatalf("failed to serialize (%v)", err)
+		}
+		obj, err := DeserializeWithLimits(dat, nil)
+		if err != nil {
+			t.Fatalf("failed to deserialize (%v)", err)
+		}
+		if !obj.Equal(txobj) {
+			t.Fatalf("deserialized transaction differs: %v", obj.Diff(txobj))
+		}
+		if string(obj.Events[0].Asset.AssetBody) != largeBody {
+			t.Fatal("large body is not recovered")
+		}
+		body, err := obj.Relations[0].Asset.GetBodyObject()
+		if err != nil || len(body.(map[interface{}]interface{})["data"].([]byte)) != 80*1024 {
+			t.Fatalf("large object is not recovered (%v)", err)
+		}
+		if ret, _ := obj.VerifyAll(); !ret {
+			t.Fatal("failed to verify")
+		}
+	})
+
+	t.Run("AssetID calculated in another form", func(t *testing.T) {
+		txobj, _ := NewTransactionBuilder(defaultIDLength).Event(assetGroupID).Asset(u1).BodyString("small").Build()
+		legacyID := append([]byte{}, txobj.Events[0].Asset.AssetID...)
+		txobj.Version = TransactionVersion2
+		if _, err := txobj.Pack(); err == nil || !strings.HasPrefix(err.Error(), "Events[0].Asset:") {
+			t.Fatalf("Pack must fail for AssetID in the legacy form (%v)", err)
+		}
+		if !bytes.Equal(legacyID, txobj.Events[0].Asset.AssetID) {
+			t.Fatal("AssetID must not be changed")
+		}
+
+		txobj.Events[0].Asset.AssetID = nil
+		txobj.TransactionID = nil
+		txobj.Digest()
+		if bytes.Equal(legacyID, txobj.Events[0].Asset.AssetID) {
+			t.Fatal("AssetID must be calculated in the extended form")
+		}
+		dat, _ := txobj.Pack()
+		obj := BBcTransaction{}
+		if err := obj.Unpack(&dat); err != nil || !obj.Equal(txobj) {
+			t.Fatalf("failed to unpack (%v)", err)
+		}
+	})
+}
+
+// zeroReader returns zero bytes without allocating the whole content
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestAssetFileStreaming(t *testing.T) {
+	u1 := GetIdentifier("user1_789abcdef0123456789abcdef0", defaultIDLength)
+	content := bytes.Repeat([]byte("file content "), 10000)
+
+	t.Run("reader and path", func(t *testing.T) {
+		expected := BBcAsset{IDLength: defaultIDLength}
+		expected.AddFile(&content)
+		if expected.AssetFileSize != uint64(len(content)) {
+			t.Fatalf("AssetFileSize is wrong (%d)", expected.AssetFileSize)
+		}
+
+		obj := BBcAsset{IDLength: defaultIDLength}
+		obj.Add(&u1)
+		if err := obj.AddFileFromReader(bytes.NewReader(content)); err != nil {
+			t.Fatalf("failed to add file (%v)", err)
+		}
+		if obj.AssetFileSize != expected.AssetFileSize || !bytes.Equal(obj.AssetFileDigest, expected.AssetFileDigest) {
+			t.Fatal("AddFileFromReader differs from AddFile")
+		}
+
+		path := filepath.Join(t.TempDir(), "asset.dat")
+		if err := ioutil.WriteFile(path, content, 0600); err != nil {
+			t.Fatal(err)
+		}
+		obj2 := BBcAsset{IDLength: defaultIDLength}
+		if err := obj2.AddFileFromPath(path); err != nil {
+			t.Fatalf("failed to add file (%v)", err)
+		}
+		if obj2.AssetFileSize != expected.AssetFileSize || !bytes.Equal(obj2.AssetFileDigest, expected.AssetFileDigest) {
+			t.Fatal("AddFileFromPath differs from AddFile")
+		}
+		if err := obj2.AddFileFromPath(path + ".none"); !os.IsNotExist(err) {
+			t.Fatalf("error of opening the file is expected (%v)", err)
+		}
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		obj := BBcAsset{IDLength: defaultIDLength}
+		obj.AddFileFromReader(bytes.NewReader(content))
+		if err := obj.VerifyFile(bytes.NewReader(content)); err != nil {
+			t.Fatalf("failed to verify (%v)", err)
+		}
+
+		modified := append([]byte{}, content...)
+		modified[100] ^= 0xff
+		cases := [][]byte{modified, content[:len(content)-1], append(append([]byte{}, content...), 0)}
+		for i, c := range cases {
+			if err := obj.VerifyFile(bytes.NewReader(c)); !errors.Is(err, ErrAssetFileMismatch) {
+				t.Fatalf("case %d: ErrAssetFileMismatch is expected (%v)", i, err)
+			}
+		}
+		// an endless stream is not read to the end
+		if err := obj.VerifyFile(zeroReader{}); !errors.Is(err, ErrAssetFileMismatch) {
+			t.Fatalf("ErrAssetFileMismatch is expected (%v)", err)
+		}
+		if err := (&BBcAsset{}).VerifyFile(bytes.NewReader(content)); !errors.Is(err, ErrAssetFileMismatch) {
+			t.Fatalf("asset without a file must not match (%v)", err)
+		}
+	})
+
+	t.Run("streaming a large content", func(t *testing.T) {
+		const size = 64 * 1024 * 1024
+		obj := BBcAsset{IDLength: defaultIDLength}
+		if err := obj.AddFileFromReader(io.LimitReader(zeroReader{}, size)); err != nil {
+			t.Fatalf("failed to add file (%v)", err)
+		}
+		if obj.AssetFileSize != size {
+			t.Fatalf("AssetFileSize is wrong (%d)", obj.AssetFileSize)
+		}
+		if err := obj.VerifyFile(io.LimitReader(zeroReader{}, size)); err != nil {
+			t.Fatalf("failed to verify (%v)", err)
+		}
+	})
+
+	t.Run("file larger than 4GiB", func(t *testing.T) {
+		assetGroupID := GetIdentifier("asset_group_id_file", defaultIDLength)
+		obj := BBcAsset{IDLength: defaultIDLength}
+		obj.Add(&u1)
+		obj.AssetFileSize = 5 << 30
+		obj.AssetFileDigest = GetIdentifier("dummy digest", 32)
+		if _, err := obj.Pack(); err == nil {
+			t.Fatal("a large file must be rejected in the legacy form")
+		}
+
+		txobj := MakeTransaction(1, 0, false, defaultIDLength)
+		txobj.Version = TransactionVersion2
+		txobj.Events[0].Add(&assetGroupID, &obj)
+		if _, err := txobj.Pack(); err == nil {
+			t.Fatal("a large file must be rejected without ExtensionLargeAssetFile")
+		}
+		if err := txobj.Validate(); err == nil {
+			t.Fatal("a large file must be reported without ExtensionLargeAssetFile")
+		}
+
+		txobj.AddExtension(ExtensionLargeAssetFile, nil)
+		if err := txobj.Validate(); err != nil {
+			t.Fatalf("failed to validate (%v)", err)
+		}
+		dat, err := txobj.Pack()
+		if err != nil {
+			t.Fatalf("failed to pack (%v)", err)
+		}
+		txobj2 := BBcTransaction{}
+		if err := txobj2.Unpack(&dat); err != nil {
+			t.Fatalf("failed to unpack (%v)", err)
+		}
+		if txobj2.Events[0].Asset.AssetFileSize != 5<<30 || !bytes.Equal(txobj2.TransactionID, txobj.Digest()) {
+			t.Fatalf("AssetFileSize is not recovered (%d)", txobj2.Events[0].Asset.AssetFileSize)
+		}
+
+		// the extension adds 4 bytes to the asset file size (and the extension itself)
+		small := MakeTransaction(1, 0, false, defaultIDLength)
+		small.Version = TransactionVersion2
+		AddEventAssetBodyString(small, 0, &assetGroupID, &u1, "small")
+		extended, _ := small.Pack()
+		small.AddExtension(ExtensionLargeAssetFile, nil)
+		small.Events[0].Asset.AssetID = nil
+		large, _ := small.Pack()
+		if len(large)-len(extended) != 2+4+4 {
+			t.Fatalf("unexpected length difference (%d and %d)", len(extended), len(large))
+		}
+	})
+
+	t.Run("AssetFileSize beyond int64", func(t *testing.T) {
+		obj := BBcAsset{IDLength: defaultIDLength, AssetFileSize: math.MaxUint64, AssetFileDigest: GetIdentifier("dummy digest", 32)}
+		if err := obj.VerifyFile(bytes.NewReader([]byte("content"))); !errors.Is(err, ErrAssetFileMismatch) {
+			t.Fatalf("ErrAssetFileMismatch is expected (%v)", err)
+		}
+		obj.form = assetFormLargeFile
+		if _, err := obj.Pack(); err == nil {
+			t.Fatal("AssetFileSize beyond int64 must be rejected")
+		}
+	})
+}
@@ -37,15 +37,16 @@ package bbclib
 import (
 	"bytes"
 	"encoding/binary"
-	"errors"
-	"fmt"
 	"time"
 )
 
 // Header values for serialized data
 const (
-	FormatPlain = 0x0000
-	FormatZlib  = 0x0010
+	FormatPlain  = 0x0000
+	FormatZlib   = 0x0010
+	FormatZstd   = 0x0020
+	FormatLz4    = 0x0030
+	FormatBrotli = 0x0040
 )
 
 const (
@@ -58,8 +59,19 @@ Serialize BBcTransaction object into packed data
 formatType = 0x0000: Packed data is simply used for serialized data.
 
 formatType = 0x0010: Packed data is compressed using zlib, and the compressed data is used for serialized data.
+
+formatType = 0x0020, 0x0030, 0x0040: Packed data is compressed using zstd, lz4 and brotli, respectively.
+
+Other formatType can be used by registering a Codec (see RegisterCodec). UnsupportedFormatError is returned for an unknown formatType.
 */
 func Serialize(transaction *BBcTransaction, formatType uint16) ([]byte, error) {
+	var codec Codec
+	if formatType != FormatPlain {
+		if codec = GetCodec(formatType); codec == nil {
+			return nil, &UnsupportedFormatError{FormatType: formatType}
+		}
+	}
+
 	buf := new(bytes.Buffer)
 	Put2byte(buf, formatType)
 	dat, err := transaction.Pack()
@@ -67,22 +79,45 @@ func Serialize(transaction *BBcTransaction, formatType uint16) ([]byte, error) {
 		return nil, err
 	}
 
-	if formatType == FormatPlain {
-		if err := binary.Write(buf, binary.LittleEndian, dat); err != nil {
-			return nil, err
-		}
-	} else if formatType == FormatZlib {
-		compressed := ZlibCompress(&dat)
-		if err := binary.Write(buf, binary.LittleEndian, compressed); err != nil {
+	if codec != nil {
+		if dat, err = codec.Compress(dat); err != nil {
 			return nil, err
 		}
 	}
+	if err := binary.Write(buf, binary.LittleEndian, dat); err != nil {
+		return nil, err
+	}
 
 	return buf.Bytes(), nil
 }
 
 // Deserialize BBcTransaction data with header
 func Deserialize(dat []byte) (*BBcTransaction, error) {
+	return deserialize(dat, nil)
+}
+
+/*
+DeserializeWithLimits deserializes BBcTransaction data with header within the limits
+
+This should be used for data received from untrusted peers. DefaultDecodeLimits is used if limits is nil.
+DecodeLimitError is returned if the data exceeds a limit.
+*/
+func DeserializeWithLimits(dat []byte, limits *DecodeLimits) (*BBcTransaction, error) {
+	if limits == nil {
+		limits = &DefaultDecodeLimits
+	}
+	return deserialize(dat, limits)
+}
+
+// deserialize is the body of Deserialize and DeserializeWithLimits (no limit is applied if limits is nil)
+func deserialize(dat []byte, limits *DecodeLimits) (*BBcTransaction, error) {
+	maxDecompressedSize := 0
+	if limits != nil {
+		if err := checkLimit("serialized size", len(dat), limits.MaxSize); err != nil {
+			return nil, err
+		}
+		maxDecompressedSize = limits.MaxDecompressedSize
+	}
 	buf := bytes.NewBuffer(dat)
 
 	formatType, err := Get2byte(buf)
@@ -95,21 +130,19 @@ func Deserialize(dat []byte) (*BBcTransaction, error) {
 		return nil, err
 	}
 
-	if formatType == FormatPlain {
-		txobj := BBcTransaction{}
-		err2 := txobj.Unpack(&txdat)
-		return &txobj, err2
-	} else if formatType == FormatZlib {
-		decompressed, err := ZlibDecompress(txdat)
-		if err != nil {
+	if formatType != FormatPlain {
+		codec := GetCodec(formatType)
+		if codec == nil {
+			return nil, &UnsupportedFormatError{FormatType: formatType}
+		}
+		if txdat, err = codec.Decompress(txdat, maxDecompressedSize); err != nil {
 			return nil, err
 		}
-		txobj := BBcTransaction{}
-		err2 := txobj.Unpack(&decompressed)
-		return &txobj, err2
 	}
 
-	return nil, errors.New("formatType not supported")
+	txobj := BBcTransaction{}
+	err = txobj.unpack(&txdat, limits)
+	return &txobj, err
 }
 
 // MakeTransaction is a utility for making simple BBcTransaction object with BBcEvent, BBcRelation or/and BBcWitness
@@ -135,56 +168,44 @@ func MakeTransaction(eventNum, relationNum int, witness bool, idLength int) *BBc
 	return &txobj
 }
 
-// addInRelation is an internal function to create a BBcAsset and add it to  BBcRelation object and then a BBcTransaction object
-func addInRelation(transaction *BBcTransaction, relationIdx int, assetGroupID, userID *[]byte) {
-	ast := BBcAsset{}
-	transaction.Relations[relationIdx].Add(assetGroupID, &ast)
-	ast.Add(userID)
+// derefID returns the ID which the pointer refers to (nil if the pointer is nil)
+func derefID(id *[]byte) []byte {
+	if id == nil {
+		return nil
+	}
+	return *id
 }
 
 // AddRelationAssetFile sets a file digest to BBcAsset in BBcRelation and add it to a BBcTransaction object
-func AddRelationAssetFile(transaction *BBcTransaction, relationIdx int, assetGroupID, userID, assetFile *[]byte) {
-	if transaction == nil {
-		return
-	}
-	addInRelation(transaction, relationIdx, assetGroupID, userID)
+func AddRelationAssetFile(transaction *BBcTransaction, relationIdx int, assetGroupID, userID, assetFile *[]byte) error {
+	b := NewTransactionBuilderFrom(transaction).RelationAt(relationIdx).AssetGroup(derefID(assetGroupID)).Asset(derefID(userID))
 	if assetFile != nil {
-		transaction.Relations[relationIdx].Asset.AddFile(assetFile)
+		b.File(*assetFile)
 	}
+	return b.Err()
 }
 
 // AddRelationAssetBodyString sets a string in BBcAsset in BBcRelation and add it to a BBcTransaction object
-func AddRelationAssetBodyString(transaction *BBcTransaction, relationIdx int, assetGroupID, userID *[]byte, body string) {
-	if transaction == nil {
-		return
-	}
-	addInRelation(transaction, relationIdx, assetGroupID, userID)
+func AddRelationAssetBodyString(transaction *BBcTransaction, relationIdx int, assetGroupID, userID *[]byte, body string) error {
+	b := NewTransactionBuilderFrom(transaction).RelationAt(relationIdx).AssetGroup(derefID(assetGroupID)).Asset(derefID(userID))
 	if body != "" {
-		transaction.Relations[relationIdx].Asset.AddBodyString(body)
+		b.BodyString(body)
 	}
+	return b.Err()
 }
 
 // AddRelationAssetBodyObject sets an object (map[string]interface{}) in BBcAsset in BBcRelation, convert the info into msgpack, and add it in a BBcTransaction object
-func AddRelationAssetBodyObject(transaction *BBcTransaction, relationIdx int, assetGroupID, userID *[]byte, body interface{}) {
-	if transaction == nil {
-		return
-	}
-	addInRelation(transaction, relationIdx, assetGroupID, userID)
+func AddRelationAssetBodyObject(transaction *BBcTransaction, relationIdx int, assetGroupID, userID *[]byte, body interface{}) error {
+	b := NewTransactionBuilderFrom(transaction).RelationAt(relationIdx).AssetGroup(derefID(assetGroupID)).Asset(derefID(userID))
 	if body != nil {
-		if err := transaction.Relations[relationIdx].Asset.AddBodyObject(body); err != nil {
-			fmt.Println("Fail to exec AddRelationAssetBodyObject")
-		}
+		b.BodyObject(body)
 	}
+	return b.Err()
 }
 
 // AddRelationPointer creates and includes a BBcPointer object in BBcRelation and then, add it in a BBcTransaction object
-func AddRelationPointer(transaction *BBcTransaction, relationIdx int, refTransactionID, refAssetID *[]byte) {
-	if transaction == nil {
-		return
-	}
-	ptr := BBcPointer{}
-	transaction.Relations[relationIdx].AddPointer(&ptr)
-	ptr.Add(refTransactionID, refAssetID)
+func AddRelationPointer(transaction *BBcTransaction, relationIdx int, refTransactionID, refAssetID *[]byte) error {
+	return NewTransactionBuilderFrom(transaction).RelationAt(relationIdx).Pointer(derefID(refTransactionID), derefID(refAssetID)).Err()
 }
 
 // AddPointerInRelation creates and includes a BBcPointer object in BBcRelation
@@ -195,56 +216,35 @@ func AddPointerInRelation(relation *BBcRelation, refTransaction *BBcTransaction,
 }
 
 // AddReference creates and includes a BBcReference object in a BBcTransaction object
-func AddReference(transaction *BBcTransaction, assetGroupID *[]byte, refTransaction *BBcTransaction, eventIdx int) {
-	if transaction == nil || refTransaction == nil {
-		return
-	}
-	if refTransaction.TransactionID == nil {
-		refTransaction.Digest()
-	}
-	ref := BBcReference{}
-	transaction.AddReference(&ref)
-	ref.Add(assetGroupID, refTransaction, eventIdx)
-}
-
-// addInEvent is an internal function to add a BBcEvent object in a BBcTransaction object
-func addInEvent(transaction *BBcTransaction, eventIdx int, assetGroupID, userID *[]byte) {
-	ast := BBcAsset{}
-	transaction.Events[eventIdx].Add(assetGroupID, &ast)
-	ast.Add(userID)
+func AddReference(transaction *BBcTransaction, assetGroupID *[]byte, refTransaction *BBcTransaction, eventIdx int) error {
+	return NewTransactionBuilderFrom(transaction).Reference(derefID(assetGroupID), refTransaction, eventIdx).Err()
 }
 
 // AddEventAssetFile sets a file digest to a BBcAsset object in a BBcEvent object and then, add it in a BBcTransaction object
-func AddEventAssetFile(transaction *BBcTransaction, eventIdx int, assetGroupID, userID *[]byte, assetFile *[]byte) {
-	if transaction == nil {
-		return
-	}
-	addInEvent(transaction, eventIdx, assetGroupID, userID)
+func AddEventAssetFile(transaction *BBcTransaction, eventIdx int, assetGroupID, userID *[]byte, assetFile *[]byte) error {
+	b := NewTransactionBuilderFrom(transaction).EventAt(eventIdx).AssetGroup(derefID(assetGroupID)).Asset(derefID(userID))
 	if assetFile != nil {
-		transaction.Events[eventIdx].Asset.AddFile(assetFile)
+		b.File(*assetFile)
 	}
+	return b.Err()
 }
 
 // AddEventAssetBodyString sets a string to a BBcAsset object in a BBcEvent object and then, add it in a BBcTransaction object
-func AddEventAssetBodyString(transaction *BBcTransaction, eventIdx int, assetGroupID, userID *[]byte, body string) {
-	if transaction == nil {
-		return
-	}
-	addInEvent(transaction, eventIdx, assetGroupID, userID)
+func AddEventAssetBodyString(transaction *BBcTransaction, eventIdx int, assetGroupID, userID *[]byte, body string) error {
+	b := NewTransactionBuilderFrom(transaction).EventAt(eventIdx).AssetGroup(derefID(assetGroupID)).Asset(derefID(userID))
 	if body != "" {
-		transaction.Events[eventIdx].Asset.AddBodyString(body)
+		b.BodyString(body)
 	}
+	return b.Err()
 }
 
 // AddEventAssetBodyObject sets an object (map[string]interface{}) to a BBcAsset object in a BBcEvent object and then, add it in a BBcTransaction object
-func AddEventAssetBodyObject(transaction *BBcTransaction, eventIdx int, assetGroupID, userID *[]byte, body interface{}) {
-	if transaction == nil {
-		return
-	}
-	addInEvent(transaction, eventIdx, assetGroupID, userID)
-	if body != "" {
-		transaction.Events[eventIdx].Asset.AddBodyObject(body)
+func AddEventAssetBodyObject(transaction *BBcTransaction, eventIdx int, assetGroupID, userID *[]byte, body interface{}) error {
+	b := NewTransactionBuilderFrom(transaction).EventAt(eventIdx).AssetGroup(derefID(assetGroupID)).Asset(derefID(userID))
+	if body != nil {
+		b.BodyObject(body)
 	}
+	return b.Err()
 }
 
 // MakeRelationWithAsset is a utility for making simple BBcTransaction object with BBcRelation with BBcAsset
@@ -264,11 +264,15 @@ func MakeRelationWithAsset(assetGroupID, userID *[]byte, assetBodyString string,
 	return &rtn
 }
 
-// SignToTransaction signs the transaction and append the BBcSignature object to it
-func SignToTransaction(transaction *BBcTransaction, userID *[]byte, keypair *KeyPair) {
+// SignToTransaction signs the transaction with the signer (e.g., KeyPair object) and append the BBcSignature object to it
+func SignToTransaction(transaction *BBcTransaction, userID *[]byte, signer Signer) error {
+	signature, err := transaction.Sign(signer)
+	if err != nil {
+		return err
+	}
 	sig := BBcSignature{}
-	sig.SetPublicKeyByKeypair(keypair)
-	signature, _ := transaction.Sign(keypair)
+	sig.SetPublicKeyBySigner(signer)
 	sig.SetSignature(&signature)
 	transaction.AddSignature(userID, &sig)
+	return nil
 }
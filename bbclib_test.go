@@ -24,6 +24,50 @@ var (
 	keypair2         KeyPair
 )
 
+// Identifiers and key pairs shared by the tests which make transactions with makeTestTransaction
+var (
+	testAssetGroupID = GetIdentifier("asset_group_id_for_test", defaultIDLength)
+	testUsers        = [][]byte{
+		GetIdentifier("user1_for_test", defaultIDLength),
+		GetIdentifier("user2_for_test", defaultIDLength),
+		GetIdentifier("user3_for_test", defaultIDLength),
+		GetIdentifier("user4_for_test", defaultIDLength),
+	}
+	testKeypairs = []KeyPair{
+		GenerateKeypair(KeyTypeEcdsaP256v1, defaultCompressionMode),
+		GenerateKeypair(KeyTypeEcdsaSECP256k1, defaultCompressionMode),
+		GenerateKeypair(KeyTypeEd25519, defaultCompressionMode),
+		GenerateKeypair(KeyTypeEcdsaP256v1, defaultCompressionMode),
+	}
+)
+
+// makeTestTransaction builds a transaction with the TransactionBuilder set up by build, and signs it by testUsers[i] for each i in signers
+func makeTestTransaction(t testing.TB, build func(b *TransactionBuilder), signers ...int) *BBcTransaction {
+	t.Helper()
+	b := NewTransactionBuilder(defaultIDLength)
+	build(b)
+	txobj, err := b.Build()
+	if err != nil {
+		t.Fatalf("failed to build (%v)", err)
+	}
+	for _, i := range signers {
+		if err := SignToTransaction(txobj, &testUsers[i], &testKeypairs[i]); err != nil {
+			t.Fatalf("failed to sign (%v)", err)
+		}
+	}
+	return txobj
+}
+
+// testPubkeyResolver returns the public key of the user in testUsers
+func testPubkeyResolver(userID []byte) [][]byte {
+	for i := range testUsers {
+		if bytes.Equal(testUsers[i], userID) {
+			return [][]byte{testKeypairs[i].Pubkey}
+		}
+	}
+	return nil
+}
+
 func TestBBcLibUtilitiesTx1(t *testing.T) {
 	assetGroupID = GetIdentifierWithTimestamp("assetGroupID", defaultIDLength)
 	u1 = GetIdentifierWithTimestamp("user1", defaultIDLength)
@@ -0,0 +1,159 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"sync"
+)
+
+/*
+AssetBodyCodec definition
+
+An AssetBodyCodec encodes an object into the asset body and decodes the asset body into an object. An AssetBodyCodec is registered for each AssetBodyType,
+and AddBodyObjectWithType, GetBodyObject and DecodeBody of BBcAsset look up the AssetBodyCodec in the registry according to AssetBodyType.
+AssetBodyCodecs for MessagePack, JSON, CBOR and Protocol Buffers are registered by default, and applications can register their own AssetBodyCodecs.
+AssetBodyTypeString is not an AssetBodyCodec (the asset body is used as is) and cannot be replaced.
+
+"Decode" sets the decoded object to v, which is a pointer to a variable (e.g., a pointer to a struct or to an interface{}).
+The codec for AssetBodyTypeProtobuf accepts only proto.Message for both "Encode" and "Decode", so the asset body in Protocol Buffers cannot be decoded by GetBodyObject.
+Encoders for CBOR and Protocol Buffers are deterministic, so that the same object results in the same AssetID.
+
+UnsupportedAssetBodyTypeError is returned if no AssetBodyCodec is registered for the AssetBodyType.
+*/
+type (
+	AssetBodyCodec interface {
+		Encode(v interface{}) ([]byte, error)
+		Decode(dat []byte, v interface{}) error
+	}
+
+	// UnsupportedAssetBodyTypeError reports the unknown AssetBodyType
+	UnsupportedAssetBodyTypeError struct {
+		AssetBodyType uint16
+	}
+
+	messagePackBodyCodec struct{}
+	jsonBodyCodec        struct{}
+	cborBodyCodec        struct{}
+	protobufBodyCodec    struct{}
+)
+
+// Values of AssetBodyType
+const (
+	AssetBodyTypeString      = 0
+	AssetBodyTypeMessagePack = 1
+	AssetBodyTypeJSON        = 2
+	AssetBodyTypeCBOR        = 3
+	AssetBodyTypeProtobuf    = 4
+)
+
+// The registry of AssetBodyCodecs (AssetBodyType -> AssetBodyCodec)
+var (
+	assetBodyCodecLock sync.RWMutex
+	assetBodyCodecs    = make(map[uint16]AssetBodyCodec)
+)
+
+// cborEncMode encodes in the deterministic encoding of RFC 8949 (Core Deterministic Encoding)
+var cborEncMode cbor.EncMode
+
+func init() {
+	var err error
+	if cborEncMode, err = cbor.CoreDetEncOptions().EncMode(); err != nil {
+		panic(err)
+	}
+	RegisterAssetBodyCodec(AssetBodyTypeMessagePack, messagePackBodyCodec{})
+	RegisterAssetBodyCodec(AssetBodyTypeJSON, jsonBodyCodec{})
+	RegisterAssetBodyCodec(AssetBodyTypeCBOR, cborBodyCodec{})
+	RegisterAssetBodyCodec(AssetBodyTypeProtobuf, protobufBodyCodec{})
+}
+
+func (e *UnsupportedAssetBodyTypeError) Error() string {
+	return fmt.Sprintf("asset body type %d not supported", e.AssetBodyType)
+}
+
+// RegisterAssetBodyCodec sets the AssetBodyCodec for the bodyType in the registry (the existing one is replaced, and nil codec removes it)
+func RegisterAssetBodyCodec(bodyType uint16, bodyCodec AssetBodyCodec) {
+	if bodyType == AssetBodyTypeString {
+		return
+	}
+	assetBodyCodecLock.Lock()
+	defer assetBodyCodecLock.Unlock()
+	if bodyCodec == nil {
+		delete(assetBodyCodecs, bodyType)
+		return
+	}
+	assetBodyCodecs[bodyType] = bodyCodec
+}
+
+// GetAssetBodyCodec returns the AssetBodyCodec for the bodyType (nil if not registered)
+func GetAssetBodyCodec(bodyType uint16) AssetBodyCodec {
+	assetBodyCodecLock.RLock()
+	defer assetBodyCodecLock.RUnlock()
+	return assetBodyCodecs[bodyType]
+}
+
+// Encode encodes the object in MessagePack format
+func (messagePackBodyCodec) Encode(v interface{}) ([]byte, error) {
+	return encodeMessagePack(v)
+}
+
+// Decode decodes the data in MessagePack format
+func (messagePackBodyCodec) Decode(dat []byte, v interface{}) error {
+	return codec.NewDecoderBytes(dat, &mh).Decode(v)
+}
+
+// Encode encodes the object in JSON
+func (jsonBodyCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Decode decodes the data in JSON
+func (jsonBodyCodec) Decode(dat []byte, v interface{}) error {
+	return json.Unmarshal(dat, v)
+}
+
+// Encode encodes the object in CBOR format
+func (cborBodyCodec) Encode(v interface{}) ([]byte, error) {
+	return cborEncMode.Marshal(v)
+}
+
+// Decode decodes the data in CBOR format
+func (cborBodyCodec) Decode(dat []byte, v interface{}) error {
+	return cbor.Unmarshal(dat, v)
+}
+
+// Encode encodes the proto.Message in Protocol Buffers wire format
+func (protobufBodyCodec) Encode(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a proto.Message", v)
+	}
+	return proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+}
+
+// Decode decodes the data in Protocol Buffers wire format into the proto.Message
+func (protobufBodyCodec) Decode(dat []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(dat, msg)
+}
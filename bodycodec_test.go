@@ -0,0 +1,201 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"testing"
+)
+
+type bodyCodecTestRecord struct {
+	Name   string            `json:"name" codec:"name" cbor:"name"`
+	Amount int64             `json:"amount" codec:"amount" cbor:"amount"`
+	Tags   []string          `json:"tags" codec:"tags" cbor:"tags"`
+	Attrs  map[string]string `json:"attrs" codec:"attrs" cbor:"attrs"`
+}
+
+// reverseBodyCodec is a codec for testing the registry
+type reverseBodyCodec struct{}
+
+func (reverseBodyCodec) Encode(v interface{}) ([]byte, error) {
+	s, _ := v.(string)
+	dat := []byte(s)
+	for i, j := 0, len(dat)-1; i < j; i, j = i+1, j-1 {
+		dat[i], dat[j] = dat[j], dat[i]
+	}
+	return dat, nil
+}
+
+func (c reverseBodyCodec) Decode(dat []byte, v interface{}) error {
+	rev, _ := c.Encode(string(dat))
+	*(v.(*interface{})) = string(rev)
+	return nil
+}
+
+func TestAssetBodyCodec(t *testing.T) {
+	u1 := GetIdentifier("user1_bodycodec", defaultIDLength)
+	assetGroupID := GetIdentifier("asset_group_id_bodycodec", defaultIDLength)
+	record := bodyCodecTestRecord{
+		Name:   "sample",
+		Amount: 12345678901,
+		Tags:   []string{"a", "b"},
+		Attrs:  map[string]string{"x": "1", "y": "2", "z": "3"},
+	}
+
+	for _, bodyType := range []uint16{AssetBodyTypeMessagePack, AssetBodyTypeJSON, AssetBodyTypeCBOR} {
+		txobj, err := NewTransactionBuilder(defaultIDLength).
+			Event(assetGroupID).Asset(u1).BodyObjectWithType(bodyType, record).Approver(u1).
+			Build()
+		if err != nil {
+			t.Fatalf("type %d: failed to build (%v)", bodyType, err)
+		}
+		dat, err := txobj.Pack()
+		if err != nil {
+			t.Fatalf("type %d: failed to pack (%v)", bodyType, err)
+		}
+		txobj2 := BBcTransaction{}
+		if err := txobj2.Unpack(&dat); err != nil {
+			t.Fatalf("type %d: failed to unpack (%v)", bodyType, err)
+		}
+		asset := txobj2.Events[0].Asset
+		if asset.AssetBodyType != bodyType {
+			t.Fatalf("type %d: AssetBodyType is %d", bodyType, asset.AssetBodyType)
+		}
+		var decoded bodyCodecTestRecord
+		if err := asset.DecodeBody(&decoded); err != nil {
+			t.Fatalf("type %d: failed to decode (%v)", bodyType, err)
+		}
+		if decoded.Name != record.Name || decoded.Amount != record.Amount || len(decoded.Tags) != 2 || decoded.Attrs["z"] != "3" {
+			t.Fatalf("type %d: decoded body differs (%+v)", bodyType, decoded)
+		}
+		obj, err := asset.GetBodyObject()
+		if err != nil || obj == nil {
+			t.Fatalf("type %d: failed to get the body object (%v)", bodyType, err)
+		}
+
+		// asset body is made from asset_body if asset_body_raw is missing (JSON numbers are encoded as float)
+		jdat, err := json.Marshal(asset)
+		if err != nil {
+			t.Fatalf("type %d: failed to marshal (%v)", bodyType, err)
+		}
+		var j map[string]interface{}
+		json.Unmarshal(jdat, &j)
+		if body, ok := j["asset_body"].(map[string]interface{}); !ok || body["name"] != "sample" {
+			t.Fatalf("type %d: asset_body is not a JSON object (%s)", bodyType, jdat)
+		}
+		delete(j, "asset_body_raw")
+		jdat, _ = json.Marshal(j)
+		asset2 := BBcAsset{}
+		if err := json.Unmarshal(jdat, &asset2); err != nil {
+			t.Fatalf("type %d: failed to unmarshal (%v)", bodyType, err)
+		}
+		obj2, _ := asset2.GetBodyObject()
+		if values, ok := jsonCompatible(obj2).(map[string]interface{}); asset2.AssetBodyType != bodyType || !ok || values["name"] != "sample" {
+			t.Fatalf("type %d: asset body is not made from asset_body", bodyType)
+		}
+	}
+
+	t.Run("deterministic cbor", func(t *testing.T) {
+		var digest []byte
+		for i := 0; i < 10; i++ {
+			obj := BBcAsset{IDLength: defaultIDLength}
+			obj.Add(&u1)
+			obj.Nonce = GetIdentifier("fixed nonce", defaultIDLength)
+			if err := obj.AddBodyObjectWithType(AssetBodyTypeCBOR, record.Attrs); err != nil {
+				t.Fatalf("failed to add body (%v)", err)
+			}
+			if d := obj.Digest(); digest != nil && !bytes.Equal(d, digest) {
+				t.Fatal("AssetID changes with the same object")
+			} else {
+				digest = d
+			}
+		}
+	})
+
+	t.Run("protobuf", func(t *testing.T) {
+		msg, _ := structpb.NewStruct(map[string]interface{}{"name": "sample", "amount": 100})
+		obj := BBcAsset{IDLength: defaultIDLength}
+		obj.Add(&u1)
+		if err := obj.AddBodyObjectWithType(AssetBodyTypeProtobuf, msg); err != nil {
+			t.Fatalf("failed to add body (%v)", err)
+		}
+		decoded := &structpb.Struct{}
+		if err := obj.DecodeBody(decoded); err != nil {
+			t.Fatalf("failed to decode (%v)", err)
+		}
+		if !proto.Equal(msg, decoded) {
+			t.Fatal("decoded message differs")
+		}
+		if _, err := obj.GetBodyObject(); err == nil {
+			t.Fatal("protobuf body cannot be decoded without a message")
+		}
+		if err := obj.AddBodyObjectWithType(AssetBodyTypeProtobuf, record); err == nil {
+			t.Fatal("non proto.Message must be rejected")
+		}
+	})
+
+	t.Run("string and unknown types", func(t *testing.T) {
+		obj := BBcAsset{IDLength: defaultIDLength}
+		obj.AddBodyString("plain string")
+		if body, err := obj.GetBodyObject(); body != nil || err != nil {
+			t.Fatalf("string body is not an object (%v, %v)", body, err)
+		}
+		var s string
+		if err := obj.DecodeBody(&s); err != nil || s != "plain string" {
+			t.Fatalf("failed to decode string body (%v)", err)
+		}
+		if err := obj.DecodeBody(&record); err == nil {
+			t.Fatal("string body cannot be decoded into a struct")
+		}
+		if err := obj.AddBodyObjectWithType(AssetBodyTypeString, "abc"); err == nil {
+			t.Fatal("AddBodyObjectWithType must reject the string type")
+		}
+
+		obj.AssetBodyType = 100
+		var unsupported *UnsupportedAssetBodyTypeError
+		if _, err := obj.GetBodyObject(); !errors.As(err, &unsupported) || unsupported.AssetBodyType != 100 {
+			t.Fatalf("UnsupportedAssetBodyTypeError is expected (%v)", err)
+		}
+		if err := obj.AddBodyObjectWithType(100, "abc"); !errors.As(err, &unsupported) {
+			t.Fatalf("UnsupportedAssetBodyTypeError is expected (%v)", err)
+		}
+	})
+
+	t.Run("registry", func(t *testing.T) {
+		RegisterAssetBodyCodec(100, reverseBodyCodec{})
+		defer RegisterAssetBodyCodec(100, nil)
+		RegisterAssetBodyCodec(AssetBodyTypeString, reverseBodyCodec{})
+		if GetAssetBodyCodec(AssetBodyTypeString) != nil {
+			t.Fatal("string type must not be replaced")
+		}
+
+		obj := BBcAsset{IDLength: defaultIDLength}
+		if err := obj.AddBodyObjectWithType(100, "abcdef"); err != nil {
+			t.Fatalf("failed to add body (%v)", err)
+		}
+		if string(obj.AssetBody) != "fedcba" {
+			t.Fatalf("custom codec is not used (%s)", obj.AssetBody)
+		}
+		if body, err := obj.GetBodyObject(); err != nil || body != "abcdef" {
+			t.Fatalf("failed to decode by the custom codec (%v, %v)", body, err)
+		}
+	})
+}
@@ -0,0 +1,474 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+TransactionBuilder definition
+
+TransactionBuilder makes a BBcTransaction object with method chaining, for example:
+
+	txobj, err := NewTransactionBuilder(32).
+		Event(assetGroupID).Asset(userID).BodyString("body").Approver(userID).
+		Relation(assetGroupID).Asset(userID).BodyObject(obj).Pointer(refTxID, refAssetID).
+		Witness(userID).
+		Build()
+
+Event, Relation and Reference append a new object to the transaction and select it as the target of the following methods.
+EventAt and RelationAt select an existing object by index instead.
+Asset, BodyString, BodyObject and File work on the asset of the selected BBcEvent or BBcRelation, Approver and OptionApprovers on the selected BBcEvent,
+Pointer on the selected BBcRelation, and ReferenceApprover on the selected BBcReference.
+
+An error in a method does not stop the chain. The errors are collected and returned by Err() or Build() as BuildErrors.
+Build() also checks the transaction by Validate() and calculates the TransactionID.
+The builder is consumed by a successful Build(), i.e., the methods called after that do not modify the transaction and record errors,
+because the TransactionID of the returned transaction would be stale.
+*/
+type (
+	TransactionBuilder struct {
+		tx        *BBcTransaction
+		event     *BBcEvent
+		relation  *BBcRelation
+		reference *BBcReference
+		asset     *BBcAsset
+		errs      BuildErrors
+		built     bool
+	}
+
+	// BuildErrors lists the errors occurred in the method chain of TransactionBuilder
+	BuildErrors []error
+)
+
+func (e BuildErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].Error()
+	}
+	return fmt.Sprintf("%d error(s) in building the transaction: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// NewTransactionBuilder returns a TransactionBuilder for a new transaction with the given length of IDs
+func NewTransactionBuilder(idLength int) *TransactionBuilder {
+	b := &TransactionBuilder{tx: &BBcTransaction{Version: DefaultTransactionVersion, IDLength: idLength}}
+	b.tx.Timestamp = time.Now().UnixNano() / int64(time.Microsecond)
+	if idLength < 1 || idLength > 32 {
+		b.addError("NewTransactionBuilder", "idLength must be between 1 and 32 but %d", idLength)
+	}
+	return b
+}
+
+// NewTransactionBuilderFrom returns a TransactionBuilder to add objects to the existing transaction
+func NewTransactionBuilderFrom(transaction *BBcTransaction) *TransactionBuilder {
+	b := &TransactionBuilder{tx: transaction}
+	if transaction == nil {
+		b.addError("NewTransactionBuilderFrom", "transaction is nil")
+	}
+	return b
+}
+
+// active returns true if the methods can work on the transaction, and records an error if the transaction has already been built
+func (b *TransactionBuilder) active(method string) bool {
+	if b.built {
+		b.addError(method, "the transaction has already been built")
+		return false
+	}
+	return b.tx != nil
+}
+
+func (b *TransactionBuilder) addError(method string, format string, args ...interface{}) {
+	b.errs = append(b.errs, fmt.Errorf("%s: %s", method, fmt.Sprintf(format, args...)))
+}
+
+// checkID records an error if id is shorter than IDLength of the transaction (nil is allowed if optional)
+func (b *TransactionBuilder) checkID(method string, name string, id []byte, optional bool) bool {
+	if id == nil && optional {
+		return true
+	}
+	if len(id) < b.tx.IDLength {
+		b.addError(method, "%s must be at least %d bytes but %d", name, b.tx.IDLength, len(id))
+		return false
+	}
+	return true
+}
+
+// selectObject sets the target of the following methods
+func (b *TransactionBuilder) selectObject(evt *BBcEvent, rtn *BBcRelation, ref *BBcReference) {
+	b.event, b.relation, b.reference, b.asset = evt, rtn, ref, nil
+	if evt != nil {
+		b.asset = evt.Asset
+	}
+	if rtn != nil {
+		b.asset = rtn.Asset
+	}
+}
+
+// Version sets the header version of the transaction
+func (b *TransactionBuilder) Version(version uint32) *TransactionBuilder {
+	if !b.active("Version") {
+		return b
+	}
+	if !CanWriteVersion(version) {
+		b.addError("Version", "version=%d is not supported", version)
+		return b
+	}
+	b.tx.Version = version
+	return b
+}
+
+// Timestamp sets the timestamp of the transaction
+func (b *TransactionBuilder) Timestamp(timestamp int64) *TransactionBuilder {
+	if b.active("Timestamp") {
+		b.tx.Timestamp = timestamp
+	}
+	return b
+}
+
+// Event appends a BBcEvent object with the assetGroupID (nil for an empty BBcEvent) and selects it
+func (b *TransactionBuilder) Event(assetGroupID []byte) *TransactionBuilder {
+	if !b.active("Event") {
+		return b
+	}
+	evt := &BBcEvent{}
+	b.tx.AddEvent(evt)
+	b.selectObject(evt, nil, nil)
+	return b.AssetGroup(assetGroupID)
+}
+
+// EventAt selects the existing BBcEvent object in the transaction
+func (b *TransactionBuilder) EventAt(eventIdx int) *TransactionBuilder {
+	if !b.active("EventAt") {
+		return b
+	}
+	if eventIdx < 0 || eventIdx >= len(b.tx.Events) || b.tx.Events[eventIdx] == nil {
+		b.addError("EventAt", "no event at index %d", eventIdx)
+		b.selectObject(nil, nil, nil)
+		return b
+	}
+	b.selectObject(b.tx.Events[eventIdx], nil, nil)
+	return b
+}
+
+// Relation appends a BBcRelation object with the assetGroupID (nil for an empty BBcRelation) and selects it
+func (b *TransactionBuilder) Relation(assetGroupID []byte) *TransactionBuilder {
+	if !b.active("Relation") {
+		return b
+	}
+	rtn := &BBcRelation{}
+	b.tx.AddRelation(rtn)
+	b.selectObject(nil, rtn, nil)
+	return b.AssetGroup(assetGroupID)
+}
+
+// RelationAt selects the existing BBcRelation object in the transaction
+func (b *TransactionBuilder) RelationAt(relationIdx int) *TransactionBuilder {
+	if !b.active("RelationAt") {
+		return b
+	}
+	if relationIdx < 0 || relationIdx >= len(b.tx.Relations) || b.tx.Relations[relationIdx] == nil {
+		b.addError("RelationAt", "no relation at index %d", relationIdx)
+		b.selectObject(nil, nil, nil)
+		return b
+	}
+	b.selectObject(nil, b.tx.Relations[relationIdx], nil)
+	return b
+}
+
+// AssetGroup sets the assetGroupID to the selected BBcEvent or BBcRelation (nil is ignored)
+func (b *TransactionBuilder) AssetGroup(assetGroupID []byte) *TransactionBuilder {
+	if !b.active("AssetGroup") || assetGroupID == nil {
+		return b
+	}
+	if !b.checkID("AssetGroup", "assetGroupID", assetGroupID, false) {
+		return b
+	}
+	switch {
+	case b.event != nil:
+		b.event.Add(&assetGroupID, nil)
+	case b.relation != nil:
+		b.relation.Add(&assetGroupID, nil)
+	default:
+		b.addError("AssetGroup", "no event or relation is selected")
+	}
+	return b
+}
+
+// Asset sets a new BBcAsset object of the userID to the selected BBcEvent or BBcRelation
+func (b *TransactionBuilder) Asset(userID []byte) *TransactionBuilder {
+	if !b.active("Asset") {
+		return b
+	}
+	if b.event == nil && b.relation == nil {
+		b.addError("Asset", "no event or relation is selected")
+		return b
+	}
+	if !b.checkID("Asset", "userID", userID, false) {
+		return b
+	}
+	ast := &BBcAsset{}
+	if b.event != nil {
+		b.event.Add(nil, ast)
+	} else {
+		b.relation.Add(nil, ast)
+	}
+	ast.Add(&userID)
+	b.asset = ast
+	return b
+}
+
+// BodyString sets a string to the selected asset
+func (b *TransactionBuilder) BodyString(body string) *TransactionBuilder {
+	if !b.active("BodyString") {
+		return b
+	}
+	if b.asset == nil {
+		b.addError("BodyString", "no asset is selected")
+		return b
+	}
+	b.asset.AddBodyString(body)
+	return b
+}
+
+// BodyObject sets an object to the selected asset in MessagePack format
+func (b *TransactionBuilder) BodyObject(body interface{}) *TransactionBuilder {
+	if !b.active("BodyObject") {
+		return b
+	}
+	if b.asset == nil {
+		b.addError("BodyObject", "no asset is selected")
+		return b
+	}
+	if err := b.asset.AddBodyObject(body); err != nil {
+		b.addError("BodyObject", "%v", err)
+	}
+	return b
+}
+
+// BodyObjectWithType sets an object to the selected asset encoded by the AssetBodyCodec of the bodyType
+func (b *TransactionBuilder) BodyObjectWithType(bodyType uint16, body interface{}) *TransactionBuilder {
+	if !b.active("BodyObjectWithType") {
+		return b
+	}
+	if b.asset == nil {
+		b.addError("BodyObjectWithType", "no asset is selected")
+		return b
+	}
+	if err := b.asset.AddBodyObjectWithType(bodyType, body); err != nil {
+		b.addError("BodyObjectWithType", "%v", err)
+	}
+	return b
+}
+
+// File sets the digest of the file content to the selected asset
+func (b *TransactionBuilder) File(content []byte) *TransactionBuilder {
+	if !b.active("File") {
+		return b
+	}
+	if b.asset == nil {
+		b.addError("File", "no asset is selected")
+		return b
+	}
+	b.asset.AddFile(&content)
+	return b
+}
+
+// Approver adds the userIDs to MandatoryApprovers of the selected BBcEvent
+func (b *TransactionBuilder) Approver(userIDs ...[]byte) *TransactionBuilder {
+	if !b.active("Approver") {
+		return b
+	}
+	if b.event == nil {
+		b.addError("Approver", "no event is selected")
+		return b
+	}
+	for i := range userIDs {
+		if b.checkID("Approver", "userID", userIDs[i], false) {
+			b.event.AddMandatoryApprover(&userIDs[i])
+		}
+	}
+	return b
+}
+
+// OptionApprovers sets the userIDs to OptionApprovers of the selected BBcEvent, and numerator of them are required to approve
+func (b *TransactionBuilder) OptionApprovers(numerator int, userIDs ...[]byte) *TransactionBuilder {
+	if !b.active("OptionApprovers") {
+		return b
+	}
+	if b.event == nil {
+		b.addError("OptionApprovers", "no event is selected")
+		return b
+	}
+	if numerator < 0 || numerator > len(userIDs) {
+		b.addError("OptionApprovers", "numerator must be between 0 and %d but %d", len(userIDs), numerator)
+		return b
+	}
+	for i := range userIDs {
+		if !b.checkID("OptionApprovers", "userID", userIDs[i], false) {
+			return b
+		}
+	}
+	b.event.OptionApprovers = nil
+	for i := range userIDs {
+		b.event.AddOptionApprover(&userIDs[i])
+	}
+	b.event.AddOptionParams(numerator, len(userIDs))
+	return b
+}
+
+// Reference appends a BBcReference object to the event at eventIdx in refTransaction and selects it
+func (b *TransactionBuilder) Reference(assetGroupID []byte, refTransaction *BBcTransaction, eventIdx int) *TransactionBuilder {
+	if !b.active("Reference") {
+		return b
+	}
+	if refTransaction == nil {
+		b.addError("Reference", "refTransaction is nil")
+		return b
+	}
+	if !b.checkID("Reference", "assetGroupID", assetGroupID, false) {
+		return b
+	}
+	if refTransaction.TransactionID == nil {
+		refTransaction.Digest()
+	}
+	if len(refTransaction.TransactionID) < b.tx.IDLength {
+		b.addError("Reference", "TransactionID of refTransaction must be at least %d bytes but %d", b.tx.IDLength, len(refTransaction.TransactionID))
+		return b
+	}
+	if eventIdx < 0 || eventIdx >= len(refTransaction.Events) {
+		b.addError("Reference", "no event at index %d in refTransaction", eventIdx)
+		return b
+	}
+	ref := &BBcReference{}
+	b.tx.AddReference(ref)
+	ref.Add(&assetGroupID, refTransaction, eventIdx)
+	b.selectObject(nil, nil, ref)
+	return b
+}
+
+// ReferenceApprover adds the userIDs as the approvers who sign the selected BBcReference
+func (b *TransactionBuilder) ReferenceApprover(userIDs ...[]byte) *TransactionBuilder {
+	if !b.active("ReferenceApprover") {
+		return b
+	}
+	if b.reference == nil {
+		b.addError("ReferenceApprover", "no reference is selected")
+		return b
+	}
+	for i := range userIDs {
+		if err := b.reference.AddApprover(&userIDs[i]); err != nil {
+			b.addError("ReferenceApprover", "%v", err)
+		}
+	}
+	return b
+}
+
+// Pointer appends a BBcPointer object to the selected BBcRelation (refAssetID can be nil)
+func (b *TransactionBuilder) Pointer(refTransactionID, refAssetID []byte) *TransactionBuilder {
+	if !b.active("Pointer") {
+		return b
+	}
+	if b.relation == nil {
+		b.addError("Pointer", "no relation is selected")
+		return b
+	}
+	if !b.checkID("Pointer", "refTransactionID", refTransactionID, false) || !b.checkID("Pointer", "refAssetID", refAssetID, true) {
+		return b
+	}
+	ptr := &BBcPointer{}
+	b.relation.AddPointer(ptr)
+	if refAssetID == nil {
+		ptr.Add(&refTransactionID, nil)
+	} else {
+		ptr.Add(&refTransactionID, &refAssetID)
+	}
+	return b
+}
+
+// Witness adds the userIDs to the BBcWitness object (created if the transaction does not have it)
+func (b *TransactionBuilder) Witness(userIDs ...[]byte) *TransactionBuilder {
+	if !b.active("Witness") {
+		return b
+	}
+	if b.tx.Witness == nil {
+		b.tx.AddWitness(&BBcWitness{})
+	}
+	for i := range userIDs {
+		if !b.checkID("Witness", "userID", userIDs[i], false) {
+			continue
+		}
+		if err := b.tx.Witness.AddWitness(&userIDs[i]); err != nil {
+			b.addError("Witness", "%v", err)
+		}
+	}
+	return b
+}
+
+// CrossRef sets a BBcCrossRef object with the domainID and the transactionID in the domain
+func (b *TransactionBuilder) CrossRef(domainID, transactionID []byte) *TransactionBuilder {
+	if !b.active("CrossRef") {
+		return b
+	}
+	if len(domainID) != DomainIDLength {
+		b.addError("CrossRef", "domainID must be %d bytes but %d", DomainIDLength, len(domainID))
+		return b
+	}
+	if !b.checkID("CrossRef", "transactionID", transactionID, false) {
+		return b
+	}
+	crs := &BBcCrossRef{}
+	b.tx.AddCrossRef(crs)
+	crs.Add(&domainID, &transactionID)
+	return b
+}
+
+// Extension adds an extension to the header (version 2 or later is required)
+func (b *TransactionBuilder) Extension(extType uint16, data []byte) *TransactionBuilder {
+	if b.active("Extension") {
+		b.tx.AddExtension(extType, data)
+	}
+	return b
+}
+
+// Err returns the errors occurred so far in the method chain (nil if no error)
+func (b *TransactionBuilder) Err() error {
+	if len(b.errs) == 0 {
+		return nil
+	}
+	return b.errs
+}
+
+// Build checks the transaction and returns it with the calculated TransactionID (the builder cannot be used after that)
+func (b *TransactionBuilder) Build() (*BBcTransaction, error) {
+	if !b.active("Build") {
+		return nil, b.Err()
+	}
+	if err := b.Err(); err != nil {
+		return nil, err
+	}
+	if err := b.tx.Validate(); err != nil {
+		return nil, err
+	}
+	if b.tx.Digest() == nil {
+		return nil, fmt.Errorf("failed to calculate TransactionID")
+	}
+	b.built = true
+	return b.tx, nil
+}
@@ -0,0 +1,173 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTransactionBuilder(t *testing.T) {
+	assetGroupID := GetIdentifier("asset_group_id_builder", defaultIDLength)
+	u1 := GetIdentifier("user1_789abcdef0123456789abcdef0", defaultIDLength)
+	u2 := GetIdentifier("user2_789abcdef0123456789abcdef0", defaultIDLength)
+	keypair := GenerateKeypair(KeyTypeEcdsaP256v1, defaultCompressionMode)
+
+	reftx, err := NewTransactionBuilder(defaultIDLength).
+		Event(assetGroupID).Asset(u1).BodyString("reftx").Approver(u1).
+		Witness(u1).
+		Build()
+	if err != nil {
+		t.Fatalf("failed to build (%v)", err)
+	}
+	SignToTransaction(reftx, &u1, &keypair)
+
+	txobj, err := NewTransactionBuilder(defaultIDLength).
+		Event(assetGroupID).Asset(u2).BodyObject(map[string]string{"param1": "aaa"}).OptionApprovers(1, u1, u2).
+		Reference(assetGroupID, reftx, 0).ReferenceApprover(u1).
+		Relation(assetGroupID).Asset(u2).File([]byte("file content")).Pointer(reftx.TransactionID, reftx.Events[0].Asset.AssetID).
+		Relation(assetGroupID).Pointer(reftx.TransactionID, nil).
+		Witness(u2).
+		Build()
+	if err != nil {
+		t.Fatalf("failed to build (%v)", err)
+	}
+	if len(txobj.Events) != 1 || len(txobj.References) != 1 || len(txobj.Relations) != 2 || txobj.Witness == nil {
+		t.Fatalf("unexpected structure\n%s", txobj.Stringer())
+	}
+	if txobj.Events[0].OptionApproverNumNumerator != 1 || txobj.Events[0].OptionApproverNumDenominator != 2 {
+		t.Fatal("option params are not set")
+	}
+	if txobj.Relations[1].Asset != nil || txobj.Relations[1].Pointers[0].AssetID != nil {
+		t.Fatal("relation without asset is not built correctly")
+	}
+	if len(txobj.Signatures) != 2 {
+		t.Fatalf("signature slots for u1 and u2 are expected but %d", len(txobj.Signatures))
+	}
+	SignToTransaction(txobj, &u1, &keypair)
+	SignToTransaction(txobj, &u2, &keypair)
+
+	dat, err := Serialize(txobj, FormatZlib)
+	if err != nil {
+		t.Fatalf("failed to serialize (%v)", err)
+	}
+	obj, err := Deserialize(dat)
+	if err != nil {
+		t.Fatalf("failed to deserialize (%v)", err)
+	}
+	if !bytes.Equal(obj.TransactionID, txobj.TransactionID) {
+		t.Fatal("TransactionID mismatch")
+	}
+	if ret, _ := obj.VerifyAll(); !ret {
+		t.Fatal("failed to verify")
+	}
+}
+
+func TestTransactionBuilderErrors(t *testing.T) {
+	assetGroupID := GetIdentifier("asset_group_id_builder", defaultIDLength)
+	u1 := GetIdentifier("user1_789abcdef0123456789abcdef0", defaultIDLength)
+
+	t.Run("errors are collected", func(t *testing.T) {
+		b := NewTransactionBuilder(defaultIDLength).
+			Asset(u1).
+			Event(assetGroupID).Pointer(u1, nil).
+			Relation(assetGroupID).Approver(u1).
+			Event(assetGroupID).Asset([]byte("short"))
+		err := b.Err()
+		errs, ok := err.(BuildErrors)
+		if !ok || len(errs) != 4 {
+			t.Fatalf("4 errors are expected (%v)", err)
+		}
+		if _, err := b.Build(); err == nil {
+			t.Fatal("Build must fail")
+		}
+		t.Log(err)
+	})
+
+	t.Run("validation error", func(t *testing.T) {
+		_, err := NewTransactionBuilder(defaultIDLength).Event(nil).Build()
+		if _, ok := err.(ValidationErrors); !ok {
+			t.Fatalf("ValidationErrors is expected (%v)", err)
+		}
+	})
+
+	t.Run("consumed by Build", func(t *testing.T) {
+		b := NewTransactionBuilder(defaultIDLength).Event(assetGroupID).Asset(u1).BodyString("body").Approver(u1)
+		txobj, err := b.Build()
+		if err != nil {
+			t.Fatalf("failed to build (%v)", err)
+		}
+		txid := append([]byte{}, txobj.TransactionID...)
+		packed, _ := txobj.Pack()
+
+		b.BodyString("modified").Approver(u1).Event(assetGroupID).Witness(u1)
+		errs, ok := b.Err().(BuildErrors)
+		if !ok || len(errs) != 4 {
+			t.Fatalf("4 errors are expected (%v)", b.Err())
+		}
+		if _, err := b.Build(); err == nil {
+			t.Fatal("Build must fail after the transaction has been built")
+		}
+		dat, _ := txobj.Pack()
+		if !bytes.Equal(dat, packed) || txobj.Digest() == nil || !bytes.Equal(txobj.TransactionID, txid) {
+			t.Fatal("the built transaction must not be modified")
+		}
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		if err := NewTransactionBuilder(0).Err(); err == nil {
+			t.Fatal("idLength=0 must be rejected")
+		}
+		if err := NewTransactionBuilder(defaultIDLength).Version(99).Err(); err == nil {
+			t.Fatal("unsupported version must be rejected")
+		}
+		if err := NewTransactionBuilder(defaultIDLength).Event(assetGroupID).OptionApprovers(2, u1).Err(); err == nil {
+			t.Fatal("numerator larger than the number of approvers must be rejected")
+		}
+		if err := NewTransactionBuilder(defaultIDLength).Reference(assetGroupID, nil, 0).Err(); err == nil {
+			t.Fatal("nil refTransaction must be rejected")
+		}
+		if err := NewTransactionBuilder(defaultIDLength).CrossRef([]byte("short"), u1).Err(); err == nil {
+			t.Fatal("short domainID must be rejected")
+		}
+	})
+}
+
+func TestHelpersReturnErrors(t *testing.T) {
+	assetGroupID := GetIdentifier("asset_group_id_builder", defaultIDLength)
+	u1 := GetIdentifier("user1_789abcdef0123456789abcdef0", defaultIDLength)
+
+	if err := AddEventAssetBodyString(nil, 0, &assetGroupID, &u1, "body"); err == nil {
+		t.Fatal("nil transaction must be reported")
+	}
+	txobj := MakeTransaction(1, 1, false, defaultIDLength)
+	if err := AddEventAssetBodyObject(txobj, 1, &assetGroupID, &u1, "body"); err == nil {
+		t.Fatal("out of range index must be reported")
+	}
+	if err := AddRelationAssetBodyObject(txobj, 0, &assetGroupID, nil, "body"); err == nil {
+		t.Fatal("nil userID must be reported")
+	}
+	if err := AddRelationPointer(txobj, 3, &u1, nil); err == nil {
+		t.Fatal("out of range index must be reported")
+	}
+	if err := AddReference(txobj, &assetGroupID, txobj, 5); err == nil {
+		t.Fatal("out of range event index must be reported")
+	}
+	if err := AddEventAssetFile(txobj, 0, &assetGroupID, &u1, &u1); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+}
@@ -0,0 +1,238 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+/*
+Deep copy
+
+Clone() of BBcTransaction and the component objects returns a deep copy which shares no slice with the original.
+In the copy of BBcTransaction, the back-pointers (Transaction in BBcReference and BBcWitness) are rewired to the copy itself.
+When a component object is cloned alone, the back-pointer is kept as it is because the parent transaction is not copied.
+RefTransaction in BBcReference is not copied either since it is another transaction, though RefEvent is copied.
+*/
+
+// cloneBytes returns a copy of the byte slice (nil is kept)
+func cloneBytes(dat []byte) []byte {
+	if dat == nil {
+		return nil
+	}
+	ret := make([]byte, len(dat))
+	copy(ret, dat)
+	return ret
+}
+
+// cloneIDs returns a copy of the list of IDs (nil is kept)
+func cloneIDs(ids [][]byte) [][]byte {
+	if ids == nil {
+		return nil
+	}
+	ret := make([][]byte, len(ids))
+	for i := range ids {
+		ret[i] = cloneBytes(ids[i])
+	}
+	return ret
+}
+
+// cloneInts returns a copy of the int slice (nil is kept)
+func cloneInts(vals []int) []int {
+	if vals == nil {
+		return nil
+	}
+	ret := make([]int, len(vals))
+	copy(ret, vals)
+	return ret
+}
+
+// Clone returns a deep copy of the BBcAsset object
+func (p *BBcAsset) Clone() *BBcAsset {
+	if p == nil {
+		return nil
+	}
+	return &BBcAsset{
+		IDLength:        p.IDLength,
+		AssetID:         cloneBytes(p.AssetID),
+		UserID:          cloneBytes(p.UserID),
+		Nonce:           cloneBytes(p.Nonce),
+		AssetFileSize:   p.AssetFileSize,
+		AssetFileDigest: cloneBytes(p.AssetFileDigest),
+		AssetBodyType:   p.AssetBodyType,
+		AssetBodySize:   p.AssetBodySize,
+		AssetBody:       cloneBytes(p.AssetBody),
+		form:            p.form,
+	}
+}
+
+// Clone returns a deep copy of the BBcEvent object
+func (p *BBcEvent) Clone() *BBcEvent {
+	if p == nil {
+		return nil
+	}
+	return &BBcEvent{
+		IDLength:                     p.IDLength,
+		AssetGroupID:                 cloneBytes(p.AssetGroupID),
+		ReferenceIndices:             cloneInts(p.ReferenceIndices),
+		MandatoryApprovers:           cloneIDs(p.MandatoryApprovers),
+		OptionApproverNumNumerator:   p.OptionApproverNumNumerator,
+		OptionApproverNumDenominator: p.OptionApproverNumDenominator,
+		OptionApprovers:              cloneIDs(p.OptionApprovers),
+		Asset:                        p.Asset.Clone(),
+		assetForm:                    p.assetForm,
+	}
+}
+
+// Clone returns a deep copy of the BBcReference object (Transaction and RefTransaction refer to the same objects as the original)
+func (p *BBcReference) Clone() *BBcReference {
+	if p == nil {
+		return nil
+	}
+	return &BBcReference{
+		IDLength:        p.IDLength,
+		AssetGroupID:    cloneBytes(p.AssetGroupID),
+		TransactionID:   cloneBytes(p.TransactionID),
+		EventIndexInRef: p.EventIndexInRef,
+		SigIndices:      cloneInts(p.SigIndices),
+		Transaction:     p.Transaction,
+		RefTransaction:  p.RefTransaction,
+		RefEvent:        *p.RefEvent.Clone(),
+	}
+}
+
+// Clone returns a deep copy of the BBcPointer object
+func (p *BBcPointer) Clone() *BBcPointer {
+	if p == nil {
+		return nil
+	}
+	return &BBcPointer{
+		IDLength:      p.IDLength,
+		TransactionID: cloneBytes(p.TransactionID),
+		AssetID:       cloneBytes(p.AssetID),
+	}
+}
+
+// Clone returns a deep copy of the BBcRelation object
+func (p *BBcRelation) Clone() *BBcRelation {
+	if p == nil {
+		return nil
+	}
+	ret := BBcRelation{
+		IDLength:     p.IDLength,
+		AssetGroupID: cloneBytes(p.AssetGroupID),
+		Asset:        p.Asset.Clone(),
+		assetForm:    p.assetForm,
+	}
+	if p.Pointers != nil {
+		ret.Pointers = make([]*BBcPointer, len(p.Pointers))
+		for i := range p.Pointers {
+			ret.Pointers[i] = p.Pointers[i].Clone()
+		}
+	}
+	return &ret
+}
+
+// Clone returns a deep copy of the BBcWitness object (Transaction refers to the same object as the original)
+func (p *BBcWitness) Clone() *BBcWitness {
+	if p == nil {
+		return nil
+	}
+	return &BBcWitness{
+		IDLength:    p.IDLength,
+		UserIDs:     cloneIDs(p.UserIDs),
+		SigIndices:  cloneInts(p.SigIndices),
+		Transaction: p.Transaction,
+	}
+}
+
+// Clone returns a deep copy of the BBcCrossRef object
+func (p *BBcCrossRef) Clone() *BBcCrossRef {
+	if p == nil {
+		return nil
+	}
+	return &BBcCrossRef{
+		IDLength:      p.IDLength,
+		DomainID:      cloneBytes(p.DomainID),
+		TransactionID: cloneBytes(p.TransactionID),
+	}
+}
+
+// Clone returns a deep copy of the BBcSignature object
+func (p *BBcSignature) Clone() *BBcSignature {
+	if p == nil {
+		return nil
+	}
+	return &BBcSignature{
+		KeyType:      p.KeyType,
+		Pubkey:       cloneBytes(p.Pubkey),
+		PubkeyLen:    p.PubkeyLen,
+		Signature:    cloneBytes(p.Signature),
+		SignatureLen: p.SignatureLen,
+	}
+}
+
+// Clone returns a deep copy of the BBcTransaction object, in which BBcReference and BBcWitness objects are linked to the copy
+func (p *BBcTransaction) Clone() *BBcTransaction {
+	if p == nil {
+		return nil
+	}
+	ret := BBcTransaction{
+		TransactionID:         cloneBytes(p.TransactionID),
+		TransactionBaseDigest: cloneBytes(p.TransactionBaseDigest),
+		TransactionData:       cloneBytes(p.TransactionData),
+		SigIndices:            cloneIDs(p.SigIndices),
+		Version:               p.Version,
+		Timestamp:             p.Timestamp,
+		IDLength:              p.IDLength,
+		Witness:               p.Witness.Clone(),
+		Crossref:              p.Crossref.Clone(),
+	}
+	if p.Extensions != nil {
+		ret.Extensions = make([]BBcExtension, len(p.Extensions))
+		for i, ext := range p.Extensions {
+			ret.Extensions[i] = BBcExtension{Type: ext.Type, Data: cloneBytes(ext.Data)}
+		}
+	}
+	if p.Events != nil {
+		ret.Events = make([]*BBcEvent, len(p.Events))
+		for i := range p.Events {
+			ret.Events[i] = p.Events[i].Clone()
+		}
+	}
+	if p.References != nil {
+		ret.References = make([]*BBcReference, len(p.References))
+		for i := range p.References {
+			ret.References[i] = p.References[i].Clone()
+			if ret.References[i] != nil {
+				ret.References[i].Transaction = &ret
+			}
+		}
+	}
+	if p.Relations != nil {
+		ret.Relations = make([]*BBcRelation, len(p.Relations))
+		for i := range p.Relations {
+			ret.Relations[i] = p.Relations[i].Clone()
+		}
+	}
+	if ret.Witness != nil {
+		ret.Witness.Transaction = &ret
+	}
+	if p.Signatures != nil {
+		ret.Signatures = make([]*BBcSignature, len(p.Signatures))
+		for i := range p.Signatures {
+			ret.Signatures[i] = p.Signatures[i].Clone()
+		}
+	}
+	return &ret
+}
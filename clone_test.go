@@ -0,0 +1,93 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTransactionClone(t *testing.T) {
+	txobj := makeDecodeTestTransaction(t)
+	cloned := txobj.Clone()
+
+	if !txobj.Equal(cloned) {
+		t.Fatalf("clone differs: %v", txobj.Diff(cloned))
+	}
+	dat1, _ := txobj.Pack()
+	dat2, _ := cloned.Pack()
+	if !bytes.Equal(dat1, dat2) {
+		t.Fatal("packed data of the clone differs")
+	}
+
+	if cloned.Witness.Transaction != cloned || cloned.References[0].Transaction != cloned {
+		t.Fatal("back-pointers must refer to the clone")
+	}
+	if txobj.Witness.Transaction != txobj || txobj.References[0].Transaction != txobj {
+		t.Fatal("back-pointers of the original must not be changed")
+	}
+	if cloned.References[0].RefTransaction != txobj.References[0].RefTransaction {
+		t.Fatal("RefTransaction should refer to the same transaction")
+	}
+
+	cloned.Events[0].Asset.AssetBody[0] ^= 0xff
+	cloned.Relations[0].Pointers[0].TransactionID[0] ^= 0xff
+	cloned.Signatures[0].Signature[0] ^= 0xff
+	cloned.References[0].RefEvent.AssetGroupID[0] ^= 0xff
+	u3 := GetIdentifier("user3_789abcdef0123456789abcdef0", defaultIDLength)
+	cloned.Witness.AddWitness(&u3)
+	if len(cloned.Signatures) != len(txobj.Signatures)+1 || len(txobj.SigIndices) != 2 {
+		t.Fatal("AddWitness on the clone must change only the clone")
+	}
+	dat3, _ := txobj.Pack()
+	if !bytes.Equal(dat1, dat3) {
+		t.Fatal("the original is changed by modifying the clone")
+	}
+	if bytes.Equal(txobj.References[0].RefEvent.AssetGroupID, cloned.References[0].RefEvent.AssetGroupID) {
+		t.Fatal("RefEvent must be copied")
+	}
+
+	var nilTx *BBcTransaction
+	if nilTx.Clone() != nil {
+		t.Fatal("clone of nil must be nil")
+	}
+}
+
+func TestComponentClone(t *testing.T) {
+	txobj := makeDecodeTestTransaction(t)
+
+	wit := txobj.Witness.Clone()
+	if wit.Transaction != txobj || !wit.Equal(txobj.Witness) {
+		t.Fatal("witness clone is wrong")
+	}
+	wit.UserIDs[0][0] ^= 0xff
+	if wit.Equal(txobj.Witness) {
+		t.Fatal("witness clone shares UserIDs")
+	}
+
+	ref := txobj.References[0].Clone()
+	ref.SigIndices[0] = 100
+	if txobj.References[0].SigIndices[0] == 100 {
+		t.Fatal("reference clone shares SigIndices")
+	}
+
+	crs := txobj.Crossref.Clone()
+	crs.DomainID[0] ^= 0xff
+	if crs.Equal(txobj.Crossref) {
+		t.Fatal("crossref clone shares DomainID")
+	}
+}
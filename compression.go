@@ -0,0 +1,225 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"io"
+	"math"
+	"sync"
+)
+
+/*
+Codec definition
+
+A Codec compresses and decompresses the packed transaction in the serialized data. A Codec is registered for each format type (the header value of serialized data),
+and Serialize/Deserialize look up the Codec in the registry according to the format type.
+Codecs for FormatZlib, FormatZstd, FormatLz4 and FormatBrotli are registered by default, and applications can register their own Codecs.
+FormatPlain is not a Codec (the packed data is used as is) and cannot be replaced.
+
+"Decompress" must return DecodeLimitError if the decompressed data exceeds maxSize bytes (0 means no limit).
+"NewReader" returns a reader which decompresses the data read from r, so that Decoder decompresses a frame while reading it from the stream.
+maxSize is a hint to bound the memory of the decompressor, and the caller checks the size of the decompressed data.
+
+UnsupportedFormatError is returned by Serialize/Deserialize if no Codec is registered for the format type.
+*/
+type (
+	Codec interface {
+		Compress(dat []byte) ([]byte, error)
+		Decompress(dat []byte, maxSize int) ([]byte, error)
+		NewReader(r io.Reader, maxSize int) (io.ReadCloser, error)
+	}
+
+	// UnsupportedFormatError reports the unknown format type
+	UnsupportedFormatError struct {
+		FormatType uint16
+	}
+
+	zlibCodec   struct{}
+	zstdCodec   struct{}
+	lz4Codec    struct{}
+	brotliCodec struct{}
+)
+
+// The registry of Codecs (format type -> Codec)
+var (
+	codecLock sync.RWMutex
+	codecs    = make(map[uint16]Codec)
+)
+
+func init() {
+	RegisterCodec(FormatZlib, zlibCodec{})
+	RegisterCodec(FormatZstd, zstdCodec{})
+	RegisterCodec(FormatLz4, lz4Codec{})
+	RegisterCodec(FormatBrotli, brotliCodec{})
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	return fmt.Sprintf("formatType 0x%04x not supported", e.FormatType)
+}
+
+// RegisterCodec sets the Codec for the formatType in the registry (the existing one is replaced, and nil codec removes it)
+func RegisterCodec(formatType uint16, codec Codec) {
+	if formatType == FormatPlain {
+		return
+	}
+	codecLock.Lock()
+	defer codecLock.Unlock()
+	if codec == nil {
+		delete(codecs, formatType)
+		return
+	}
+	codecs[formatType] = codec
+}
+
+// GetCodec returns the Codec for the formatType (nil if not registered)
+func GetCodec(formatType uint16) Codec {
+	codecLock.RLock()
+	defer codecLock.RUnlock()
+	return codecs[formatType]
+}
+
+// readAllWithLimit reads all data from the reader, and returns DecodeLimitError if the data exceeds maxSize bytes (0 means no limit)
+func readAllWithLimit(r io.Reader, maxSize int) ([]byte, error) {
+	var dstbuf bytes.Buffer
+	if maxSize > 0 {
+		r = io.LimitReader(r, int64(maxSize)+1)
+	}
+	if _, err := io.Copy(&dstbuf, r); err != nil {
+		return nil, err
+	}
+	if maxSize > 0 && dstbuf.Len() > maxSize {
+		return nil, &DecodeLimitError{Field: "decompressed size", Value: int64(dstbuf.Len()), Limit: int64(maxSize)}
+	}
+	return dstbuf.Bytes(), nil
+}
+
+// Compress compresses the data in zlib format
+func (zlibCodec) Compress(dat []byte) ([]byte, error) {
+	return ZlibCompress(&dat), nil
+}
+
+// Decompress decompresses the data in zlib format
+func (zlibCodec) Decompress(dat []byte, maxSize int) ([]byte, error) {
+	return ZlibDecompressWithLimit(dat, maxSize)
+}
+
+// NewReader returns a reader which decompresses the data in zlib format
+func (zlibCodec) NewReader(r io.Reader, maxSize int) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+// zstdEncoder is shared because EncodeAll can be called concurrently (zstdEncoderErr keeps the error in creating it)
+var (
+	zstdEncoderOnce sync.Once
+	zstdEncoder     *zstd.Encoder
+	zstdEncoderErr  error
+)
+
+// Compress compresses the data in zstd format
+func (zstdCodec) Compress(dat []byte) ([]byte, error) {
+	zstdEncoderOnce.Do(func() {
+		zstdEncoder, zstdEncoderErr = zstd.NewWriter(nil)
+	})
+	if zstdEncoderErr != nil {
+		return nil, zstdEncoderErr
+	}
+	return zstdEncoder.EncodeAll(dat, nil), nil
+}
+
+// Decompress decompresses the data in zstd format
+//
+// The frame content size in the frame header is checked before decoding, and the memory and the window size of the decoder are capped by maxSize,
+// so that a frame which declares a huge window cannot make the decoder allocate it.
+func (c zstdCodec) Decompress(dat []byte, maxSize int) ([]byte, error) {
+	if maxSize > 0 {
+		var header zstd.Header
+		if err := header.Decode(dat); err == nil && header.HasFCS && header.FrameContentSize > uint64(maxSize) {
+			return nil, &DecodeLimitError{Field: "decompressed size", Value: int64(min(header.FrameContentSize, math.MaxInt64)), Limit: int64(maxSize)}
+		}
+	}
+	reader, err := c.NewReader(bytes.NewReader(dat), maxSize)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return readAllWithLimit(reader, maxSize)
+}
+
+// NewReader returns a reader which decompresses the data in zstd format (the memory and the window size are capped by maxSize)
+func (zstdCodec) NewReader(r io.Reader, maxSize int) (io.ReadCloser, error) {
+	options := []zstd.DOption{zstd.WithDecoderConcurrency(1)}
+	if maxSize > 0 {
+		options = append(options, zstd.WithDecoderMaxMemory(uint64(maxSize)), zstd.WithDecoderMaxWindow(max(uint64(maxSize), zstd.MinWindowSize)))
+	}
+	reader, err := zstd.NewReader(r, options...)
+	if err != nil {
+		return nil, err
+	}
+	return reader.IOReadCloser(), nil
+}
+
+// Compress compresses the data in lz4 frame format
+func (lz4Codec) Compress(dat []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := lz4.NewWriter(&buf)
+	if _, err := writer.Write(dat); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress decompresses the data in lz4 frame format
+func (lz4Codec) Decompress(dat []byte, maxSize int) ([]byte, error) {
+	return readAllWithLimit(lz4.NewReader(bytes.NewReader(dat)), maxSize)
+}
+
+// NewReader returns a reader which decompresses the data in lz4 frame format
+func (lz4Codec) NewReader(r io.Reader, maxSize int) (io.ReadCloser, error) {
+	return io.NopCloser(lz4.NewReader(r)), nil
+}
+
+// Compress compresses the data in brotli format
+func (brotliCodec) Compress(dat []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := brotli.NewWriter(&buf)
+	if _, err := writer.Write(dat); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress decompresses the data in brotli format
+func (brotliCodec) Decompress(dat []byte, maxSize int) ([]byte, error) {
+	return readAllWithLimit(brotli.NewReader(bytes.NewReader(dat)), maxSize)
+}
+
+// NewReader returns a reader which decompresses the data in brotli format
+func (brotliCodec) NewReader(r io.Reader, maxSize int) (io.ReadCloser, error) {
+	return io.NopCloser(brotli.NewReader(r)), nil
+}
@@ -0,0 +1,203 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"testing"
+)
+
+var compressionFormats = []struct {
+	name       string
+	formatType uint16
+}{
+	{"plain", FormatPlain},
+	{"zlib", FormatZlib},
+	{"zstd", FormatZstd},
+	{"lz4", FormatLz4},
+	{"brotli", FormatBrotli},
+}
+
+// xorCodec is a Codec for testing
+type xorCodec struct{}
+
+func (xorCodec) Compress(dat []byte) ([]byte, error) {
+	ret := make([]byte, len(dat))
+	for i := range dat {
+		ret[i] = dat[i] ^ 0x5a
+	}
+	return ret, nil
+}
+
+func (c xorCodec) Decompress(dat []byte, maxSize int) ([]byte, error) {
+	if err := checkLimit("decompressed size", len(dat), maxSize); err != nil {
+		return nil, err
+	}
+	return c.Compress(dat)
+}
+
+func (xorCodec) NewReader(r io.Reader, maxSize int) (io.ReadCloser, error) {
+	return io.NopCloser(xorReader{r: r}), nil
+}
+
+// xorReader decodes the data of xorCodec while reading it
+type xorReader struct {
+	r io.Reader
+}
+
+func (x xorReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	for i := 0; i < n; i++ {
+		p[i] ^= 0x5a
+	}
+	return n, err
+}
+
+// makeBenchmarkTransaction makes a transaction with many events which have asset body objects and approvers
+func makeBenchmarkTransaction(eventNum int) *BBcTransaction {
+	assetGroupID := GetIdentifier("asset_group_id_benchmark", defaultIDLength)
+	keypair := GenerateKeypair(KeyTypeEcdsaP256v1, defaultCompressionMode)
+	txobj := MakeTransaction(eventNum, 0, true, defaultIDLength)
+	for i := 0; i < eventNum; i++ {
+		u := GetIdentifier(fmt.Sprintf("user%d", i%8), defaultIDLength)
+		body := map[string]interface{}{"owner": fmt.Sprintf("user%d", i%8), "amount": i * 100, "memo": "transfer of token-X"}
+		AddEventAssetBodyObject(txobj, i, &assetGroupID, &u, body)
+		txobj.Events[i].AddMandatoryApprover(&u)
+		txobj.Witness.AddWitness(&u)
+	}
+	for i := 0; i < 8 && i < eventNum; i++ {
+		u := GetIdentifier(fmt.Sprintf("user%d", i), defaultIDLength)
+		SignToTransaction(txobj, &u, &keypair)
+	}
+	return txobj
+}
+
+func TestSerializeFormats(t *testing.T) {
+	txobj := makeBenchmarkTransaction(20)
+
+	for _, f := range compressionFormats {
+		t.Run(f.name, func(t *testing.T) {
+			dat, err := Serialize(txobj, f.formatType)
+			if err != nil {
+				t.Fatalf("failed to serialize (%v)", err)
+			}
+			t.Logf("serialized size: %d", len(dat))
+			obj, err := Deserialize(dat)
+			if err != nil {
+				t.Fatalf("failed to deserialize (%v)", err)
+			}
+			if !bytes.Equal(obj.TransactionID, txobj.TransactionID) {
+				t.Fatal("Not recovered correctly...")
+			}
+
+			if f.formatType != FormatPlain {
+				_, err = DeserializeWithLimits(dat, &DecodeLimits{MaxDecompressedSize: 1000})
+				if _, ok := err.(*DecodeLimitError); !ok {
+					t.Fatalf("DecodeLimitError must be returned (%v)", err)
+				}
+			}
+		})
+	}
+
+	t.Run("unknown format", func(t *testing.T) {
+		_, err := Serialize(txobj, 0x0100)
+		if e, ok := err.(*UnsupportedFormatError); !ok || e.FormatType != 0x0100 {
+			t.Fatalf("UnsupportedFormatError must be returned (%v)", err)
+		}
+		dat, _ := Serialize(txobj, FormatPlain)
+		dat[1] = 0x01
+		_, err = Deserialize(dat)
+		if e, ok := err.(*UnsupportedFormatError); !ok || e.FormatType != 0x0100 {
+			t.Fatalf("UnsupportedFormatError must be returned (%v)", err)
+		}
+	})
+
+	t.Run("custom codec", func(t *testing.T) {
+		RegisterCodec(0x0100, xorCodec{})
+		defer RegisterCodec(0x0100, nil)
+		dat, err := Serialize(txobj, 0x0100)
+		if err != nil {
+			t.Fatalf("failed to serialize (%v)", err)
+		}
+		obj, err := Deserialize(dat)
+		if err != nil || !bytes.Equal(obj.TransactionID, txobj.TransactionID) {
+			t.Fatalf("Not recovered correctly... (%v)", err)
+		}
+
+		RegisterCodec(0x0100, nil)
+		if _, err := Deserialize(dat); err == nil {
+			t.Fatal("removed codec must not be used")
+		}
+	})
+}
+
+func TestZstdDecompressLimit(t *testing.T) {
+	dat := make([]byte, 1<<20)
+
+	// the frame content size is in the frame header
+	compressed, _ := zstdCodec{}.Compress(dat)
+	_, err := zstdCodec{}.Decompress(compressed, 4096)
+	if e, ok := err.(*DecodeLimitError); !ok || e.Value != int64(len(dat)) {
+		t.Fatalf("DecodeLimitError must be returned before decoding (%v)", err)
+	}
+
+	// the frame declares a window larger than maxSize and no content size
+	var buf bytes.Buffer
+	writer, _ := zstd.NewWriter(&buf, zstd.WithWindowSize(1<<20))
+	writer.Write(dat)
+	writer.Close()
+	if _, err := (zstdCodec{}).Decompress(buf.Bytes(), 4096); err == nil {
+		t.Fatal("the large window must be rejected")
+	}
+	if _, err := (zstdCodec{}).Decompress(buf.Bytes(), 0); err != nil {
+		t.Fatalf("failed to decompress (%v)", err)
+	}
+}
+
+func BenchmarkSerialize(b *testing.B) {
+	txobj := makeBenchmarkTransaction(200)
+	plain, _ := Serialize(txobj, FormatPlain)
+	for _, f := range compressionFormats {
+		b.Run(f.name, func(b *testing.B) {
+			var dat []byte
+			for i := 0; i < b.N; i++ {
+				dat, _ = Serialize(txobj, f.formatType)
+			}
+			b.ReportMetric(float64(len(dat)), "bytes/tx")
+			b.ReportMetric(float64(len(dat))/float64(len(plain)), "ratio")
+		})
+	}
+}
+
+func BenchmarkDeserialize(b *testing.B) {
+	txobj := makeBenchmarkTransaction(200)
+	for _, f := range compressionFormats {
+		b.Run(f.name, func(b *testing.B) {
+			dat, _ := Serialize(txobj, f.formatType)
+			b.SetBytes(int64(len(dat)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := Deserialize(dat); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
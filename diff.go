@@ -0,0 +1,361 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"fmt"
+)
+
+/*
+Difference definition
+
+Diff() of BBcTransaction and the component objects compares the fields included in the packed data and reports the differences with the paths,
+e.g., "Events[0].Asset.AssetBody" or "Signatures[1].Signature". The path of a component object is relative to it (e.g., "Asset.AssetBody" for BBcEvent).
+If a list differs in length, the path of the list is reported and the common part is compared further.
+An empty Path means that one of the compared objects is nil.
+
+The back-pointers (Transaction and RefTransaction), RefEvent and the cached data (TransactionBaseDigest, TransactionData and SigIndices in BBcTransaction) are not compared.
+A nil slice and an empty slice are regarded as equal.
+Equal() returns true if Diff() reports nothing.
+*/
+type (
+	Difference struct {
+		Path   string
+		Detail string
+	}
+)
+
+// maxDiffBytes is the maximum number of bytes printed in Detail of Difference
+const maxDiffBytes = 32
+
+func (d Difference) String() string {
+	if d.Path == "" {
+		return d.Detail
+	}
+	return fmt.Sprintf("%s: %s", d.Path, d.Detail)
+}
+
+// differ collects Difference objects while walking two objects
+type differ struct {
+	diffs []Difference
+}
+
+func joinPath(path string, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+func indexPath(path string, idx int) string {
+	return fmt.Sprintf("%s[%d]", path, idx)
+}
+
+func shortHex(dat []byte) string {
+	if len(dat) > maxDiffBytes {
+		return fmt.Sprintf("%x...(%d bytes)", dat[:maxDiffBytes], len(dat))
+	}
+	return fmt.Sprintf("%x", dat)
+}
+
+func (d *differ) add(path string, format string, args ...interface{}) {
+	d.diffs = append(d.diffs, Difference{Path: path, Detail: fmt.Sprintf(format, args...)})
+}
+
+// nilness reports the difference if exactly one of them is nil, and returns true if the comparison should continue
+func (d *differ) nilness(path string, aIsNil, bIsNil bool) bool {
+	if aIsNil && bIsNil {
+		return false
+	}
+	if aIsNil {
+		d.add(path, "nil != object")
+		return false
+	}
+	if bIsNil {
+		d.add(path, "object != nil")
+		return false
+	}
+	return true
+}
+
+func (d *differ) bytes(path string, a, b []byte) {
+	if !bytes.Equal(a, b) {
+		d.add(path, "%s != %s", shortHex(a), shortHex(b))
+	}
+}
+
+func (d *differ) uint(path string, a, b uint64) {
+	if a != b {
+		d.add(path, "%d != %d", a, b)
+	}
+}
+
+func (d *differ) ids(path string, a, b [][]byte) {
+	if len(a) != len(b) {
+		d.add(path, "length %d != %d", len(a), len(b))
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		d.bytes(indexPath(path, i), a[i], b[i])
+	}
+}
+
+func (d *differ) ints(path string, a, b []int) {
+	if len(a) != len(b) {
+		d.add(path, "length %d != %d", len(a), len(b))
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			d.add(indexPath(path, i), "%d != %d", a[i], b[i])
+		}
+	}
+}
+
+func (d *differ) length(path string, a, b int) int {
+	if a != b {
+		d.add(path, "length %d != %d", a, b)
+	}
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func (p *BBcAsset) diff(d *differ, path string, other *BBcAsset) {
+	if !d.nilness(path, p == nil, other == nil) {
+		return
+	}
+	d.bytes(joinPath(path, "AssetID"), p.AssetID, other.AssetID)
+	d.bytes(joinPath(path, "UserID"), p.UserID, other.UserID)
+	d.bytes(joinPath(path, "Nonce"), p.Nonce, other.Nonce)
+	d.uint(joinPath(path, "AssetFileSize"), uint64(p.AssetFileSize), uint64(other.AssetFileSize))
+	d.bytes(joinPath(path, "AssetFileDigest"), p.AssetFileDigest, other.AssetFileDigest)
+	d.uint(joinPath(path, "AssetBodyType"), uint64(p.AssetBodyType), uint64(other.AssetBodyType))
+	d.uint(joinPath(path, "AssetBodySize"), uint64(p.AssetBodySize), uint64(other.AssetBodySize))
+	d.bytes(joinPath(path, "AssetBody"), p.AssetBody, other.AssetBody)
+}
+
+func (p *BBcEvent) diff(d *differ, path string, other *BBcEvent) {
+	if !d.nilness(path, p == nil, other == nil) {
+		return
+	}
+	d.bytes(joinPath(path, "AssetGroupID"), p.AssetGroupID, other.AssetGroupID)
+	d.ints(joinPath(path, "ReferenceIndices"), p.ReferenceIndices, other.ReferenceIndices)
+	d.ids(joinPath(path, "MandatoryApprovers"), p.MandatoryApprovers, other.MandatoryApprovers)
+	d.uint(joinPath(path, "OptionApproverNumNumerator"), uint64(p.OptionApproverNumNumerator), uint64(other.OptionApproverNumNumerator))
+	d.uint(joinPath(path, "OptionApproverNumDenominator"), uint64(p.OptionApproverNumDenominator), uint64(other.OptionApproverNumDenominator))
+	d.ids(joinPath(path, "OptionApprovers"), p.OptionApprovers, other.OptionApprovers)
+	p.Asset.diff(d, joinPath(path, "Asset"), other.Asset)
+}
+
+func (p *BBcReference) diff(d *differ, path string, other *BBcReference) {
+	if !d.nilness(path, p == nil, other == nil) {
+		return
+	}
+	d.bytes(joinPath(path, "AssetGroupID"), p.AssetGroupID, other.AssetGroupID)
+	d.bytes(joinPath(path, "TransactionID"), p.TransactionID, other.TransactionID)
+	d.uint(joinPath(path, "EventIndexInRef"), uint64(p.EventIndexInRef), uint64(other.EventIndexInRef))
+	d.ints(joinPath(path, "SigIndices"), p.SigIndices, other.SigIndices)
+}
+
+func (p *BBcPointer) diff(d *differ, path string, other *BBcPointer) {
+	if !d.nilness(path, p == nil, other == nil) {
+		return
+	}
+	d.bytes(joinPath(path, "TransactionID"), p.TransactionID, other.TransactionID)
+	d.bytes(joinPath(path, "AssetID"), p.AssetID, other.AssetID)
+}
+
+func (p *BBcRelation) diff(d *differ, path string, other *BBcRelation) {
+	if !d.nilness(path, p == nil, other == nil) {
+		return
+	}
+	d.bytes(joinPath(path, "AssetGroupID"), p.AssetGroupID, other.AssetGroupID)
+	n := d.length(joinPath(path, "Pointers"), len(p.Pointers), len(other.Pointers))
+	for i := 0; i < n; i++ {
+		p.Pointers[i].diff(d, indexPath(joinPath(path, "Pointers"), i), other.Pointers[i])
+	}
+	p.Asset.diff(d, joinPath(path, "Asset"), other.Asset)
+}
+
+func (p *BBcWitness) diff(d *differ, path string, other *BBcWitness) {
+	if !d.nilness(path, p == nil, other == nil) {
+		return
+	}
+	d.ids(joinPath(path, "UserIDs"), p.UserIDs, other.UserIDs)
+	d.ints(joinPath(path, "SigIndices"), p.SigIndices, other.SigIndices)
+}
+
+func (p *BBcCrossRef) diff(d *differ, path string, other *BBcCrossRef) {
+	if !d.nilness(path, p == nil, other == nil) {
+		return
+	}
+	d.bytes(joinPath(path, "DomainID"), p.DomainID, other.DomainID)
+	d.bytes(joinPath(path, "TransactionID"), p.TransactionID, other.TransactionID)
+}
+
+func (p *BBcSignature) diff(d *differ, path string, other *BBcSignature) {
+	if !d.nilness(path, p == nil, other == nil) {
+		return
+	}
+	d.uint(joinPath(path, "KeyType"), uint64(p.KeyType), uint64(other.KeyType))
+	d.bytes(joinPath(path, "Pubkey"), p.Pubkey, other.Pubkey)
+	d.bytes(joinPath(path, "Signature"), p.Signature, other.Signature)
+}
+
+func (p *BBcTransaction) diff(d *differ, path string, other *BBcTransaction) {
+	if !d.nilness(path, p == nil, other == nil) {
+		return
+	}
+	d.bytes(joinPath(path, "TransactionID"), p.TransactionID, other.TransactionID)
+	d.uint(joinPath(path, "Version"), uint64(p.Version), uint64(other.Version))
+	d.uint(joinPath(path, "Timestamp"), uint64(p.Timestamp), uint64(other.Timestamp))
+	d.uint(joinPath(path, "IDLength"), uint64(p.IDLength), uint64(other.IDLength))
+
+	n := d.length(joinPath(path, "Extensions"), len(p.Extensions), len(other.Extensions))
+	for i := 0; i < n; i++ {
+		extPath := indexPath(joinPath(path, "Extensions"), i)
+		d.uint(joinPath(extPath, "Type"), uint64(p.Extensions[i].Type), uint64(other.Extensions[i].Type))
+		d.bytes(joinPath(extPath, "Data"), p.Extensions[i].Data, other.Extensions[i].Data)
+	}
+	n = d.length(joinPath(path, "Events"), len(p.Events), len(other.Events))
+	for i := 0; i < n; i++ {
+		p.Events[i].diff(d, indexPath(joinPath(path, "Events"), i), other.Events[i])
+	}
+	n = d.length(joinPath(path, "References"), len(p.References), len(other.References))
+	for i := 0; i < n; i++ {
+		p.References[i].diff(d, indexPath(joinPath(path, "References"), i), other.References[i])
+	}
+	n = d.length(joinPath(path, "Relations"), len(p.Relations), len(other.Relations))
+	for i := 0; i < n; i++ {
+		p.Relations[i].diff(d, indexPath(joinPath(path, "Relations"), i), other.Relations[i])
+	}
+	p.Witness.diff(d, joinPath(path, "Witness"), other.Witness)
+	p.Crossref.diff(d, joinPath(path, "Crossref"), other.Crossref)
+	n = d.length(joinPath(path, "Signatures"), len(p.Signatures), len(other.Signatures))
+	for i := 0; i < n; i++ {
+		p.Signatures[i].diff(d, indexPath(joinPath(path, "Signatures"), i), other.Signatures[i])
+	}
+}
+
+// Diff reports the differences between the BBcAsset objects
+func (p *BBcAsset) Diff(other *BBcAsset) []Difference {
+	d := differ{}
+	p.diff(&d, "", other)
+	return d.diffs
+}
+
+// Equal returns true if the BBcAsset objects have the same content
+func (p *BBcAsset) Equal(other *BBcAsset) bool {
+	return len(p.Diff(other)) == 0
+}
+
+// Diff reports the differences between the BBcEvent objects
+func (p *BBcEvent) Diff(other *BBcEvent) []Difference {
+	d := differ{}
+	p.diff(&d, "", other)
+	return d.diffs
+}
+
+// Equal returns true if the BBcEvent objects have the same content
+func (p *BBcEvent) Equal(other *BBcEvent) bool {
+	return len(p.Diff(other)) == 0
+}
+
+// Diff reports the differences between the BBcReference objects
+func (p *BBcReference) Diff(other *BBcReference) []Difference {
+	d := differ{}
+	p.diff(&d, "", other)
+	return d.diffs
+}
+
+// Equal returns true if the BBcReference objects have the same content
+func (p *BBcReference) Equal(other *BBcReference) bool {
+	return len(p.Diff(other)) == 0
+}
+
+// Diff reports the differences between the BBcPointer objects
+func (p *BBcPointer) Diff(other *BBcPointer) []Difference {
+	d := differ{}
+	p.diff(&d, "", other)
+	return d.diffs
+}
+
+// Equal returns true if the BBcPointer objects have the same content
+func (p *BBcPointer) Equal(other *BBcPointer) bool {
+	return len(p.Diff(other)) == 0
+}
+
+// Diff reports the differences between the BBcRelation objects
+func (p *BBcRelation) Diff(other *BBcRelation) []Difference {
+	d := differ{}
+	p.diff(&d, "", other)
+	return d.diffs
+}
+
+// Equal returns true if the BBcRelation objects have the same content
+func (p *BBcRelation) Equal(other *BBcRelation) bool {
+	return len(p.Diff(other)) == 0
+}
+
+// Diff reports the differences between the BBcWitness objects
+func (p *BBcWitness) Diff(other *BBcWitness) []Difference {
+	d := differ{}
+	p.diff(&d, "", other)
+	return d.diffs
+}
+
+// Equal returns true if the BBcWitness objects have the same content
+func (p *BBcWitness) Equal(other *BBcWitness) bool {
+	return len(p.Diff(other)) == 0
+}
+
+// Diff reports the differences between the BBcCrossRef objects
+func (p *BBcCrossRef) Diff(other *BBcCrossRef) []Difference {
+	d := differ{}
+	p.diff(&d, "", other)
+	return d.diffs
+}
+
+// Equal returns true if the BBcCrossRef objects have the same content
+func (p *BBcCrossRef) Equal(other *BBcCrossRef) bool {
+	return len(p.Diff(other)) == 0
+}
+
+// Diff reports the differences between the BBcSignature objects
+func (p *BBcSignature) Diff(other *BBcSignature) []Difference {
+	d := differ{}
+	p.diff(&d, "", other)
+	return d.diffs
+}
+
+// Equal returns true if the BBcSignature objects have the same content
+func (p *BBcSignature) Equal(other *BBcSignature) bool {
+	return len(p.Diff(other)) == 0
+}
+
+// Diff reports the differences between the BBcTransaction objects
+func (p *BBcTransaction) Diff(other *BBcTransaction) []Difference {
+	d := differ{}
+	p.diff(&d, "", other)
+	return d.diffs
+}
+
+// Equal returns true if the BBcTransaction objects have the same content
+func (p *BBcTransaction) Equal(other *BBcTransaction) bool {
+	return len(p.Diff(other)) == 0
+}
@@ -0,0 +1,92 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"testing"
+)
+
+func diffPaths(diffs []Difference) map[string]bool {
+	ret := make(map[string]bool)
+	for _, d := range diffs {
+		ret[d.Path] = true
+	}
+	return ret
+}
+
+func TestTransactionDiff(t *testing.T) {
+	txobj := makeDecodeTestTransaction(t)
+	dat, _ := Serialize(txobj, FormatPlain)
+	received, err := Deserialize(dat)
+	if err != nil {
+		t.Fatalf("failed to deserialize (%v)", err)
+	}
+	if diffs := txobj.Diff(received); len(diffs) > 0 {
+		t.Fatalf("deserialized transaction differs: %v", diffs)
+	}
+
+	received.Events[0].Asset.AssetBody = []byte("modified")
+	received.Signatures[1].Signature[0] ^= 0xff
+	received.Relations[0].Pointers = received.Relations[0].Pointers[:0]
+	received.Witness = nil
+	received.AddExtension(1, []byte("ext"))
+
+	diffs := txobj.Diff(received)
+	paths := diffPaths(diffs)
+	for _, p := range []string{"Events[0].Asset.AssetBody", "Signatures[1].Signature", "Relations[0].Pointers", "Witness", "Extensions"} {
+		if !paths[p] {
+			t.Fatalf("%s is not reported: %v", p, diffs)
+		}
+	}
+	if paths["Events[0].Asset.AssetID"] || paths["Signatures[0].Signature"] {
+		t.Fatalf("unexpected differences: %v", diffs)
+	}
+	if txobj.Equal(received) {
+		t.Fatal("Equal must return false")
+	}
+	for _, d := range diffs {
+		t.Log(d.String())
+	}
+}
+
+func TestComponentDiff(t *testing.T) {
+	txobj := makeDecodeTestTransaction(t)
+
+	evt := txobj.Events[0].Clone()
+	evt.OptionApprovers[1][0] ^= 0xff
+	evt.Asset = nil
+	paths := diffPaths(txobj.Events[0].Diff(evt))
+	if len(paths) != 2 || !paths["OptionApprovers[1]"] || !paths["Asset"] {
+		t.Fatalf("unexpected differences: %v", paths)
+	}
+
+	var nilSig *BBcSignature
+	if diffs := nilSig.Diff(txobj.Signatures[0]); len(diffs) != 1 || diffs[0].Path != "" {
+		t.Fatalf("unexpected differences: %v", diffs)
+	}
+	if !nilSig.Equal(nil) {
+		t.Fatal("nil objects must be equal")
+	}
+
+	body := make([]byte, 100)
+	ast := txobj.Events[0].Asset.Clone()
+	ast.AssetBody = body
+	diffs := txobj.Events[0].Asset.Diff(ast)
+	if len(diffs) != 1 || diffs[0].Path != "AssetBody" || len(diffs[0].Detail) > 200 {
+		t.Fatalf("unexpected differences: %v", diffs)
+	}
+}
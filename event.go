@@ -51,6 +51,7 @@ type (
 		OptionApproverNumDenominator uint16
 		OptionApprovers              [][]byte
 		Asset                        *BBcAsset
+		assetForm                    int
 	}
 )
 
@@ -169,11 +170,14 @@ func (p *BBcEvent) Pack() ([]byte, error) {
 }
 
 // unpackApprovers unpacks the approver part of the binary data
-func (p *BBcEvent) unpackApprovers(buf *bytes.Buffer) error {
+func (p *BBcEvent) unpackApprovers(buf *bytes.Buffer, limits *DecodeLimits) error {
 	numMandatory, err := Get2byte(buf)
 	if err != nil {
 		return err
 	}
+	if err = limits.checkCount("MandatoryApprovers", int(numMandatory)); err != nil {
+		return err
+	}
 	for i := 0; i < int(numMandatory); i++ {
 		userID, err2 := GetBigInt(buf)
 		if err2 != nil {
@@ -190,6 +194,9 @@ func (p *BBcEvent) unpackApprovers(buf *bytes.Buffer) error {
 	if err != nil {
 		return err
 	}
+	if err = limits.checkCount("OptionApprovers", int(p.OptionApproverNumDenominator)); err != nil {
+		return err
+	}
 
 	for i := 0; i < int(p.OptionApproverNumDenominator); i++ {
 		userID, err2 := GetBigInt(buf)
@@ -203,6 +210,11 @@ func (p *BBcEvent) unpackApprovers(buf *bytes.Buffer) error {
 
 // Unpack the binary data to the BBcEvent object
 func (p *BBcEvent) Unpack(dat *[]byte) error {
+	return p.unpack(dat, nil)
+}
+
+// unpack the binary data to the BBcEvent object within the limits (no limit if limits is nil)
+func (p *BBcEvent) unpack(dat *[]byte, limits *DecodeLimits) error {
 	var err error
 	buf := bytes.NewBuffer(*dat)
 
@@ -215,6 +227,9 @@ func (p *BBcEvent) Unpack(dat *[]byte) error {
 	if err != nil {
 		return err
 	}
+	if err = limits.checkCount("ReferenceIndices", int(numReferences)); err != nil {
+		return err
+	}
 	for i := 0; i < int(numReferences); i++ {
 		idx, err2 := Get2byte(buf)
 		if err2 != nil {
@@ -223,7 +238,7 @@ func (p *BBcEvent) Unpack(dat *[]byte) error {
 		p.ReferenceIndices = append(p.ReferenceIndices, int(idx))
 	}
 
-	if err = p.unpackApprovers(buf); err != nil {
+	if err = p.unpackApprovers(buf, limits); err != nil {
 		return err
 	}
 
@@ -236,8 +251,10 @@ func (p *BBcEvent) Unpack(dat *[]byte) error {
 		if err != nil {
 			return err
 		}
-		p.Asset = &BBcAsset{IDLength: p.IDLength}
-		p.Asset.Unpack(&ast)
+		p.Asset = &BBcAsset{IDLength: p.IDLength, form: p.assetForm}
+		if err := p.Asset.unpack(&ast, limits); err != nil {
+			return limitErrorAt("Asset", err)
+		}
 	}
 
 	return nil
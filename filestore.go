@@ -0,0 +1,279 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+AssetFileStore definition
+
+AssetFileStore keeps the asset files (the contents whose digests are in AssetFileDigest of BBcAsset objects),
+addressed by the AssetGroupID and the AssetID. Implementations must be safe for concurrent use.
+
+Put reads the content from r and stores it only if it matches AssetFileSize and AssetFileDigest of the asset,
+otherwise the returned error wraps ErrAssetFileMismatch. Putting the file of the same asset again replaces it.
+AssetID of the asset must be set, since the files are addressed by it (Put does not calculate it),
+e.g., take the asset from a transaction whose TransactionID has been calculated.
+Get and Delete return ErrAssetFileNotFound if the file is not stored. The caller must close the reader returned by Get.
+Walk calls fn for each stored file, and stops when fn returns an error.
+
+LocalAssetFileStore (in this file) keeps the files in a directory of the local filesystem.
+CollectAssetFileGarbage removes the files which are no longer referred by any transaction in a TransactionStore.
+*/
+type (
+	AssetFileStore interface {
+		Put(assetGroupID []byte, asset *BBcAsset, r io.Reader) error
+		Get(assetGroupID, assetID []byte) (io.ReadCloser, error)
+		Exists(assetGroupID, assetID []byte) (bool, error)
+		Delete(assetGroupID, assetID []byte) error
+		Walk(fn func(assetGroupID, assetID []byte) error) error
+	}
+
+	// LocalAssetFileStore is an AssetFileStore in a directory of the local filesystem
+	LocalAssetFileStore struct {
+		root string
+	}
+)
+
+// ErrAssetFileNotFound is returned by AssetFileStore if the file is not stored
+var ErrAssetFileNotFound = errors.New("asset file not found")
+
+// prefix of the temporary files in LocalAssetFileStore
+const assetFileTempPrefix = ".tmp-"
+
+/*
+NewLocalAssetFileStore returns an AssetFileStore in the directory root, which is created if it does not exist
+
+The files are sharded by the asset group and the first byte of the AssetID:
+
+	root/<AssetGroupID in hex>/<first byte of AssetID in hex>/<AssetID in hex>
+
+A file is written in a temporary file in the same directory and renamed after the verification,
+so that a reader never sees a partial or unverified file.
+*/
+func NewLocalAssetFileStore(root string) (*LocalAssetFileStore, error) {
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, err
+	}
+	return &LocalAssetFileStore{root: root}, nil
+}
+
+// path returns the directory of the shard and the path of the file
+func (s *LocalAssetFileStore) path(assetGroupID, assetID []byte) (string, string, error) {
+	if len(assetGroupID) == 0 {
+		return "", "", errors.New("asset group id is empty")
+	}
+	if len(assetID) == 0 {
+		return "", "", errors.New("asset id is empty")
+	}
+	dir := filepath.Join(s.root, hex.EncodeToString(assetGroupID), hex.EncodeToString(assetID[:1]))
+	return dir, filepath.Join(dir, hex.EncodeToString(assetID)), nil
+}
+
+// Put verifies the content read from r against the asset and stores it
+func (s *LocalAssetFileStore) Put(assetGroupID []byte, asset *BBcAsset, r io.Reader) error {
+	if asset == nil {
+		return errors.New("asset is nil")
+	}
+	if asset.AssetFileDigest == nil {
+		return errors.New("asset has no file digest")
+	}
+	if asset.AssetID == nil {
+		return errors.New("AssetID of the asset is not set (calculate TransactionID of the parent transaction first)")
+	}
+	dir, path, err := s.path(assetGroupID, asset.AssetID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, assetFileTempPrefix)
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+	defer os.Remove(tmpPath)
+
+	err = asset.VerifyFile(io.TeeReader(r, f))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Get returns the reader of the stored file
+func (s *LocalAssetFileStore) Get(assetGroupID, assetID []byte) (io.ReadCloser, error) {
+	_, path, err := s.path(assetGroupID, assetID)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrAssetFileNotFound
+	}
+	return f, err
+}
+
+// Exists returns true if the file is stored
+func (s *LocalAssetFileStore) Exists(assetGroupID, assetID []byte) (bool, error) {
+	_, path, err := s.path(assetGroupID, assetID)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete removes the stored file
+//
+// The directories of the shard and the asset group are kept even if they become empty, because a concurrent Put may be about to create a file in them.
+func (s *LocalAssetFileStore) Delete(assetGroupID, assetID []byte) error {
+	_, path, err := s.path(assetGroupID, assetID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return ErrAssetFileNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Walk calls fn for each stored file in the order of AssetGroupID and AssetID
+func (s *LocalAssetFileStore) Walk(fn func(assetGroupID, assetID []byte) error) error {
+	groups, err := os.ReadDir(s.root)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		assetGroupID, err := hex.DecodeString(group.Name())
+		if err != nil || !group.IsDir() {
+			continue
+		}
+		groupDir := filepath.Join(s.root, group.Name())
+		shards, err := os.ReadDir(groupDir)
+		if err != nil {
+			return err
+		}
+		for _, shard := range shards {
+			if !shard.IsDir() {
+				continue
+			}
+			files, err := os.ReadDir(filepath.Join(groupDir, shard.Name()))
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				if strings.HasPrefix(file.Name(), assetFileTempPrefix) || file.IsDir() {
+					continue
+				}
+				assetID, err := hex.DecodeString(file.Name())
+				if err != nil || len(assetID) == 0 || !strings.HasPrefix(file.Name(), shard.Name()) {
+					continue
+				}
+				if err := fn(assetGroupID, assetID); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// referredAssetFile returns true if the transaction has an asset with the file in the asset group
+func referredAssetFile(transaction *BBcTransaction, assetGroupID, assetID []byte) bool {
+	match := func(agid []byte, asset *BBcAsset) bool {
+		return asset != nil && asset.AssetFileDigest != nil &&
+			bytes.Equal(agid, assetGroupID) && bytes.Equal(asset.AssetID, assetID)
+	}
+	for _, evt := range transaction.Events {
+		if evt != nil && match(evt.AssetGroupID, evt.Asset) {
+			return true
+		}
+	}
+	for _, rtn := range transaction.Relations {
+		if rtn != nil && match(rtn.AssetGroupID, rtn.Asset) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+CollectAssetFileGarbage removes the files in the AssetFileStore which are not referred by any transaction in the TransactionStore
+
+A file is referred if a stored transaction has a BBcAsset with the AssetID and a file digest in a BBcEvent or BBcRelation of the asset group.
+It returns the number of the removed files.
+
+A file put before its transaction is stored is regarded as garbage, so store the transaction first
+or do not run the collection while transactions are being added.
+*/
+func CollectAssetFileGarbage(files AssetFileStore, transactions TransactionStore) (int, error) {
+	type assetFileKey struct {
+		assetGroupID, assetID []byte
+	}
+	var garbage []assetFileKey
+	err := files.Walk(func(assetGroupID, assetID []byte) error {
+		txobjs, err := transactions.GetByAssetID(assetID)
+		if err != nil {
+			return err
+		}
+		for _, txobj := range txobjs {
+			if referredAssetFile(txobj, assetGroupID, assetID) {
+				return nil
+			}
+		}
+		garbage = append(garbage, assetFileKey{assetGroupID, assetID})
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, key := range garbage {
+		err := files.Delete(key.assetGroupID, key.assetID)
+		if err == ErrAssetFileNotFound {
+			continue
+		}
+		if err != nil {
+			return removed, fmt.Errorf("failed to remove the asset file %x (%v)", key.assetID, err)
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,205 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestLocalAssetFileStore(t *testing.T) {
+	ag := GetIdentifier("asset_group_id_filestore", defaultIDLength)
+	u1 := GetIdentifier("user1_filestore", defaultIDLength)
+	content := bytes.Repeat([]byte("asset file content "), 1000)
+	txobj := makeStoreTestTransaction(t, DefaultTransactionVersion, ag, u1, "file asset", content)
+	asset := txobj.Events[0].Asset
+
+	root := filepath.Join(t.TempDir(), "files")
+	store, err := NewLocalAssetFileStore(root)
+	if err != nil {
+		t.Fatalf("failed to create the store (%v)", err)
+	}
+
+	t.Run("put and get", func(t *testing.T) {
+		if err := store.Put(ag, asset, bytes.NewReader(content)); err != nil {
+			t.Fatalf("failed to put (%v)", err)
+		}
+		if ok, err := store.Exists(ag, asset.AssetID); !ok || err != nil {
+			t.Fatalf("file must exist (%v)", err)
+		}
+		shard := filepath.Join(root, hex.EncodeToString(ag), hex.EncodeToString(asset.AssetID[:1]), hex.EncodeToString(asset.AssetID))
+		if _, err := os.Stat(shard); err != nil {
+			t.Fatalf("file is not in the shard (%v)", err)
+		}
+		r, err := store.Get(ag, asset.AssetID)
+		if err != nil {
+			t.Fatalf("failed to get (%v)", err)
+		}
+		defer r.Close()
+		dat, _ := io.ReadAll(r)
+		if !bytes.Equal(dat, content) {
+			t.Fatal("content differs")
+		}
+	})
+
+	t.Run("put mismatch", func(t *testing.T) {
+		ag2 := GetIdentifier("asset_group_id_filestore2", defaultIDLength)
+		modified := append([]byte{}, content...)
+		modified[0] ^= 0xff
+		err := store.Put(ag2, asset, bytes.NewReader(modified))
+		if !errors.Is(err, ErrAssetFileMismatch) {
+			t.Fatalf("ErrAssetFileMismatch is expected (%v)", err)
+		}
+		if ok, _ := store.Exists(ag2, asset.AssetID); ok {
+			t.Fatal("mismatched file must not be stored")
+		}
+		entries, _ := os.ReadDir(filepath.Join(root, hex.EncodeToString(ag2), hex.EncodeToString(asset.AssetID[:1])))
+		if len(entries) != 0 {
+			t.Fatalf("temporary file remains (%d)", len(entries))
+		}
+		noFile := BBcAsset{IDLength: defaultIDLength}
+		noFile.Add(&u1)
+		if err := store.Put(ag2, &noFile, bytes.NewReader(content)); err == nil {
+			t.Fatal("asset without a file digest must be rejected")
+		}
+		noID := BBcAsset{IDLength: defaultIDLength}
+		noID.Add(&u1)
+		noID.AddFile(&content)
+		if err := store.Put(ag2, &noID, bytes.NewReader(content)); err == nil || noID.AssetID != nil {
+			t.Fatal("asset without AssetID must be rejected")
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		unknown := GetIdentifier("unknown asset", defaultIDLength)
+		if ok, err := store.Exists(ag, unknown); ok || err != nil {
+			t.Fatalf("file must not exist (%v)", err)
+		}
+		if _, err := store.Get(ag, unknown); err != ErrAssetFileNotFound {
+			t.Fatalf("ErrAssetFileNotFound is expected (%v)", err)
+		}
+		if err := store.Delete(ag, unknown); err != ErrAssetFileNotFound {
+			t.Fatalf("ErrAssetFileNotFound is expected (%v)", err)
+		}
+		if _, err := store.Get(nil, unknown); err == nil {
+			t.Fatal("empty asset group id must be rejected")
+		}
+	})
+
+	t.Run("walk and delete", func(t *testing.T) {
+		var found [][]byte
+		store.Walk(func(assetGroupID, assetID []byte) error {
+			found = append(found, assetID)
+			return nil
+		})
+		if len(found) != 1 || !bytes.Equal(found[0], asset.AssetID) {
+			t.Fatalf("walk found %d files", len(found))
+		}
+		if err := store.Delete(ag, asset.AssetID); err != nil {
+			t.Fatalf("failed to delete (%v)", err)
+		}
+		if ok, _ := store.Exists(ag, asset.AssetID); ok {
+			t.Fatal("deleted file remains")
+		}
+	})
+
+	t.Run("concurrent put and delete", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					if err := store.Put(ag, asset, bytes.NewReader(content)); err != nil {
+						errs <- err
+						return
+					}
+					if err := store.Delete(ag, asset.AssetID); err != nil && err != ErrAssetFileNotFound {
+						errs <- err
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Fatalf("failed in concurrent put and delete (%v)", err)
+		}
+	})
+}
+
+func TestCollectAssetFileGarbage(t *testing.T) {
+	ag := GetIdentifier("asset_group_id_filestore_gc", defaultIDLength)
+	u1 := GetIdentifier("user1_filestore_gc", defaultIDLength)
+	files, err := NewLocalAssetFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create the store (%v)", err)
+	}
+	transactions := NewMemoryTransactionStore(FormatZlib)
+
+	contents := [][]byte{[]byte("stored content"), []byte("orphan content"), []byte("stored content in version 2")}
+	var txobjs []*BBcTransaction
+	for i, c := range contents {
+		version := DefaultTransactionVersion
+		if i == 2 {
+			version = TransactionVersion2
+		}
+		txobj := makeStoreTestTransaction(t, version, ag, u1, "file asset", c)
+		if err := files.Put(ag, txobj.Events[0].Asset, bytes.NewReader(c)); err != nil {
+			t.Fatalf("failed to put (%v)", err)
+		}
+		txobjs = append(txobjs, txobj)
+	}
+	for _, txobj := range []*BBcTransaction{txobjs[0], txobjs[2]} {
+		if err := transactions.Put(txobj); err != nil {
+			t.Fatalf("failed to put the transaction (%v)", err)
+		}
+	}
+	// same asset in another asset group is not referred
+	otherGroup := GetIdentifier("asset_group_id_filestore_gc2", defaultIDLength)
+	if err := files.Put(otherGroup, txobjs[0].Events[0].Asset, bytes.NewReader(contents[0])); err != nil {
+		t.Fatalf("failed to put (%v)", err)
+	}
+
+	removed, err := CollectAssetFileGarbage(files, transactions)
+	if err != nil {
+		t.Fatalf("failed to collect (%v)", err)
+	}
+	if removed != 2 {
+		t.Fatalf("removed %d files but 2 is expected", removed)
+	}
+	if ok, _ := files.Exists(ag, txobjs[0].Events[0].Asset.AssetID); !ok {
+		t.Fatal("referred file is removed")
+	}
+	if ok, _ := files.Exists(ag, txobjs[2].Events[0].Asset.AssetID); !ok {
+		t.Fatal("referred file of the version 2 transaction is removed")
+	}
+	if ok, _ := files.Exists(ag, txobjs[1].Events[0].Asset.AssetID); ok {
+		t.Fatal("orphan file remains")
+	}
+	if ok, _ := files.Exists(otherGroup, txobjs[0].Events[0].Asset.AssetID); ok {
+		t.Fatal("file in the other asset group remains")
+	}
+}
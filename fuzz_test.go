@@ -0,0 +1,147 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Fuzz targets for the decoder. Run with "go test -fuzz=FuzzDeserialize" etc. (Go 1.18 or later).
+// The decoder must not panic, and must not allocate memory beyond the size of the input data.
+
+func FuzzDeserialize(f *testing.F) {
+	txobj := makeDecodeTestTransaction(f)
+	for _, formatType := range []uint16{FormatPlain, FormatZlib} {
+		dat, _ := Serialize(txobj, formatType)
+		f.Add(dat)
+	}
+	f.Add([]byte{})
+	f.Add([]byte{0x10, 0x00, 0x78, 0x9c})
+
+	f.Fuzz(func(t *testing.T, dat []byte) {
+		if obj, err := DeserializeWithLimits(dat, nil); err == nil {
+			obj.Pack()
+			obj.Validate()
+		}
+		Deserialize(dat)
+	})
+}
+
+func FuzzDecoder(f *testing.F) {
+	txobj := makeDecodeTestTransaction(f)
+	for _, formatType := range []uint16{FormatPlain, FormatZlib} {
+		buf := new(bytes.Buffer)
+		encoder := NewEncoder(buf, formatType)
+		encoder.Encode(txobj)
+		encoder.Encode(txobj)
+		f.Add(buf.Bytes())
+	}
+
+	f.Fuzz(func(t *testing.T, dat []byte) {
+		decoder := NewDecoder(bytes.NewReader(dat))
+		for {
+			if _, err := decoder.Decode(); err != nil {
+				break
+			}
+		}
+	})
+}
+
+// fuzzUnpack registers the seed and the fuzz function for an Unpack method
+func fuzzUnpack(f *testing.F, seed []byte, unpack func(dat *[]byte) error) {
+	f.Add(seed)
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, dat []byte) {
+		unpack(&dat)
+	})
+}
+
+func FuzzUnpackTransaction(f *testing.F) {
+	dat, _ := makeDecodeTestTransaction(f).Pack()
+	fuzzUnpack(f, dat, func(dat *[]byte) error {
+		obj := BBcTransaction{}
+		return obj.UnpackWithLimits(dat, nil)
+	})
+}
+
+func FuzzUnpackEvent(f *testing.F) {
+	dat, _ := makeDecodeTestTransaction(f).Events[0].Pack()
+	fuzzUnpack(f, dat, func(dat *[]byte) error {
+		obj := BBcEvent{IDLength: defaultIDLength}
+		return obj.Unpack(dat)
+	})
+}
+
+func FuzzUnpackReference(f *testing.F) {
+	dat, _ := makeDecodeTestTransaction(f).References[0].Pack()
+	fuzzUnpack(f, dat, func(dat *[]byte) error {
+		obj := BBcReference{IDLength: defaultIDLength}
+		return obj.Unpack(dat)
+	})
+}
+
+func FuzzUnpackRelation(f *testing.F) {
+	dat, _ := makeDecodeTestTransaction(f).Relations[0].Pack()
+	fuzzUnpack(f, dat, func(dat *[]byte) error {
+		obj := BBcRelation{IDLength: defaultIDLength}
+		return obj.Unpack(dat)
+	})
+}
+
+func FuzzUnpackPointer(f *testing.F) {
+	dat, _ := makeDecodeTestTransaction(f).Relations[0].Pointers[0].Pack()
+	fuzzUnpack(f, dat, func(dat *[]byte) error {
+		obj := BBcPointer{IDLength: defaultIDLength}
+		return obj.Unpack(dat)
+	})
+}
+
+func FuzzUnpackAsset(f *testing.F) {
+	dat, _ := makeDecodeTestTransaction(f).Events[0].Asset.Pack()
+	fuzzUnpack(f, dat, func(dat *[]byte) error {
+		obj := BBcAsset{IDLength: defaultIDLength}
+		return obj.Unpack(dat)
+	})
+}
+
+func FuzzUnpackWitness(f *testing.F) {
+	dat, _ := makeDecodeTestTransaction(f).Witness.Pack()
+	fuzzUnpack(f, dat, func(dat *[]byte) error {
+		obj := BBcWitness{IDLength: defaultIDLength}
+		return obj.Unpack(dat)
+	})
+}
+
+func FuzzUnpackCrossRef(f *testing.F) {
+	dat, _ := makeDecodeTestTransaction(f).Crossref.Pack()
+	fuzzUnpack(f, dat, func(dat *[]byte) error {
+		obj := BBcCrossRef{IDLength: defaultIDLength}
+		return obj.Unpack(dat)
+	})
+}
+
+func FuzzUnpackSignature(f *testing.F) {
+	dat, _ := makeDecodeTestTransaction(f).Signatures[0].Pack()
+	fuzzUnpack(f, dat, func(dat *[]byte) error {
+		obj := BBcSignature{}
+		return obj.Unpack(dat)
+	})
+}
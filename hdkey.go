@@ -0,0 +1,220 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+/*
+HDKey definition
+
+HDKey is a node of the hierarchical deterministic key tree defined by BIP32 and SLIP-0010.
+A master HDKey is generated from a seed (or a BIP39 mnemonic), and child keys are derived by index or by path (e.g., "m/44'/0'/0'/0/1").
+Since every KeyPair (and userID) in the tree can be regenerated from the seed, only the seed needs to be backed up.
+
+Supported key types are KeyTypeEcdsaSECP256k1 (BIP32), KeyTypeEcdsaP256v1 (SLIP-0010 nist256p1) and KeyTypeEd25519 (SLIP-0010, hardened derivation only).
+*/
+type (
+	HDKey struct {
+		CurveType int
+		Privkey   []byte
+		ChainCode []byte
+		Depth     uint8
+		Index     uint32
+	}
+)
+
+// HardenedKeyStart is the first index of hardened child keys (written as "i'" or "iH" in a path)
+const (
+	HardenedKeyStart = 0x80000000
+)
+
+// hdSeedKey returns the HMAC key for generating the master key
+func hdSeedKey(curveType int) ([]byte, error) {
+	switch curveType {
+	case KeyTypeEcdsaSECP256k1:
+		return []byte("Bitcoin seed"), nil
+	case KeyTypeEcdsaP256v1:
+		return []byte("Nist256p1 seed"), nil
+	case KeyTypeEd25519:
+		return []byte("ed25519 seed"), nil
+	}
+	return nil, fmt.Errorf("not supported key type: %d", curveType)
+}
+
+// hdCurveOrder returns the order of the curve
+func hdCurveOrder(curveType int) *big.Int {
+	if curveType == KeyTypeEcdsaSECP256k1 {
+		n, _ := new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+		return n
+	}
+	return elliptic.P256().Params().N
+}
+
+// hmacSHA512 returns HMAC-SHA512 of the data
+func hmacSHA512(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// MnemonicToSeed converts the BIP39 mnemonic sentence into a 64-byte seed (the checksum of the mnemonic is not validated)
+func MnemonicToSeed(mnemonic string, passphrase string) []byte {
+	password := norm.NFKD.String(strings.Join(strings.Fields(mnemonic), " "))
+	salt := norm.NFKD.String("mnemonic" + passphrase)
+	return pbkdf2.Key([]byte(password), []byte(salt), 2048, 64, sha512.New)
+}
+
+// NewMasterKey generates the master HDKey from the seed (16 to 64 bytes)
+func NewMasterKey(curveType int, seed []byte) (*HDKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, errors.New("length of seed must be between 16 and 64 bytes")
+	}
+	seedKey, err := hdSeedKey(curveType)
+	if err != nil {
+		return nil, err
+	}
+
+	I := hmacSHA512(seedKey, seed)
+	if curveType != KeyTypeEd25519 {
+		n := hdCurveOrder(curveType)
+		for {
+			il := new(big.Int).SetBytes(I[:32])
+			if il.Sign() != 0 && il.Cmp(n) < 0 {
+				break
+			}
+			I = hmacSHA512(seedKey, I)
+		}
+	}
+	return &HDKey{CurveType: curveType, Privkey: I[:32], ChainCode: I[32:]}, nil
+}
+
+// NewMasterKeyFromMnemonic generates the master HDKey from the BIP39 mnemonic sentence and passphrase
+func NewMasterKeyFromMnemonic(curveType int, mnemonic string, passphrase string) (*HDKey, error) {
+	return NewMasterKey(curveType, MnemonicToSeed(mnemonic, passphrase))
+}
+
+// Child derives the child HDKey with the index (index >= HardenedKeyStart means hardened derivation)
+func (k *HDKey) Child(index uint32) (*HDKey, error) {
+	if k.Depth == 0xff {
+		return nil, errors.New("too deep derivation")
+	}
+	hardened := index >= HardenedKeyStart
+	if k.CurveType == KeyTypeEd25519 && !hardened {
+		return nil, errors.New("Ed25519 supports only hardened derivation")
+	}
+
+	var ser32 [4]byte
+	binary.BigEndian.PutUint32(ser32[:], index)
+	var data []byte
+	if hardened {
+		data = append([]byte{0x00}, padScalar(k.Privkey)...)
+	} else {
+		data = ecdsaPublicKey(k.CurveType, k.Privkey, CompressionModeCompressed)
+		if data == nil {
+			return nil, fmt.Errorf("not supported key type: %d", k.CurveType)
+		}
+	}
+
+	I := hmacSHA512(k.ChainCode, data, ser32[:])
+	if k.CurveType == KeyTypeEd25519 {
+		return &HDKey{CurveType: k.CurveType, Privkey: I[:32], ChainCode: I[32:], Depth: k.Depth + 1, Index: index}, nil
+	}
+
+	n := hdCurveOrder(k.CurveType)
+	parent := new(big.Int).SetBytes(k.Privkey)
+	for {
+		il := new(big.Int).SetBytes(I[:32])
+		child := new(big.Int).Add(il, parent)
+		child.Mod(child, n)
+		if il.Cmp(n) < 0 && child.Sign() != 0 {
+			return &HDKey{CurveType: k.CurveType, Privkey: padScalar(child.Bytes()), ChainCode: I[32:], Depth: k.Depth + 1, Index: index}, nil
+		}
+		I = hmacSHA512(k.ChainCode, []byte{0x01}, I[32:], ser32[:])
+	}
+}
+
+// ParseDerivationPath parses the path string like "m/44'/0'/0'/0/1" into the list of indices
+func ParseDerivationPath(path string) ([]uint32, error) {
+	elements := strings.Split(strings.TrimSpace(path), "/")
+	if len(elements) == 0 || elements[0] != "m" {
+		return nil, fmt.Errorf("derivation path must start with \"m\": %s", path)
+	}
+	var indices []uint32
+	for _, e := range elements[1:] {
+		offset := uint32(0)
+		if strings.HasSuffix(e, "'") || strings.HasSuffix(e, "H") || strings.HasSuffix(e, "h") {
+			offset = HardenedKeyStart
+			e = e[:len(e)-1]
+		}
+		idx, err := strconv.ParseUint(e, 10, 32)
+		if err != nil || idx >= HardenedKeyStart {
+			return nil, fmt.Errorf("invalid index in derivation path: %s", path)
+		}
+		indices = append(indices, uint32(idx)+offset)
+	}
+	return indices, nil
+}
+
+// Derive derives the descendant HDKey specified by the path string (e.g., "m/44'/0'/0'/0/1")
+func (k *HDKey) Derive(path string) (*HDKey, error) {
+	indices, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	key := k
+	for _, idx := range indices {
+		if key, err = key.Child(idx); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// KeyPair returns the KeyPair object of the HDKey
+func (k *HDKey) KeyPair(compressionMode int) KeyPair {
+	privkey := append([]byte{}, k.Privkey...)
+	if k.CurveType == KeyTypeEd25519 {
+		pubkey := ed25519.NewKeyFromSeed(privkey).Public().(ed25519.PublicKey)
+		return KeyPair{CurveType: k.CurveType, Pubkey: []byte(pubkey), Privkey: privkey}
+	}
+	return KeyPair{CurveType: k.CurveType, Pubkey: ecdsaPublicKey(k.CurveType, privkey, compressionMode), Privkey: privkey}
+}
+
+// DeriveIdentity derives the KeyPair specified by the path string and the userID from its public key (GetIdentifierFromPubkey)
+func (k *HDKey) DeriveIdentity(path string, compressionMode int, idLength int) (KeyPair, []byte, error) {
+	key, err := k.Derive(path)
+	if err != nil {
+		return KeyPair{}, nil, err
+	}
+	keypair := key.KeyPair(compressionMode)
+	return keypair, GetIdentifierFromPubkey(keypair.Pubkey, idLength), nil
+}
@@ -0,0 +1,122 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// Test vector 1 of BIP32 and SLIP-0010
+var hdTestVectors = []struct {
+	curveType int
+	path      string
+	chainCode string
+	privkey   string
+}{
+	{KeyTypeEcdsaSECP256k1, "m", "873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508", "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35"},
+	{KeyTypeEcdsaSECP256k1, "m/0'", "47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141", "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+	{KeyTypeEcdsaSECP256k1, "m/0'/1", "2a7857631386ba23dacac34180dd1983734e444fdbf774041578e9b6adb37c19", "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+	{KeyTypeEcdsaP256v1, "m", "beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea", "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2"},
+	{KeyTypeEcdsaP256v1, "m/0'", "3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11", "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c"},
+	{KeyTypeEcdsaP256v1, "m/0'/1", "4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c", "284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129"},
+	{KeyTypeEd25519, "m", "90046a93de5380a72b5e45010748567d5ea02bbf6522f979e05c0d8d8ca9fffb", "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7"},
+	{KeyTypeEd25519, "m/0'", "8b59aa11380b624e81507a27fedda59fea6d0b779a778918a2fd3590e16e9c69", "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3"},
+}
+
+func TestHDKeyDerive(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	for _, v := range hdTestVectors {
+		t.Run(v.path, func(t *testing.T) {
+			master, err := NewMasterKey(v.curveType, seed)
+			if err != nil {
+				t.Fatalf("failed to generate master key (%v)", err)
+			}
+			key, err := master.Derive(v.path)
+			if err != nil {
+				t.Fatalf("failed to derive key (%v)", err)
+			}
+			if hex.EncodeToString(key.ChainCode) != v.chainCode || hex.EncodeToString(key.Privkey) != v.privkey {
+				t.Fatalf("key type %d, %s: chain code %x, private key %x", v.curveType, v.path, key.ChainCode, key.Privkey)
+			}
+		})
+	}
+}
+
+func TestMnemonicToSeed(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	seed := MnemonicToSeed(mnemonic, "TREZOR")
+	expected := "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"
+	if hex.EncodeToString(seed) != expected {
+		t.Fatalf("invalid seed: %x", seed)
+	}
+}
+
+func TestHDKeyDeriveIdentity(t *testing.T) {
+	digest := sha256.Sum256([]byte("aaaaaaaaaaa"))
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+	for keyType := KeyTypeEcdsaSECP256k1; keyType <= KeyTypeEd25519; keyType++ {
+		t.Run("key type", func(t *testing.T) {
+			master, err := NewMasterKeyFromMnemonic(keyType, mnemonic, "")
+			if err != nil {
+				t.Fatalf("failed to generate master key (%v)", err)
+			}
+			keypair1, userID1, err := master.DeriveIdentity("m/44'/0'/0'/1'", defaultCompressionMode, defaultIDLength)
+			if err != nil {
+				t.Fatalf("failed to derive identity (%v)", err)
+			}
+			keypair2, userID2, _ := master.DeriveIdentity("m/44'/0'/0'/2'", defaultCompressionMode, defaultIDLength)
+			again, _ := NewMasterKeyFromMnemonic(keyType, mnemonic, "")
+			keypair3, userID3, _ := again.DeriveIdentity("m/44'/0'/0'/1'", defaultCompressionMode, defaultIDLength)
+
+			if bytes.Equal(userID1, userID2) || bytes.Equal(keypair1.Privkey, keypair2.Privkey) {
+				t.Fatal("different paths must result in different identities")
+			}
+			if !bytes.Equal(userID1, userID3) || !sameKeyPair(&keypair1, &keypair3) {
+				t.Fatal("identity must be regenerated from the same mnemonic")
+			}
+			if !bytes.Equal(userID1, GetIdentifierFromPubkey(keypair1.Pubkey, defaultIDLength)) {
+				t.Fatal("invalid userID")
+			}
+			if sig, err := keypair1.Sign(digest[:]); err != nil || !keypair1.Verify(digest[:], sig) {
+				t.Fatal("fail to verify")
+			}
+		})
+	}
+}
+
+func TestHDKeyErrors(t *testing.T) {
+	master, _ := NewMasterKey(KeyTypeEd25519, GetRandomValue(32))
+	if _, err := master.Derive("m/0"); err == nil {
+		t.Fatal("non-hardened derivation must be rejected for Ed25519")
+	}
+	if _, err := NewMasterKey(KeyTypeEcdsaP256v1, GetRandomValue(8)); err == nil {
+		t.Fatal("too short seed must be rejected")
+	}
+	for _, path := range []string{"", "0/1", "m/a", "m/2147483648", "m//1"} {
+		if _, err := ParseDerivationPath(path); err == nil {
+			t.Fatalf("invalid path must be rejected: %s", path)
+		}
+	}
+	indices, err := ParseDerivationPath("m/44'/0H/1")
+	if err != nil || len(indices) != 3 || indices[0] != HardenedKeyStart+44 || indices[1] != HardenedKeyStart || indices[2] != 1 {
+		t.Fatalf("failed to parse path (%v)", err)
+	}
+}
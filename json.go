@@ -0,0 +1,532 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+/*
+JSON representation
+
+BBcTransaction and all sub-objects implement json.Marshaler and json.Unmarshaler. IDs and other binary data are expressed in hex strings,
+and the keys are the same as those in the output of Stringer() (e.g., "asset_group_id").
+
+An asset body encoded by an AssetBodyCodec (e.g., MessagePack for AssetBodyType = 1) is decoded into a JSON object in "asset_body".
+Since the encoded data cannot be reproduced from the JSON object byte by byte, the original data is also included in "asset_body_raw".
+"asset_body_raw" is used in unmarshaling if it exists, so that the JSON round trip reproduces the same AssetID and TransactionID.
+A string asset body (AssetBodyType = 0) in UTF-8 is expressed as a JSON string.
+
+The values which are determined by others (e.g., IDLength of sub-objects, AssetBodySize and PubkeyLen) are not included in the JSON representation.
+UnmarshalJSON of BBcTransaction returns an error if "transaction_id" does not match the digest of the unmarshaled transaction.
+*/
+type (
+	hexBytes []byte
+
+	jsonTransaction struct {
+		TransactionID hexBytes        `json:"transaction_id,omitempty"`
+		Version       uint32          `json:"version"`
+		Timestamp     int64           `json:"timestamp"`
+		IDLength      int             `json:"id_length"`
+		Extensions    []jsonExtension `json:"extensions,omitempty"`
+		Events        []*BBcEvent     `json:"events"`
+		References    []*BBcReference `json:"references"`
+		Relations     []*BBcRelation  `json:"relations"`
+		Witness       *BBcWitness     `json:"witness"`
+		Crossref      *BBcCrossRef    `json:"cross_ref"`
+		Signatures    []*BBcSignature `json:"signatures"`
+	}
+
+	jsonExtension struct {
+		Type uint16   `json:"type"`
+		Data hexBytes `json:"data"`
+	}
+
+	jsonEvent struct {
+		AssetGroupID                 hexBytes   `json:"asset_group_id"`
+		ReferenceIndices             []int      `json:"reference_indices"`
+		MandatoryApprovers           []hexBytes `json:"mandatory_approvers"`
+		OptionApproverNumNumerator   uint16     `json:"option_approver_num_numerator"`
+		OptionApproverNumDenominator uint16     `json:"option_approver_num_denominator"`
+		OptionApprovers              []hexBytes `json:"option_approvers"`
+		Asset                        *BBcAsset  `json:"asset"`
+	}
+
+	jsonReference struct {
+		AssetGroupID    hexBytes `json:"asset_group_id"`
+		TransactionID   hexBytes `json:"transaction_id"`
+		EventIndexInRef uint16   `json:"event_index_in_ref"`
+		SigIndices      []int    `json:"sig_indices"`
+	}
+
+	jsonRelation struct {
+		AssetGroupID hexBytes      `json:"asset_group_id"`
+		Pointers     []*BBcPointer `json:"pointers"`
+		Asset        *BBcAsset     `json:"asset"`
+	}
+
+	jsonPointer struct {
+		TransactionID hexBytes `json:"transaction_id"`
+		AssetID       hexBytes `json:"asset_id"`
+	}
+
+	jsonWitness struct {
+		UserIDs    []hexBytes `json:"user_ids"`
+		SigIndices []int      `json:"sig_indices"`
+	}
+
+	jsonCrossRef struct {
+		DomainID      hexBytes `json:"domain_id"`
+		TransactionID hexBytes `json:"transaction_id"`
+	}
+
+	jsonAsset struct {
+		AssetID         hexBytes        `json:"asset_id,omitempty"`
+		UserID          hexBytes        `json:"user_id"`
+		Nonce           hexBytes        `json:"nonce"`
+		AssetFileSize   uint64          `json:"asset_file_size"`
+		AssetFileDigest hexBytes        `json:"asset_file_digest,omitempty"`
+		AssetBodyType   uint16          `json:"asset_body_type"`
+		AssetBody       json.RawMessage `json:"asset_body,omitempty"`
+		AssetBodyRaw    hexBytes        `json:"asset_body_raw,omitempty"`
+	}
+
+	jsonSignature struct {
+		KeyType   uint32   `json:"key_type"`
+		Pubkey    hexBytes `json:"pubkey,omitempty"`
+		Signature hexBytes `json:"signature,omitempty"`
+	}
+)
+
+// MarshalJSON encodes the binary data in a hex string (nil is encoded in null)
+func (h hexBytes) MarshalJSON() ([]byte, error) {
+	if h == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(hex.EncodeToString(h))
+}
+
+// UnmarshalJSON decodes a hex string
+func (h *hexBytes) UnmarshalJSON(dat []byte) error {
+	if string(dat) == "null" {
+		*h = nil
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(dat, &s); err != nil {
+		return err
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*h = b
+	return nil
+}
+
+// toHexList converts the list of binary data for JSON encoding
+func toHexList(list [][]byte) []hexBytes {
+	if list == nil {
+		return nil
+	}
+	ret := make([]hexBytes, len(list))
+	for i := range list {
+		ret[i] = list[i]
+	}
+	return ret
+}
+
+// fromHexList converts the list of decoded binary data
+func fromHexList(list []hexBytes) [][]byte {
+	if list == nil {
+		return nil
+	}
+	ret := make([][]byte, len(list))
+	for i := range list {
+		ret[i] = list[i]
+	}
+	return ret
+}
+
+// jsonCompatible converts the object decoded from MessagePack into the one which can be encoded in JSON
+func jsonCompatible(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, e := range val {
+			if b, ok := k.([]byte); ok {
+				k = string(b)
+			}
+			m[fmt.Sprint(k)] = jsonCompatible(e)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, e := range val {
+			m[k] = jsonCompatible(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(val))
+		for i := range val {
+			l[i] = jsonCompatible(val[i])
+		}
+		return l
+	case []byte:
+		if utf8.Valid(val) {
+			return string(val)
+		}
+		return hex.EncodeToString(val)
+	}
+	return v
+}
+
+// MarshalJSON returns the JSON representation of the BBcTransaction object (transaction_id is omitted if TransactionID is not set)
+func (p *BBcTransaction) MarshalJSON() ([]byte, error) {
+	var extensions []jsonExtension
+	for _, ext := range p.Extensions {
+		extensions = append(extensions, jsonExtension{Type: ext.Type, Data: ext.Data})
+	}
+	return json.Marshal(&jsonTransaction{
+		TransactionID: p.TransactionID,
+		Version:       p.Version,
+		Timestamp:     p.Timestamp,
+		IDLength:      p.IDLength,
+		Extensions:    extensions,
+		Events:        p.Events,
+		References:    p.References,
+		Relations:     p.Relations,
+		Witness:       p.Witness,
+		Crossref:      p.Crossref,
+		Signatures:    p.Signatures,
+	})
+}
+
+// UnmarshalJSON sets the BBcTransaction object from the JSON representation and checks the TransactionID
+func (p *BBcTransaction) UnmarshalJSON(dat []byte) error {
+	var j jsonTransaction
+	if err := json.Unmarshal(dat, &j); err != nil {
+		return err
+	}
+	*p = BBcTransaction{Version: j.Version, Timestamp: j.Timestamp, IDLength: j.IDLength}
+	for _, ext := range j.Extensions {
+		p.AddExtension(ext.Type, ext.Data)
+	}
+	// the assets are made in the form of the transaction, since the given asset_id depends on it
+	form := p.assetForm()
+	for _, obj := range j.Events {
+		if obj == nil {
+			return errors.New("null in events")
+		}
+		p.AddEvent(obj)
+		obj.assetForm = form
+		if obj.Asset != nil {
+			obj.Asset.IDLength = p.IDLength
+			obj.Asset.form = form
+		}
+	}
+	for _, obj := range j.References {
+		if obj == nil {
+			return errors.New("null in references")
+		}
+		p.AddReference(obj)
+	}
+	for _, obj := range j.Relations {
+		if obj == nil {
+			return errors.New("null in relations")
+		}
+		p.AddRelation(obj)
+		for _, ptr := range obj.Pointers {
+			if ptr == nil {
+				return errors.New("null in pointers")
+			}
+			ptr.IDLength = p.IDLength
+		}
+		obj.assetForm = form
+		if obj.Asset != nil {
+			obj.Asset.IDLength = p.IDLength
+			obj.Asset.form = form
+		}
+	}
+	if j.Witness != nil {
+		p.AddWitness(j.Witness)
+	}
+	if j.Crossref != nil {
+		p.AddCrossRef(j.Crossref)
+	}
+	for _, obj := range j.Signatures {
+		if obj == nil {
+			return errors.New("null in signatures")
+		}
+		p.Signatures = append(p.Signatures, obj)
+	}
+
+	p.Digest()
+	if j.TransactionID != nil && !bytes.Equal(j.TransactionID, p.TransactionID) {
+		return fmt.Errorf("transaction_id mismatch (%x is given but %x is calculated)", []byte(j.TransactionID), p.TransactionID)
+	}
+	return nil
+}
+
+// MarshalJSON returns the JSON representation of the BBcEvent object
+func (p *BBcEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&jsonEvent{
+		AssetGroupID:                 p.AssetGroupID,
+		ReferenceIndices:             p.ReferenceIndices,
+		MandatoryApprovers:           toHexList(p.MandatoryApprovers),
+		OptionApproverNumNumerator:   p.OptionApproverNumNumerator,
+		OptionApproverNumDenominator: p.OptionApproverNumDenominator,
+		OptionApprovers:              toHexList(p.OptionApprovers),
+		Asset:                        p.Asset,
+	})
+}
+
+// UnmarshalJSON sets the BBcEvent object from the JSON representation
+func (p *BBcEvent) UnmarshalJSON(dat []byte) error {
+	var j jsonEvent
+	if err := json.Unmarshal(dat, &j); err != nil {
+		return err
+	}
+	*p = BBcEvent{
+		IDLength:                     len(j.AssetGroupID),
+		AssetGroupID:                 j.AssetGroupID,
+		ReferenceIndices:             j.ReferenceIndices,
+		MandatoryApprovers:           fromHexList(j.MandatoryApprovers),
+		OptionApproverNumNumerator:   j.OptionApproverNumNumerator,
+		OptionApproverNumDenominator: j.OptionApproverNumDenominator,
+		OptionApprovers:              fromHexList(j.OptionApprovers),
+		Asset:                        j.Asset,
+	}
+	return nil
+}
+
+// MarshalJSON returns the JSON representation of the BBcReference object
+func (p *BBcReference) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&jsonReference{
+		AssetGroupID:    p.AssetGroupID,
+		TransactionID:   p.TransactionID,
+		EventIndexInRef: p.EventIndexInRef,
+		SigIndices:      p.SigIndices,
+	})
+}
+
+// UnmarshalJSON sets the BBcReference object from the JSON representation
+func (p *BBcReference) UnmarshalJSON(dat []byte) error {
+	var j jsonReference
+	if err := json.Unmarshal(dat, &j); err != nil {
+		return err
+	}
+	*p = BBcReference{
+		IDLength:        len(j.AssetGroupID),
+		AssetGroupID:    j.AssetGroupID,
+		TransactionID:   j.TransactionID,
+		EventIndexInRef: j.EventIndexInRef,
+		SigIndices:      j.SigIndices,
+	}
+	return nil
+}
+
+// MarshalJSON returns the JSON representation of the BBcRelation object
+func (p *BBcRelation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&jsonRelation{
+		AssetGroupID: p.AssetGroupID,
+		Pointers:     p.Pointers,
+		Asset:        p.Asset,
+	})
+}
+
+// UnmarshalJSON sets the BBcRelation object from the JSON representation
+func (p *BBcRelation) UnmarshalJSON(dat []byte) error {
+	var j jsonRelation
+	if err := json.Unmarshal(dat, &j); err != nil {
+		return err
+	}
+	*p = BBcRelation{
+		IDLength:     len(j.AssetGroupID),
+		AssetGroupID: j.AssetGroupID,
+		Pointers:     j.Pointers,
+		Asset:        j.Asset,
+	}
+	return nil
+}
+
+// MarshalJSON returns the JSON representation of the BBcPointer object
+func (p *BBcPointer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&jsonPointer{
+		TransactionID: p.TransactionID,
+		AssetID:       p.AssetID,
+	})
+}
+
+// UnmarshalJSON sets the BBcPointer object from the JSON representation
+func (p *BBcPointer) UnmarshalJSON(dat []byte) error {
+	var j jsonPointer
+	if err := json.Unmarshal(dat, &j); err != nil {
+		return err
+	}
+	*p = BBcPointer{
+		IDLength:      len(j.TransactionID),
+		TransactionID: j.TransactionID,
+		AssetID:       j.AssetID,
+	}
+	return nil
+}
+
+// MarshalJSON returns the JSON representation of the BBcWitness object
+func (p *BBcWitness) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&jsonWitness{
+		UserIDs:    toHexList(p.UserIDs),
+		SigIndices: p.SigIndices,
+	})
+}
+
+// UnmarshalJSON sets the BBcWitness object from the JSON representation
+func (p *BBcWitness) UnmarshalJSON(dat []byte) error {
+	var j jsonWitness
+	if err := json.Unmarshal(dat, &j); err != nil {
+		return err
+	}
+	if len(j.UserIDs) != len(j.SigIndices) {
+		return errors.New("the numbers of user_ids and sig_indices are different")
+	}
+	*p = BBcWitness{
+		UserIDs:    fromHexList(j.UserIDs),
+		SigIndices: j.SigIndices,
+	}
+	if len(p.UserIDs) > 0 {
+		p.IDLength = len(p.UserIDs[0])
+	}
+	return nil
+}
+
+// MarshalJSON returns the JSON representation of the BBcCrossRef object
+func (p *BBcCrossRef) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&jsonCrossRef{
+		DomainID:      p.DomainID,
+		TransactionID: p.TransactionID,
+	})
+}
+
+// UnmarshalJSON sets the BBcCrossRef object from the JSON representation
+func (p *BBcCrossRef) UnmarshalJSON(dat []byte) error {
+	var j jsonCrossRef
+	if err := json.Unmarshal(dat, &j); err != nil {
+		return err
+	}
+	*p = BBcCrossRef{
+		IDLength:      len(j.TransactionID),
+		DomainID:      j.DomainID,
+		TransactionID: j.TransactionID,
+	}
+	return nil
+}
+
+// MarshalJSON returns the JSON representation of the BBcAsset object (asset_id is omitted if AssetID is not set)
+func (p *BBcAsset) MarshalJSON() ([]byte, error) {
+	j := jsonAsset{
+		AssetID:         p.AssetID,
+		UserID:          p.UserID,
+		Nonce:           p.Nonce,
+		AssetFileSize:   p.AssetFileSize,
+		AssetFileDigest: p.AssetFileDigest,
+		AssetBodyType:   p.AssetBodyType,
+	}
+	if len(p.AssetBody) > 0 {
+		j.AssetBodyRaw = p.AssetBody
+		if p.AssetBodyType == 0 && utf8.Valid(p.AssetBody) {
+			j.AssetBody, _ = json.Marshal(string(p.AssetBody))
+			j.AssetBodyRaw = nil
+		} else if obj, err := p.GetBodyObject(); err == nil && obj != nil {
+			j.AssetBody, _ = json.Marshal(jsonCompatible(obj))
+		}
+	}
+	return json.Marshal(&j)
+}
+
+// UnmarshalJSON sets the BBcAsset object from the JSON representation
+//
+// If "asset_body_raw" does not exist, the asset body is made from "asset_body" (a JSON object is encoded by the AssetBodyCodec of asset_body_type).
+func (p *BBcAsset) UnmarshalJSON(dat []byte) error {
+	var j jsonAsset
+	if err := json.Unmarshal(dat, &j); err != nil {
+		return err
+	}
+	*p = BBcAsset{
+		IDLength:        len(j.UserID),
+		AssetID:         j.AssetID,
+		UserID:          j.UserID,
+		Nonce:           j.Nonce,
+		AssetFileSize:   j.AssetFileSize,
+		AssetFileDigest: j.AssetFileDigest,
+		AssetBodyType:   j.AssetBodyType,
+	}
+
+	switch {
+	case j.AssetBodyRaw != nil:
+		p.AssetBody = j.AssetBodyRaw
+	case len(j.AssetBody) == 0 || string(j.AssetBody) == "null":
+	case j.AssetBodyType == 0:
+		var s string
+		if err := json.Unmarshal(j.AssetBody, &s); err != nil {
+			return err
+		}
+		p.AssetBody = []byte(s)
+	default:
+		var obj interface{}
+		if err := json.Unmarshal(j.AssetBody, &obj); err != nil {
+			return err
+		}
+		if err := p.AddBodyObjectWithType(j.AssetBodyType, obj); err != nil {
+			return fmt.Errorf("asset_body_raw is needed for asset_body_type %d (%v)", j.AssetBodyType, err)
+		}
+	}
+	if uint64(len(p.AssetBody)) > maxExtendedAssetBodySize {
+		return errors.New("asset body is too large")
+	}
+	p.AssetBodySize = uint32(len(p.AssetBody))
+	return nil
+}
+
+// MarshalJSON returns the JSON representation of the BBcSignature object
+func (p *BBcSignature) MarshalJSON() ([]byte, error) {
+	j := jsonSignature{KeyType: p.KeyType}
+	if p.KeyType != KeyTypeNotInitialized {
+		j.Pubkey = p.Pubkey
+		j.Signature = p.Signature
+	}
+	return json.Marshal(&j)
+}
+
+// UnmarshalJSON sets the BBcSignature object from the JSON representation
+func (p *BBcSignature) UnmarshalJSON(dat []byte) error {
+	var j jsonSignature
+	if err := json.Unmarshal(dat, &j); err != nil {
+		return err
+	}
+	*p = BBcSignature{KeyType: j.KeyType}
+	if j.KeyType != KeyTypeNotInitialized {
+		pubkey, sig := []byte(j.Pubkey), []byte(j.Signature)
+		p.SetPublicKey(j.KeyType, &pubkey)
+		p.SetSignature(&sig)
+	}
+	return nil
+}
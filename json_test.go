@@ -0,0 +1,132 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTransactionJSON(t *testing.T) {
+	txobj := makeDecodeTestTransaction(t)
+
+	t.Run("round trip", func(t *testing.T) {
+		dat, err := json.MarshalIndent(txobj, "", "  ")
+		if err != nil {
+			t.Fatalf("failed to marshal (%v)", err)
+		}
+		t.Logf("%s", dat)
+
+		obj := BBcTransaction{}
+		if err := json.Unmarshal(dat, &obj); err != nil {
+			t.Fatalf("failed to unmarshal (%v)", err)
+		}
+		if !bytes.Equal(obj.TransactionID, txobj.TransactionID) {
+			t.Fatal("Not recovered correctly...")
+		}
+		if result, idx := obj.VerifyAll(); !result {
+			t.Fatalf("Verification failed..signature[%d]", idx)
+		}
+		packed1, _ := txobj.Pack()
+		packed2, _ := obj.Pack()
+		if !bytes.Equal(packed1, packed2) {
+			t.Fatal("Not recovered correctly...")
+		}
+		if obj.References[0].Transaction != &obj || obj.Witness.Transaction != &obj || obj.Events[0].Asset.IDLength != defaultIDLength {
+			t.Fatal("sub-objects must be linked to the transaction")
+		}
+	})
+
+	t.Run("readable asset body", func(t *testing.T) {
+		dat, _ := json.Marshal(txobj)
+		var generic map[string]interface{}
+		if err := json.Unmarshal(dat, &generic); err != nil {
+			t.Fatalf("failed to unmarshal (%v)", err)
+		}
+		event := generic["events"].([]interface{})[0].(map[string]interface{})
+		body := event["asset"].(map[string]interface{})["asset_body"].(map[string]interface{})
+		if body["param1"] != "aaa" {
+			t.Fatalf("asset body must be decoded into JSON object: %v", body)
+		}
+		relation := generic["relations"].([]interface{})[0].(map[string]interface{})
+		if relation["asset"].(map[string]interface{})["asset_body"] != "relation" {
+			t.Fatal("string asset body must be JSON string")
+		}
+	})
+
+	t.Run("transaction_id mismatch", func(t *testing.T) {
+		dat, _ := json.Marshal(txobj)
+		tampered := strings.Replace(string(dat), `"version":1`, `"version":2`, 1)
+		obj := BBcTransaction{}
+		if err := json.Unmarshal([]byte(tampered), &obj); err == nil {
+			t.Fatal("tampered transaction must be rejected")
+		}
+	})
+
+	t.Run("receiver not modified", func(t *testing.T) {
+		assetGroupID := GetIdentifier("asset_group_id_decode", defaultIDLength)
+		u1 := GetIdentifier("user1_789abcdef0123456789abcdef0", defaultIDLength)
+		obj := MakeTransaction(1, 0, false, defaultIDLength)
+		AddEventAssetBodyString(obj, 0, &assetGroupID, &u1, "teststring!!!!!")
+		obj.Events[0].Asset.AssetID = nil
+		timestamp := obj.Timestamp
+
+		dat, err := json.Marshal(obj)
+		if err != nil {
+			t.Fatalf("failed to marshal (%v)", err)
+		}
+		if obj.TransactionID != nil || obj.TransactionBaseDigest != nil || obj.Events[0].Asset.AssetID != nil || obj.Timestamp != timestamp {
+			t.Fatal("MarshalJSON must not modify the transaction")
+		}
+		if strings.Contains(string(dat), "transaction_id") || strings.Contains(string(dat), "asset_id") {
+			t.Fatalf("IDs which are not set must be omitted: %s", dat)
+		}
+
+		recovered := BBcTransaction{}
+		if err := json.Unmarshal(dat, &recovered); err != nil {
+			t.Fatalf("failed to unmarshal (%v)", err)
+		}
+		if !bytes.Equal(recovered.TransactionID, obj.Digest()) {
+			t.Fatal("Not recovered correctly...")
+		}
+	})
+
+	t.Run("asset without raw body", func(t *testing.T) {
+		u1 := GetIdentifier("user1_789abcdef0123456789abcdef0", defaultIDLength)
+		dat := `{"user_id":"` + hex.EncodeToString(u1) + `","nonce":"00","asset_file_size":0,"asset_body_type":1,"asset_body":{"param1":"aaa"}}`
+		obj := BBcAsset{}
+		if err := json.Unmarshal([]byte(dat), &obj); err != nil {
+			t.Fatalf("failed to unmarshal (%v)", err)
+		}
+		body, err := obj.GetBodyObject()
+		if err != nil || obj.IDLength != defaultIDLength || int(obj.AssetBodySize) != len(obj.AssetBody) {
+			t.Fatalf("Not recovered correctly... (%v)", err)
+		}
+		t.Logf("%v", body)
+	})
+
+	t.Run("not initialized signature", func(t *testing.T) {
+		sig := BBcSignature{}
+		dat, _ := json.Marshal(&sig)
+		if string(dat) != `{"key_type":0}` {
+			t.Fatalf("unexpected JSON: %s", dat)
+		}
+	})
+}
@@ -0,0 +1,571 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"math/big"
+)
+
+/*
+Key import/export
+
+A KeyPair object can be converted from/to the following formats.
+  private key: PKCS#8 or SEC1 (RFC5915), in DER or PEM
+  public key:  SubjectPublicKeyInfo (RFC5280), in DER or PEM
+  both:        JWK (RFC7517, RFC7518, RFC8037 and RFC8812)
+SEC1 is available only for ECDSA keys. compressionMode specifies the encoding of the public key set in the KeyPair object (ignored for Ed25519).
+*/
+
+// Formats of the private key for ConvertToDer and ConvertToPem
+const (
+	KeyFormatPKCS8 = 0
+	KeyFormatSEC1  = 1
+)
+
+// PEM block types
+const (
+	pemTypeECPrivateKey = "EC PRIVATE KEY"
+	pemTypeECParameters = "EC PARAMETERS"
+	pemTypePrivateKey   = "PRIVATE KEY"
+	pemTypePublicKey    = "PUBLIC KEY"
+)
+
+// Object identifiers of the public key algorithms and the named curves
+var (
+	oidPublicKeyECDSA      = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidPublicKeyEd25519    = asn1.ObjectIdentifier{1, 3, 101, 112}
+	oidNamedCurveSECP256k1 = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+	oidNamedCurveP256v1    = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+)
+
+type (
+	// ecPrivateKey is the ASN.1 structure of the SEC1 EC private key (RFC5915)
+	ecPrivateKey struct {
+		Version       int
+		PrivateKey    []byte
+		NamedCurveOID asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+		PublicKey     asn1.BitString        `asn1:"optional,explicit,tag:1"`
+	}
+
+	// pkcs8PrivateKey is the ASN.1 structure of PKCS#8 private key (RFC5208, attributes are omitted)
+	pkcs8PrivateKey struct {
+		Version    int
+		Algo       pkix.AlgorithmIdentifier
+		PrivateKey []byte
+	}
+
+	// subjectPublicKeyInfo is the ASN.1 structure of the public key (RFC5280)
+	subjectPublicKeyInfo struct {
+		Algo      pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+
+	// jsonWebKey is the JSON structure of JWK
+	jsonWebKey struct {
+		Kty string `json:"kty"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y,omitempty"`
+		D   string `json:"d,omitempty"`
+	}
+)
+
+// namedCurveOID returns the object identifier of the curve
+func namedCurveOID(curveType int) (asn1.ObjectIdentifier, error) {
+	switch curveType {
+	case KeyTypeEcdsaSECP256k1:
+		return oidNamedCurveSECP256k1, nil
+	case KeyTypeEcdsaP256v1:
+		return oidNamedCurveP256v1, nil
+	}
+	return nil, fmt.Errorf("not supported curve type: %d", curveType)
+}
+
+// curveTypeFromOID returns the curve type for the object identifier of the named curve
+func curveTypeFromOID(oid asn1.ObjectIdentifier) (int, error) {
+	switch {
+	case oid.Equal(oidNamedCurveSECP256k1):
+		return KeyTypeEcdsaSECP256k1, nil
+	case oid.Equal(oidNamedCurveP256v1):
+		return KeyTypeEcdsaP256v1, nil
+	}
+	return KeyTypeNotInitialized, fmt.Errorf("not supported named curve: %v", oid)
+}
+
+// checkCompressionMode returns an error if compressionMode is not a valid value
+func checkCompressionMode(compressionMode int) error {
+	switch compressionMode {
+	case CompressionModeCompressed, CompressionModeUncompressed, CompressionModeHybrid:
+		return nil
+	}
+	return fmt.Errorf("invalid compression mode: %d", compressionMode)
+}
+
+// checkPrivateKey returns an error if privkey is not a valid private key for the key type
+func checkPrivateKey(curveType int, privkey []byte) error {
+	switch curveType {
+	case KeyTypeEd25519:
+		if len(privkey) != ed25519.SeedSize {
+			return errors.New("invalid length of Ed25519 private key")
+		}
+		return nil
+	case KeyTypeEcdsaSECP256k1:
+		var d secp256k1.ModNScalar
+		if len(privkey) > ecdsaScalarLength || d.SetByteSlice(privkey) || d.IsZero() {
+			return errors.New("invalid secp256k1 private key")
+		}
+		return nil
+	case KeyTypeEcdsaP256v1:
+		d := new(big.Int).SetBytes(privkey)
+		if len(privkey) > ecdsaScalarLength || d.Sign() == 0 || d.Cmp(elliptic.P256().Params().N) >= 0 {
+			return errors.New("invalid prime256v1 private key")
+		}
+		return nil
+	}
+	return fmt.Errorf("not supported key type: %d", curveType)
+}
+
+// ConvertPublicKey converts the public key into the encoding specified by compressionMode
+func ConvertPublicKey(curveType int, pubkey []byte, compressionMode int) ([]byte, error) {
+	if curveType == KeyTypeEd25519 {
+		if len(pubkey) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid length of Ed25519 public key")
+		}
+		return append([]byte{}, pubkey...), nil
+	}
+	if err := checkCompressionMode(compressionMode); err != nil {
+		return nil, err
+	}
+	x, y, err := decodePoint(curveType, pubkey)
+	if err != nil {
+		return nil, err
+	}
+	return encodePoint(x, y, compressionMode), nil
+}
+
+// SetCompressionMode converts the public key in the object into the encoding specified by compressionMode
+func (k *KeyPair) SetCompressionMode(compressionMode int) error {
+	pubkey, err := ConvertPublicKey(k.CurveType, k.Pubkey, compressionMode)
+	if err != nil {
+		return err
+	}
+	k.Pubkey = pubkey
+	return nil
+}
+
+// setPrivateKey sets the private key and the public key calculated from it in the object
+func (k *KeyPair) setPrivateKey(curveType int, privkey []byte, compressionMode int) error {
+	if err := checkPrivateKey(curveType, privkey); err != nil {
+		return err
+	}
+	if curveType == KeyTypeEd25519 {
+		k.CurveType = curveType
+		k.Pubkey = []byte(ed25519.NewKeyFromSeed(privkey).Public().(ed25519.PublicKey))
+		k.Privkey = append([]byte{}, privkey...)
+		return nil
+	}
+	if err := checkCompressionMode(compressionMode); err != nil {
+		return err
+	}
+	k.CurveType = curveType
+	k.Privkey = padScalar(privkey)
+	k.Pubkey = ecdsaPublicKey(curveType, k.Privkey, compressionMode)
+	return nil
+}
+
+// samePublicKey returns true if two encoded public keys express the same point
+func samePublicKey(curveType int, pubkey1, pubkey2 []byte) bool {
+	p1, err := ConvertPublicKey(curveType, pubkey1, CompressionModeUncompressed)
+	if err != nil {
+		return false
+	}
+	p2, err := ConvertPublicKey(curveType, pubkey2, CompressionModeUncompressed)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(p1, p2)
+}
+
+// convertFromSEC1 reads a SEC1 private key (curveOID is used if the named curve is omitted in the data)
+func (k *KeyPair) convertFromSEC1(der []byte, curveOID asn1.ObjectIdentifier, compressionMode int) error {
+	var key ecPrivateKey
+	rest, err := asn1.Unmarshal(der, &key)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return errors.New("trailing data after SEC1 private key")
+	}
+	if key.Version != 1 {
+		return fmt.Errorf("unknown SEC1 private key version: %d", key.Version)
+	}
+	if len(key.NamedCurveOID) > 0 {
+		curveOID = key.NamedCurveOID
+	}
+	curveType, err := curveTypeFromOID(curveOID)
+	if err != nil {
+		return err
+	}
+
+	obj := KeyPair{}
+	if err := obj.setPrivateKey(curveType, key.PrivateKey, compressionMode); err != nil {
+		return err
+	}
+	if key.PublicKey.BitLength > 0 && !samePublicKey(curveType, key.PublicKey.Bytes, obj.Pubkey) {
+		return errors.New("public key does not match the private key")
+	}
+	*k = obj
+	return nil
+}
+
+// convertFromPKCS8 reads a PKCS#8 private key
+func (k *KeyPair) convertFromPKCS8(der []byte, compressionMode int) error {
+	var key pkcs8PrivateKey
+	rest, err := asn1.Unmarshal(der, &key)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return errors.New("trailing data after PKCS#8 private key")
+	}
+
+	switch {
+	case key.Algo.Algorithm.Equal(oidPublicKeyEd25519):
+		var seed []byte
+		if _, err := asn1.Unmarshal(key.PrivateKey, &seed); err != nil {
+			return err
+		}
+		return k.setPrivateKey(KeyTypeEd25519, seed, compressionMode)
+	case key.Algo.Algorithm.Equal(oidPublicKeyECDSA):
+		var curveOID asn1.ObjectIdentifier
+		if _, err := asn1.Unmarshal(key.Algo.Parameters.FullBytes, &curveOID); err != nil {
+			return err
+		}
+		return k.convertFromSEC1(key.PrivateKey, curveOID, compressionMode)
+	}
+	return fmt.Errorf("not supported public key algorithm: %v", key.Algo.Algorithm)
+}
+
+// ConvertFromDer reads the private key in PKCS#8 or SEC1 DER format and sets the key pair in the object
+func (k *KeyPair) ConvertFromDer(der []byte, compressionMode int) error {
+	err := k.convertFromPKCS8(der, compressionMode)
+	if err == nil {
+		return nil
+	}
+	if err2 := k.convertFromSEC1(der, nil, compressionMode); err2 != nil {
+		return fmt.Errorf("neither PKCS#8 (%v) nor SEC1 (%v) private key", err, err2)
+	}
+	return nil
+}
+
+// ConvertFromPem reads the private key in PKCS#8 or SEC1 PEM format and sets the key pair in the object
+func (k *KeyPair) ConvertFromPem(pemString string, compressionMode int) error {
+	rest := []byte(pemString)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return errors.New("no private key found in PEM data")
+		}
+		switch block.Type {
+		case pemTypeECPrivateKey:
+			return k.convertFromSEC1(block.Bytes, nil, compressionMode)
+		case pemTypePrivateKey:
+			return k.convertFromPKCS8(block.Bytes, compressionMode)
+		case pemTypeECParameters:
+			continue
+		}
+		return fmt.Errorf("not supported PEM block type: %s", block.Type)
+	}
+}
+
+// ConvertToDer outputs the private key in PKCS#8 or SEC1 DER format (format is KeyFormatPKCS8 or KeyFormatSEC1)
+func (k *KeyPair) ConvertToDer(format int) ([]byte, error) {
+	if err := checkPrivateKey(k.CurveType, k.Privkey); err != nil {
+		return nil, err
+	}
+	if format != KeyFormatPKCS8 && format != KeyFormatSEC1 {
+		return nil, fmt.Errorf("not supported key format: %d", format)
+	}
+
+	if k.CurveType == KeyTypeEd25519 {
+		if format != KeyFormatPKCS8 {
+			return nil, errors.New("Ed25519 private key is available only in PKCS#8 format")
+		}
+		seed, err := asn1.Marshal(k.Privkey)
+		if err != nil {
+			return nil, err
+		}
+		return asn1.Marshal(pkcs8PrivateKey{
+			Algo:       pkix.AlgorithmIdentifier{Algorithm: oidPublicKeyEd25519},
+			PrivateKey: seed,
+		})
+	}
+
+	curveOID, err := namedCurveOID(k.CurveType)
+	if err != nil {
+		return nil, err
+	}
+	privkey := padScalar(k.Privkey)
+	pubkey := ecdsaPublicKey(k.CurveType, privkey, CompressionModeUncompressed)
+	key := ecPrivateKey{
+		Version:    1,
+		PrivateKey: privkey,
+		PublicKey:  asn1.BitString{Bytes: pubkey, BitLength: 8 * len(pubkey)},
+	}
+	if format == KeyFormatSEC1 {
+		key.NamedCurveOID = curveOID
+		return asn1.Marshal(key)
+	}
+
+	inner, err := asn1.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(curveOID)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(pkcs8PrivateKey{
+		Algo: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPublicKeyECDSA,
+			Parameters: asn1.RawValue{FullBytes: params},
+		},
+		PrivateKey: inner,
+	})
+}
+
+// ConvertToPem outputs the private key in PKCS#8 or SEC1 PEM format (format is KeyFormatPKCS8 or KeyFormatSEC1)
+func (k *KeyPair) ConvertToPem(format int) (string, error) {
+	der, err := k.ConvertToDer(format)
+	if err != nil {
+		return "", err
+	}
+	blockType := pemTypePrivateKey
+	if format == KeyFormatSEC1 {
+		blockType = pemTypeECPrivateKey
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})), nil
+}
+
+// ConvertPublicKeyFromDer reads the public key in SubjectPublicKeyInfo DER format and sets it in the object (Privkey is cleared)
+func (k *KeyPair) ConvertPublicKeyFromDer(der []byte, compressionMode int) error {
+	var spki subjectPublicKeyInfo
+	rest, err := asn1.Unmarshal(der, &spki)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return errors.New("trailing data after public key")
+	}
+
+	var curveType int
+	switch {
+	case spki.Algo.Algorithm.Equal(oidPublicKeyEd25519):
+		curveType = KeyTypeEd25519
+	case spki.Algo.Algorithm.Equal(oidPublicKeyECDSA):
+		var curveOID asn1.ObjectIdentifier
+		if _, err := asn1.Unmarshal(spki.Algo.Parameters.FullBytes, &curveOID); err != nil {
+			return err
+		}
+		if curveType, err = curveTypeFromOID(curveOID); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("not supported public key algorithm: %v", spki.Algo.Algorithm)
+	}
+
+	pubkey, err := ConvertPublicKey(curveType, spki.PublicKey.RightAlign(), compressionMode)
+	if err != nil {
+		return err
+	}
+	k.CurveType = curveType
+	k.Pubkey = pubkey
+	k.Privkey = nil
+	return nil
+}
+
+// ConvertPublicKeyFromPem reads the public key in SubjectPublicKeyInfo PEM format and sets it in the object (Privkey is cleared)
+func (k *KeyPair) ConvertPublicKeyFromPem(pemString string, compressionMode int) error {
+	block, _ := pem.Decode([]byte(pemString))
+	if block == nil {
+		return errors.New("no PEM data found")
+	}
+	if block.Type != pemTypePublicKey {
+		return fmt.Errorf("not supported PEM block type: %s", block.Type)
+	}
+	return k.ConvertPublicKeyFromDer(block.Bytes, compressionMode)
+}
+
+// ConvertPublicKeyToDer outputs the public key in SubjectPublicKeyInfo DER format (uncompressed point for ECDSA)
+func (k *KeyPair) ConvertPublicKeyToDer() ([]byte, error) {
+	if k.CurveType == KeyTypeEd25519 {
+		if len(k.Pubkey) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid length of Ed25519 public key")
+		}
+		return asn1.Marshal(subjectPublicKeyInfo{
+			Algo:      pkix.AlgorithmIdentifier{Algorithm: oidPublicKeyEd25519},
+			PublicKey: asn1.BitString{Bytes: k.Pubkey, BitLength: 8 * len(k.Pubkey)},
+		})
+	}
+
+	curveOID, err := namedCurveOID(k.CurveType)
+	if err != nil {
+		return nil, err
+	}
+	pubkey, err := ConvertPublicKey(k.CurveType, k.Pubkey, CompressionModeUncompressed)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(curveOID)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(subjectPublicKeyInfo{
+		Algo: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPublicKeyECDSA,
+			Parameters: asn1.RawValue{FullBytes: params},
+		},
+		PublicKey: asn1.BitString{Bytes: pubkey, BitLength: 8 * len(pubkey)},
+	})
+}
+
+// ConvertPublicKeyToPem outputs the public key in SubjectPublicKeyInfo PEM format
+func (k *KeyPair) ConvertPublicKeyToPem() (string, error) {
+	der, err := k.ConvertPublicKeyToDer()
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: pemTypePublicKey, Bytes: der})), nil
+}
+
+// jwkCurve returns "kty" and "crv" parameters of JWK for the key type
+func jwkCurve(curveType int) (string, string, error) {
+	switch curveType {
+	case KeyTypeEcdsaSECP256k1:
+		return "EC", "secp256k1", nil
+	case KeyTypeEcdsaP256v1:
+		return "EC", "P-256", nil
+	case KeyTypeEd25519:
+		return "OKP", "Ed25519", nil
+	}
+	return "", "", fmt.Errorf("not supported key type: %d", curveType)
+}
+
+// ConvertFromJwk reads the key in JWK format and sets it in the object (Privkey is cleared if "d" is not included)
+func (k *KeyPair) ConvertFromJwk(jwk []byte, compressionMode int) error {
+	var key jsonWebKey
+	if err := json.Unmarshal(jwk, &key); err != nil {
+		return err
+	}
+
+	curveType := KeyTypeNotInitialized
+	for _, t := range []int{KeyTypeEcdsaSECP256k1, KeyTypeEcdsaP256v1, KeyTypeEd25519} {
+		if kty, crv, _ := jwkCurve(t); kty == key.Kty && crv == key.Crv {
+			curveType = t
+		}
+	}
+	if curveType == KeyTypeNotInitialized {
+		return fmt.Errorf("not supported JWK: kty=%s, crv=%s", key.Kty, key.Crv)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(key.X)
+	if err != nil {
+		return err
+	}
+	pubkey := x
+	if curveType != KeyTypeEd25519 {
+		y, err := base64.RawURLEncoding.DecodeString(key.Y)
+		if err != nil {
+			return err
+		}
+		if len(x) != ecdsaScalarLength || len(y) != ecdsaScalarLength {
+			return errors.New("invalid length of coordinates in JWK")
+		}
+		pubkey = append(append([]byte{0x04}, x...), y...)
+	}
+	if pubkey, err = ConvertPublicKey(curveType, pubkey, compressionMode); err != nil {
+		return err
+	}
+
+	if key.D == "" {
+		k.CurveType = curveType
+		k.Pubkey = pubkey
+		k.Privkey = nil
+		return nil
+	}
+
+	d, err := base64.RawURLEncoding.DecodeString(key.D)
+	if err != nil {
+		return err
+	}
+	obj := KeyPair{}
+	if err := obj.setPrivateKey(curveType, d, compressionMode); err != nil {
+		return err
+	}
+	if !bytes.Equal(obj.Pubkey, pubkey) {
+		return errors.New("public key does not match the private key")
+	}
+	*k = obj
+	return nil
+}
+
+// ConvertToJwk outputs the key in JWK format (the private key is included if withPrivateKey is true)
+func (k *KeyPair) ConvertToJwk(withPrivateKey bool) ([]byte, error) {
+	kty, crv, err := jwkCurve(k.CurveType)
+	if err != nil {
+		return nil, err
+	}
+	key := jsonWebKey{Kty: kty, Crv: crv}
+
+	if k.CurveType == KeyTypeEd25519 {
+		if len(k.Pubkey) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid length of Ed25519 public key")
+		}
+		key.X = base64.RawURLEncoding.EncodeToString(k.Pubkey)
+	} else {
+		pubkey, err := ConvertPublicKey(k.CurveType, k.Pubkey, CompressionModeUncompressed)
+		if err != nil {
+			return nil, err
+		}
+		key.X = base64.RawURLEncoding.EncodeToString(pubkey[1 : 1+ecdsaScalarLength])
+		key.Y = base64.RawURLEncoding.EncodeToString(pubkey[1+ecdsaScalarLength:])
+	}
+
+	if withPrivateKey {
+		if err := checkPrivateKey(k.CurveType, k.Privkey); err != nil {
+			return nil, err
+		}
+		privkey := k.Privkey
+		if k.CurveType != KeyTypeEd25519 {
+			privkey = padScalar(privkey)
+		}
+		key.D = base64.RawURLEncoding.EncodeToString(privkey)
+	}
+	return json.Marshal(key)
+}
@@ -0,0 +1,237 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+)
+
+func sameKeyPair(k1, k2 *KeyPair) bool {
+	return k1.CurveType == k2.CurveType && bytes.Equal(k1.Pubkey, k2.Pubkey) && bytes.Equal(k1.Privkey, k2.Privkey)
+}
+
+func TestKeyPair_ConvertPrivateKey(t *testing.T) {
+	for keyType := KeyTypeEcdsaSECP256k1; keyType <= KeyTypeEd25519; keyType++ {
+		keypair := GenerateKeypair(keyType, defaultCompressionMode)
+		t.Run("key type", func(t *testing.T) {
+			t.Logf("key type = %d", keyType)
+			formats := []int{KeyFormatPKCS8, KeyFormatSEC1}
+			if keyType == KeyTypeEd25519 {
+				formats = formats[:1]
+				if _, err := keypair.ConvertToDer(KeyFormatSEC1); err == nil {
+					t.Fatal("SEC1 format must not be available for Ed25519")
+				}
+			}
+			for _, format := range formats {
+				pemString, err := keypair.ConvertToPem(format)
+				if err != nil {
+					t.Fatalf("failed to export private key (%v)", err)
+				}
+				t.Logf("PEM:\n%s", pemString)
+				keypair2 := KeyPair{}
+				if err := keypair2.ConvertFromPem(pemString, defaultCompressionMode); err != nil {
+					t.Fatalf("failed to import private key (%v)", err)
+				}
+				if !sameKeyPair(&keypair, &keypair2) {
+					t.Fatal("Not recovered correctly...(PEM)")
+				}
+
+				der, err := keypair.ConvertToDer(format)
+				if err != nil {
+					t.Fatalf("failed to export private key (%v)", err)
+				}
+				keypair3 := KeyPair{}
+				if err := keypair3.ConvertFromDer(der, CompressionModeCompressed); err != nil {
+					t.Fatalf("failed to import private key (%v)", err)
+				}
+				if keyType != KeyTypeEd25519 && len(keypair3.Pubkey) != 33 {
+					t.Fatal("public key is not compressed")
+				}
+				if err := keypair3.SetCompressionMode(defaultCompressionMode); err != nil || !sameKeyPair(&keypair, &keypair3) {
+					t.Fatal("Not recovered correctly...(DER)")
+				}
+			}
+		})
+	}
+}
+
+func TestKeyPair_ConvertPublicKey(t *testing.T) {
+	digest := sha256.Sum256([]byte("aaaaaaaaaaa"))
+	for keyType := KeyTypeEcdsaSECP256k1; keyType <= KeyTypeEd25519; keyType++ {
+		keypair := GenerateKeypair(keyType, defaultCompressionMode)
+		signature, _ := keypair.Sign(digest[:])
+		t.Run("key type", func(t *testing.T) {
+			pemString, err := keypair.ConvertPublicKeyToPem()
+			if err != nil {
+				t.Fatalf("failed to export public key (%v)", err)
+			}
+			t.Logf("PEM:\n%s", pemString)
+			pubkey := KeyPair{Privkey: []byte("should be cleared")}
+			if err := pubkey.ConvertPublicKeyFromPem(pemString, defaultCompressionMode); err != nil {
+				t.Fatalf("failed to import public key (%v)", err)
+			}
+			if pubkey.CurveType != keyType || !bytes.Equal(pubkey.Pubkey, keypair.Pubkey) || pubkey.Privkey != nil {
+				t.Fatal("Not recovered correctly...")
+			}
+			if !pubkey.Verify(digest[:], signature) {
+				t.Fatal("fail to verify")
+			}
+		})
+	}
+}
+
+func TestKeyPair_ConvertJwk(t *testing.T) {
+	for keyType := KeyTypeEcdsaSECP256k1; keyType <= KeyTypeEd25519; keyType++ {
+		keypair := GenerateKeypair(keyType, defaultCompressionMode)
+		t.Run("key type", func(t *testing.T) {
+			jwk, err := keypair.ConvertToJwk(true)
+			if err != nil {
+				t.Fatalf("failed to export JWK (%v)", err)
+			}
+			t.Logf("JWK: %s", jwk)
+			keypair2 := KeyPair{}
+			if err := keypair2.ConvertFromJwk(jwk, defaultCompressionMode); err != nil {
+				t.Fatalf("failed to import JWK (%v)", err)
+			}
+			if !sameKeyPair(&keypair, &keypair2) {
+				t.Fatal("Not recovered correctly...")
+			}
+
+			jwk, err = keypair.ConvertToJwk(false)
+			if err != nil {
+				t.Fatalf("failed to export JWK (%v)", err)
+			}
+			if strings.Contains(string(jwk), `"d"`) {
+				t.Fatal("private key must not be included")
+			}
+			keypair3 := KeyPair{}
+			if err := keypair3.ConvertFromJwk(jwk, defaultCompressionMode); err != nil {
+				t.Fatalf("failed to import JWK (%v)", err)
+			}
+			if keypair3.CurveType != keyType || !bytes.Equal(keypair3.Pubkey, keypair.Pubkey) || keypair3.Privkey != nil {
+				t.Fatal("Not recovered correctly...")
+			}
+		})
+	}
+}
+
+func TestConvertPublicKey(t *testing.T) {
+	for curvetype := 1; curvetype < 3; curvetype++ {
+		keypair := GenerateKeypair(curvetype, CompressionModeUncompressed)
+		t.Run("curvetype", func(t *testing.T) {
+			compressed, err := ConvertPublicKey(curvetype, keypair.Pubkey, CompressionModeCompressed)
+			if err != nil || len(compressed) != 33 {
+				t.Fatalf("failed to compress public key (%v)", err)
+			}
+			hybrid, err := ConvertPublicKey(curvetype, compressed, CompressionModeHybrid)
+			if err != nil || len(hybrid) != 65 || hybrid[0]&0xfe != 0x06 {
+				t.Fatalf("failed to convert public key into hybrid (%v)", err)
+			}
+			uncompressed, err := ConvertPublicKey(curvetype, hybrid, CompressionModeUncompressed)
+			if err != nil || !bytes.Equal(uncompressed, keypair.Pubkey) {
+				t.Fatalf("failed to decompress public key (%v)", err)
+			}
+			if _, err := ConvertPublicKey(curvetype, keypair.Pubkey, 3); err == nil {
+				t.Fatal("invalid compression mode must be rejected")
+			}
+			if _, err := ConvertPublicKey(curvetype, keypair.Pubkey[:64], CompressionModeCompressed); err == nil {
+				t.Fatal("invalid public key must be rejected")
+			}
+		})
+	}
+}
+
+func TestKeyPair_ConvertWithX509(t *testing.T) {
+	keypair := GenerateKeypair(KeyTypeEcdsaP256v1, defaultCompressionMode)
+	der, err := keypair.ConvertToDer(KeyFormatPKCS8)
+	if err != nil {
+		t.Fatalf("failed to export private key (%v)", err)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		t.Fatalf("failed to parse PKCS#8 by crypto/x509 (%v)", err)
+	}
+	if !bytes.Equal(padScalar(key.(*ecdsa.PrivateKey).D.Bytes()), keypair.Privkey) {
+		t.Fatal("private key mismatch")
+	}
+
+	sec1, err := x509.MarshalECPrivateKey(key.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatalf("failed to marshal by crypto/x509 (%v)", err)
+	}
+	keypair2 := KeyPair{}
+	if err := keypair2.ConvertFromDer(sec1, defaultCompressionMode); err != nil || !sameKeyPair(&keypair, &keypair2) {
+		t.Fatalf("failed to import SEC1 private key (%v)", err)
+	}
+
+	spki, err := x509.MarshalPKIXPublicKey(key.(*ecdsa.PrivateKey).Public())
+	if err != nil {
+		t.Fatalf("failed to marshal by crypto/x509 (%v)", err)
+	}
+	spki2, err := keypair.ConvertPublicKeyToDer()
+	if err != nil || !bytes.Equal(spki, spki2) {
+		t.Fatalf("public key mismatch (%v)", err)
+	}
+}
+
+func TestKeyPair_ConvertErrors(t *testing.T) {
+	keypair := GenerateKeypair(KeyTypeEcdsaSECP256k1, defaultCompressionMode)
+	other := GenerateKeypair(KeyTypeEcdsaSECP256k1, defaultCompressionMode)
+	k := KeyPair{}
+
+	if err := k.ConvertFromPem("not a pem", defaultCompressionMode); err == nil {
+		t.Fatal("invalid PEM must be rejected")
+	}
+	pubPem, _ := keypair.ConvertPublicKeyToPem()
+	if err := k.ConvertFromPem(pubPem, defaultCompressionMode); err == nil {
+		t.Fatal("public key must be rejected as a private key")
+	}
+	if err := k.ConvertFromDer([]byte{0x30, 0x03, 0x02, 0x01, 0x01}, defaultCompressionMode); err == nil {
+		t.Fatal("invalid DER must be rejected")
+	}
+
+	zero := KeyPair{CurveType: KeyTypeEcdsaSECP256k1, Privkey: make([]byte, 32)}
+	if _, err := zero.ConvertToDer(KeyFormatSEC1); err == nil {
+		t.Fatal("zero private key must be rejected")
+	}
+
+	der, _ := keypair.ConvertToDer(KeyFormatSEC1)
+	block := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: der})
+	if err := k.ConvertFromPem(string(block), defaultCompressionMode); err == nil {
+		t.Fatal("unknown PEM block type must be rejected")
+	}
+
+	jwk, _ := keypair.ConvertToJwk(true)
+	jwkOther, _ := other.ConvertToJwk(false)
+	d := string(jwk[strings.Index(string(jwk), `"d":`):])
+	mismatched := strings.TrimSuffix(string(jwkOther), "}") + "," + d
+	if err := k.ConvertFromJwk([]byte(mismatched), defaultCompressionMode); err == nil {
+		t.Fatal("JWK with mismatched public key must be rejected")
+	}
+	if err := k.ConvertFromJwk([]byte(`{"kty":"RSA","n":"AQAB"}`), defaultCompressionMode); err == nil {
+		t.Fatal("unsupported JWK must be rejected")
+	}
+	if !sameKeyPair(&k, &KeyPair{}) {
+		t.Fatal("the object must not be changed on error")
+	}
+}
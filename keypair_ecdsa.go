@@ -0,0 +1,197 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"math/big"
+)
+
+// Length of private key, coordinates of public key and each of r and s in the signature
+const (
+	ecdsaScalarLength = 32
+)
+
+// padScalar left-pads a big endian integer with zeros up to ecdsaScalarLength
+func padScalar(val []byte) []byte {
+	if len(val) >= ecdsaScalarLength {
+		return val[len(val)-ecdsaScalarLength:]
+	}
+	padded := make([]byte, ecdsaScalarLength)
+	copy(padded[ecdsaScalarLength-len(val):], val)
+	return padded
+}
+
+// encodePoint serializes a point on the curve in the format specified by compressionMode
+func encodePoint(x, y *big.Int, compressionMode int) []byte {
+	xb := padScalar(x.Bytes())
+	yb := padScalar(y.Bytes())
+	switch compressionMode {
+	case CompressionModeCompressed:
+		return append([]byte{0x02 | byte(y.Bit(0))}, xb...)
+	case CompressionModeHybrid:
+		return append(append([]byte{0x06 | byte(y.Bit(0))}, xb...), yb...)
+	default:
+		return append(append([]byte{0x04}, xb...), yb...)
+	}
+}
+
+// decodeP256Point parses a public key of prime256v1 in compressed, uncompressed or hybrid format
+func decodeP256Point(pubkey []byte) (*big.Int, *big.Int, error) {
+	curve := elliptic.P256()
+	if len(pubkey) == 1+ecdsaScalarLength {
+		x, y := elliptic.UnmarshalCompressed(curve, pubkey)
+		if x == nil {
+			return nil, nil, errors.New("invalid compressed public key")
+		}
+		return x, y, nil
+	}
+	if len(pubkey) != 1+2*ecdsaScalarLength {
+		return nil, nil, errors.New("invalid length of public key")
+	}
+
+	uncompressed := make([]byte, len(pubkey))
+	copy(uncompressed, pubkey)
+	if pubkey[0] == 0x06 || pubkey[0] == 0x07 {
+		if pubkey[0]&0x01 != pubkey[len(pubkey)-1]&0x01 {
+			return nil, nil, errors.New("invalid hybrid public key")
+		}
+		uncompressed[0] = 0x04
+	}
+	x, y := elliptic.Unmarshal(curve, uncompressed)
+	if x == nil {
+		return nil, nil, errors.New("invalid public key")
+	}
+	return x, y, nil
+}
+
+// decodePoint parses a public key of the curve in compressed, uncompressed or hybrid format
+func decodePoint(curveType int, pubkey []byte) (*big.Int, *big.Int, error) {
+	switch curveType {
+	case KeyTypeEcdsaSECP256k1:
+		pub, err := secp256k1.ParsePubKey(pubkey)
+		if err != nil {
+			return nil, nil, err
+		}
+		return pub.X(), pub.Y(), nil
+	case KeyTypeEcdsaP256v1:
+		return decodeP256Point(pubkey)
+	}
+	return nil, nil, errors.New("not supported curve type")
+}
+
+// ecdsaPublicKey calculates the public key from the private key and serializes it
+func ecdsaPublicKey(curveType int, privkey []byte, compressionMode int) []byte {
+	var x, y *big.Int
+	switch curveType {
+	case KeyTypeEcdsaSECP256k1:
+		pub := secp256k1.PrivKeyFromBytes(privkey).PubKey()
+		x, y = pub.X(), pub.Y()
+	case KeyTypeEcdsaP256v1:
+		x, y = elliptic.P256().ScalarBaseMult(padScalar(privkey))
+	default:
+		return nil
+	}
+	return encodePoint(x, y, compressionMode)
+}
+
+// ecdsaGenerateKeypair generates a new key pair for the curve
+func ecdsaGenerateKeypair(curveType int, compressionMode int) KeyPair {
+	var privkey []byte
+	switch curveType {
+	case KeyTypeEcdsaSECP256k1:
+		key, err := secp256k1.GeneratePrivateKey()
+		if err != nil {
+			return KeyPair{CurveType: curveType}
+		}
+		privkey = key.Serialize()
+	case KeyTypeEcdsaP256v1:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return KeyPair{CurveType: curveType}
+		}
+		privkey = padScalar(key.D.Bytes())
+	default:
+		return KeyPair{CurveType: curveType}
+	}
+	return KeyPair{CurveType: curveType, Pubkey: ecdsaPublicKey(curveType, privkey, compressionMode), Privkey: privkey}
+}
+
+// ecdsaSign signs to the digest and returns the signature in r||s format (64 bytes)
+func ecdsaSign(curveType int, privkey []byte, digest []byte) ([]byte, error) {
+	if len(privkey) == 0 {
+		return nil, errors.New("no private key")
+	}
+	switch curveType {
+	case KeyTypeEcdsaSECP256k1:
+		sig := secp256k1ecdsa.Sign(secp256k1.PrivKeyFromBytes(privkey), digest)
+		r := sig.R()
+		s := sig.S()
+		rb := r.Bytes()
+		sb := s.Bytes()
+		return append(rb[:], sb[:]...), nil
+	case KeyTypeEcdsaP256v1:
+		curve := elliptic.P256()
+		key := ecdsa.PrivateKey{D: new(big.Int).SetBytes(privkey)}
+		key.PublicKey.Curve = curve
+		key.PublicKey.X, key.PublicKey.Y = curve.ScalarBaseMult(padScalar(privkey))
+		r, s, err := ecdsa.Sign(rand.Reader, &key, digest)
+		if err != nil {
+			return nil, err
+		}
+		return append(padScalar(r.Bytes()), padScalar(s.Bytes())...), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %d", curveType)
+}
+
+// ecdsaVerify verifies the signature in r||s format for the digest
+func ecdsaVerify(curveType int, pubkey []byte, digest []byte, sig []byte) bool {
+	if len(sig) != 2*ecdsaScalarLength {
+		return false
+	}
+	switch curveType {
+	case KeyTypeEcdsaSECP256k1:
+		pub, err := secp256k1.ParsePubKey(pubkey)
+		if err != nil {
+			return false
+		}
+		var r, s secp256k1.ModNScalar
+		if overflow := r.SetByteSlice(sig[:ecdsaScalarLength]); overflow {
+			return false
+		}
+		if overflow := s.SetByteSlice(sig[ecdsaScalarLength:]); overflow {
+			return false
+		}
+		return secp256k1ecdsa.NewSignature(&r, &s).Verify(digest, pub)
+	case KeyTypeEcdsaP256v1:
+		x, y, err := decodeP256Point(pubkey)
+		if err != nil {
+			return false
+		}
+		pub := ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		r := new(big.Int).SetBytes(sig[:ecdsaScalarLength])
+		s := new(big.Int).SetBytes(sig[ecdsaScalarLength:])
+		return ecdsa.Verify(&pub, digest, r, s)
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+)
+
+// ed25519GenerateKeypair generates a new Ed25519 key pair (Privkey is the 32-byte seed of RFC8032)
+func ed25519GenerateKeypair() KeyPair {
+	pubkey, privkey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return KeyPair{CurveType: KeyTypeEd25519}
+	}
+	return KeyPair{CurveType: KeyTypeEd25519, Pubkey: []byte(pubkey), Privkey: privkey.Seed()}
+}
+
+// ed25519Sign signs to the digest and returns the 64-byte signature
+func ed25519Sign(privkey []byte, digest []byte) ([]byte, error) {
+	if len(privkey) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid private key length for Ed25519 (%d bytes)", len(privkey))
+	}
+	return ed25519.Sign(ed25519.NewKeyFromSeed(privkey), digest), nil
+}
+
+// ed25519Verify verifies the signature for the digest
+func ed25519Verify(pubkey []byte, digest []byte, sig []byte) bool {
+	if len(pubkey) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(pubkey), digest, sig)
+}
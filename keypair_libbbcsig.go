@@ -0,0 +1,99 @@
+//go:build libbbcsig
+// +build libbbcsig
+
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+/*
+#cgo CFLAGS: -I.
+#cgo LDFLAGS: ${SRCDIR}/libbbcsig.a -ldl
+#include "libbbcsig.h"
+*/
+import "C"
+import (
+	"errors"
+	"unsafe"
+)
+
+// GenerateKeypair generates a new Key pair object with new private key and public key
+func GenerateKeypair(curveType int, compressionMode int) KeyPair {
+	if curveType == KeyTypeEd25519 {
+		return ed25519GenerateKeypair()
+	}
+	pubkey := make([]byte, 100)
+	privkey := make([]byte, 100)
+	var lenPubkey, lenPrivkey C.int
+	C.generate_keypair(C.int(curveType), C.uint8_t(compressionMode), &lenPubkey, (*C.uint8_t)(unsafe.Pointer(&pubkey[0])),
+		&lenPrivkey, (*C.uint8_t)(unsafe.Pointer(&privkey[0])))
+	return KeyPair{CurveType: curveType, Pubkey: pubkey[:lenPubkey], Privkey: privkey[:lenPrivkey]}
+}
+
+// Sign to a given digest
+func (k *KeyPair) Sign(digest []byte) ([]byte, error) {
+	if k.CurveType == KeyTypeEd25519 {
+		return ed25519Sign(k.Privkey, digest)
+	}
+	if len(k.Privkey) == 0 || len(digest) == 0 {
+		return nil, errors.New("private key and digest must be set")
+	}
+	sigR := make([]byte, 100)
+	sigS := make([]byte, 100)
+	var lenSigR, lenSigS C.uint
+	C.sign(C.int(k.CurveType), C.int(len(k.Privkey)), (*C.uint8_t)(unsafe.Pointer(&k.Privkey[0])),
+		C.int(len(digest)), (*C.uint8_t)(unsafe.Pointer(&digest[0])),
+		(*C.uint8_t)(unsafe.Pointer(&sigR[0])), (*C.uint8_t)(unsafe.Pointer(&sigS[0])),
+		(*C.uint)(&lenSigR), (*C.uint)(&lenSigS))
+
+	if lenSigR < 32 {
+		zeros := make([]byte, 32-lenSigR)
+		for i := range zeros {
+			zeros[i] = 0
+		}
+		sigR = append(zeros, sigR[:32]...)
+	}
+	if lenSigS < 32 {
+		zeros := make([]byte, 32-lenSigS)
+		for i := range zeros {
+			zeros[i] = 0
+		}
+		sigS = append(zeros, sigS[:32]...)
+	}
+	//sig := make([]byte, lenSigR+lenSigS)
+	sig := append(sigR[:32], sigS[:32]...)
+
+	return sig, nil
+}
+
+// Verify a given digest with signature
+func (k *KeyPair) Verify(digest []byte, sig []byte) bool {
+	return verify(k.CurveType, k.Pubkey, digest, sig)
+}
+
+// verify is the backend function to verify a signature
+func verify(curveType int, pubkey []byte, digest []byte, sig []byte) bool {
+	if curveType == KeyTypeEd25519 {
+		return ed25519Verify(pubkey, digest, sig)
+	}
+	if len(pubkey) == 0 || len(digest) == 0 || len(sig) == 0 {
+		return false
+	}
+	result := C.verify(C.int(curveType), C.int(len(pubkey)), (*C.uint8_t)(unsafe.Pointer(&pubkey[0])),
+		C.int(len(digest)), (*C.uint8_t)(unsafe.Pointer(&digest[0])),
+		C.int(len(sig)), (*C.uint8_t)(unsafe.Pointer(&sig[0])))
+	return result == 1
+}
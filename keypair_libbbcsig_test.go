@@ -0,0 +1,68 @@
+//go:build libbbcsig
+// +build libbbcsig
+
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestKeyPair_BackendCompatibility(t *testing.T) {
+	digest := sha256.Sum256([]byte("aaaaaaaaaaa"))
+
+	for curvetype := 1; curvetype < 3; curvetype++ {
+		for _, mode := range []int{CompressionModeCompressed, CompressionModeUncompressed} {
+			t.Run("curvetype", func(t *testing.T) {
+				t.Logf("Curvetype = %d, compressionMode = %d", curvetype, mode)
+				keypair := GenerateKeypair(curvetype, mode)
+				pubkey := ecdsaPublicKey(curvetype, keypair.Privkey, mode)
+				if !bytes.Equal(pubkey, keypair.Pubkey) {
+					t.Fatalf("public key mismatch: libbbcsig=%x, go=%x", keypair.Pubkey, pubkey)
+				}
+
+				sig1, _ := keypair.Sign(digest[:])
+				if !ecdsaVerify(curvetype, keypair.Pubkey, digest[:], sig1) {
+					t.Fatal("signature by libbbcsig is not verified in go")
+				}
+				sig2, _ := ecdsaSign(curvetype, keypair.Privkey, digest[:])
+				if len(sig2) != 64 {
+					t.Fatal("fail to sign in go")
+				}
+				if !keypair.Verify(digest[:], sig2) {
+					t.Fatal("signature by go is not verified by libbbcsig")
+				}
+
+				keypair2 := ecdsaGenerateKeypair(curvetype, mode)
+				sig3, _ := keypair2.Sign(digest[:])
+				if !ecdsaVerify(curvetype, keypair2.Pubkey, digest[:], sig3) {
+					t.Fatal("signature by libbbcsig with go key is not verified in go")
+				}
+				bbcsig := BBcSignature{}
+				sig4, _ := ecdsaSign(curvetype, keypair2.Privkey, digest[:])
+				bbcsig.SetPublicKey(uint32(curvetype), &keypair2.Pubkey)
+				bbcsig.SetSignature(&sig4)
+				if !VerifyBBcSignature(digest[:], &bbcsig) {
+					t.Fatal("signature by go with go key is not verified by libbbcsig")
+				}
+			})
+		}
+	}
+}
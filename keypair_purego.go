@@ -0,0 +1,49 @@
+//go:build !libbbcsig
+// +build !libbbcsig
+
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+// GenerateKeypair generates a new Key pair object with new private key and public key
+func GenerateKeypair(curveType int, compressionMode int) KeyPair {
+	if curveType == KeyTypeEd25519 {
+		return ed25519GenerateKeypair()
+	}
+	return ecdsaGenerateKeypair(curveType, compressionMode)
+}
+
+// Sign to a given digest
+func (k *KeyPair) Sign(digest []byte) ([]byte, error) {
+	if k.CurveType == KeyTypeEd25519 {
+		return ed25519Sign(k.Privkey, digest)
+	}
+	return ecdsaSign(k.CurveType, k.Privkey, digest)
+}
+
+// Verify a given digest with signature
+func (k *KeyPair) Verify(digest []byte, sig []byte) bool {
+	return verify(k.CurveType, k.Pubkey, digest, sig)
+}
+
+// verify is the backend function to verify a signature
+func verify(curveType int, pubkey []byte, digest []byte, sig []byte) bool {
+	if curveType == KeyTypeEd25519 {
+		return ed25519Verify(pubkey, digest, sig)
+	}
+	return ecdsaVerify(curveType, pubkey, digest, sig)
+}
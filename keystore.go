@@ -0,0 +1,364 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+	"os"
+	"time"
+)
+
+/*
+Keystore definition
+
+A keystore is a JSON document holding a KeyPair protected by a passphrase.
+The private key is encrypted with AES-256-GCM using a key derived from the passphrase by scrypt or argon2id.
+The key type, public key, userID and creation time are stored in plain text so that they can be read without the passphrase,
+but they are authenticated as the additional data of AES-GCM together with the KDF, its parameters and the salt,
+so any modification is detected on decryption.
+
+A "check" value derived from the passphrase is also stored, so that a wrong passphrase (KeystorePassphraseError)
+is distinguished from a modified file (KeystoreTamperedError). Since a modified KDF parameter or salt also changes the derived key,
+the digest of the KDF section ("kdf_digest") is checked before the key derivation, and a mismatch is reported as KeystoreTamperedError.
+(A modification which also rewrites "kdf_digest" cannot be told from a wrong passphrase without the passphrase, and results in KeystorePassphraseError.)
+The KDF parameters are limited so that the derivation does not use more than 1GiB of memory (128*N*(r+p) bytes for scrypt).
+A malformed file results in KeystoreFormatError.
+*/
+type (
+	KeystoreParams struct {
+		KDF           string
+		ScryptN       int
+		ScryptR       int
+		ScryptP       int
+		Argon2Time    uint32
+		Argon2Memory  uint32
+		Argon2Threads uint8
+	}
+
+	KeystoreInfo struct {
+		Version   int
+		KeyType   int
+		Pubkey    []byte
+		UserID    []byte
+		CreatedAt time.Time
+	}
+
+	// KeystoreFormatError is returned if the keystore data is malformed or not supported
+	KeystoreFormatError struct {
+		Reason string
+	}
+
+	// KeystorePassphraseError is returned if the passphrase is wrong
+	KeystorePassphraseError struct{}
+
+	// KeystoreTamperedError is returned if the keystore data has been modified
+	KeystoreTamperedError struct {
+		Reason string
+	}
+
+	keystoreFile struct {
+		Version   int            `json:"version"`
+		KeyType   int            `json:"key_type"`
+		Pubkey    string         `json:"pubkey"`
+		UserID    string         `json:"user_id"`
+		CreatedAt string         `json:"created_at"`
+		Crypto    keystoreCrypto `json:"crypto"`
+	}
+
+	keystoreCrypto struct {
+		Cipher     string            `json:"cipher"`
+		Ciphertext string            `json:"ciphertext"`
+		Nonce      string            `json:"nonce"`
+		KDF        string            `json:"kdf"`
+		KDFParams  keystoreKDFParams `json:"kdfparams"`
+		KDFDigest  string            `json:"kdf_digest"`
+		Check      string            `json:"check"`
+	}
+
+	keystoreKDFParams struct {
+		Salt    string `json:"salt"`
+		N       int    `json:"n,omitempty"`
+		R       int    `json:"r,omitempty"`
+		P       int    `json:"p,omitempty"`
+		Time    uint32 `json:"time,omitempty"`
+		Memory  uint32 `json:"memory,omitempty"`
+		Threads uint8  `json:"threads,omitempty"`
+	}
+)
+
+// Supported KDFs and parameters of keystore
+const (
+	KeystoreKDFScrypt   = "scrypt"
+	KeystoreKDFArgon2id = "argon2id"
+
+	keystoreVersion      = 1
+	keystoreCipher       = "aes-256-gcm"
+	keystoreSaltLength   = 32
+	keystoreDerivedLen   = 64
+	keystoreMaxMemory    = 1 << 30
+	keystoreMaxMemoryKiB = keystoreMaxMemory / 1024
+)
+
+// DefaultKeystoreParams is used if params is nil in EncryptKeystore
+var DefaultKeystoreParams = KeystoreParams{
+	KDF:     KeystoreKDFScrypt,
+	ScryptN: 1 << 15,
+	ScryptR: 8,
+	ScryptP: 1,
+}
+
+func (e *KeystoreFormatError) Error() string {
+	return "keystore: invalid format: " + e.Reason
+}
+
+func (e *KeystorePassphraseError) Error() string {
+	return "keystore: wrong passphrase"
+}
+
+func (e *KeystoreTamperedError) Error() string {
+	return "keystore: data has been tampered: " + e.Reason
+}
+
+// deriveKey derives the encryption key (32 bytes) and the check key (32 bytes) from the passphrase
+func (p *keystoreKDFParams) deriveKey(kdf string, passphrase []byte) ([]byte, error) {
+	salt, err := hex.DecodeString(p.Salt)
+	if err != nil || len(salt) == 0 {
+		return nil, &KeystoreFormatError{Reason: "invalid salt"}
+	}
+	switch kdf {
+	case KeystoreKDFScrypt:
+		if p.N <= 1 || p.R <= 0 || p.P <= 0 || uint64(p.R)*uint64(p.P) >= 1<<30 {
+			return nil, &KeystoreFormatError{Reason: "invalid scrypt parameters"}
+		}
+		// scrypt allocates 128*r*N bytes for V and 128*r*p bytes for B
+		if uint64(p.N)+uint64(p.P) > keystoreMaxMemory/(128*uint64(p.R)) {
+			return nil, &KeystoreFormatError{Reason: "scrypt parameters require too much memory"}
+		}
+		derived, err := scrypt.Key(passphrase, salt, p.N, p.R, p.P, keystoreDerivedLen)
+		if err != nil {
+			return nil, &KeystoreFormatError{Reason: err.Error()}
+		}
+		return derived, nil
+	case KeystoreKDFArgon2id:
+		if p.Time == 0 || p.Threads == 0 || p.Memory == 0 || p.Memory > keystoreMaxMemoryKiB {
+			return nil, &KeystoreFormatError{Reason: "invalid argon2id parameters"}
+		}
+		return argon2.IDKey(passphrase, salt, p.Time, p.Memory, p.Threads, keystoreDerivedLen), nil
+	}
+	return nil, &KeystoreFormatError{Reason: "not supported kdf: " + kdf}
+}
+
+// kdfData returns the KDF, its parameters and the salt in the canonical form
+func (c *keystoreCrypto) kdfData() string {
+	p := &c.KDFParams
+	return fmt.Sprintf("%s|%s|%d|%d|%d|%d|%d|%d", c.KDF, p.Salt, p.N, p.R, p.P, p.Time, p.Memory, p.Threads)
+}
+
+// kdfDigest returns the digest of the KDF section
+func (c *keystoreCrypto) kdfDigest() []byte {
+	digest := sha256.Sum256([]byte(c.kdfData()))
+	return digest[:]
+}
+
+// additionalData returns the data authenticated by AES-GCM
+func (f *keystoreFile) additionalData() []byte {
+	return []byte(fmt.Sprintf("%d|%d|%s|%s|%s|%s", f.Version, f.KeyType, f.Pubkey, f.UserID, f.CreatedAt, f.Crypto.kdfData()))
+}
+
+// keystoreCheck returns the check value of the derived key
+func keystoreCheck(derived []byte) []byte {
+	digest := sha256.Sum256(derived[32:])
+	return digest[:]
+}
+
+// EncryptKeystore encrypts the keypair with the passphrase and returns the keystore data (params can be nil)
+func EncryptKeystore(keypair *KeyPair, userID []byte, passphrase []byte, params *KeystoreParams) ([]byte, error) {
+	if err := checkPrivateKey(keypair.CurveType, keypair.Privkey); err != nil {
+		return nil, err
+	}
+	if params == nil {
+		params = &DefaultKeystoreParams
+	}
+
+	f := keystoreFile{
+		Version:   keystoreVersion,
+		KeyType:   keypair.CurveType,
+		Pubkey:    hex.EncodeToString(keypair.Pubkey),
+		UserID:    hex.EncodeToString(userID),
+		CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+		Crypto: keystoreCrypto{
+			Cipher: keystoreCipher,
+			KDF:    params.KDF,
+			KDFParams: keystoreKDFParams{
+				Salt: hex.EncodeToString(GetRandomValue(keystoreSaltLength)),
+			},
+		},
+	}
+	switch params.KDF {
+	case KeystoreKDFScrypt:
+		f.Crypto.KDFParams.N = params.ScryptN
+		f.Crypto.KDFParams.R = params.ScryptR
+		f.Crypto.KDFParams.P = params.ScryptP
+	case KeystoreKDFArgon2id:
+		f.Crypto.KDFParams.Time = params.Argon2Time
+		f.Crypto.KDFParams.Memory = params.Argon2Memory
+		f.Crypto.KDFParams.Threads = params.Argon2Threads
+	}
+
+	derived, err := f.Crypto.KDFParams.deriveKey(params.KDF, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(derived[:32])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := GetRandomValue(aead.NonceSize())
+	f.Crypto.Nonce = hex.EncodeToString(nonce)
+	f.Crypto.KDFDigest = hex.EncodeToString(f.Crypto.kdfDigest())
+	f.Crypto.Ciphertext = hex.EncodeToString(aead.Seal(nil, nonce, keypair.Privkey, f.additionalData()))
+	f.Crypto.Check = hex.EncodeToString(keystoreCheck(derived))
+
+	return json.MarshalIndent(&f, "", "  ")
+}
+
+// parseKeystore parses the keystore data and returns the metadata
+func parseKeystore(data []byte) (*keystoreFile, *KeystoreInfo, error) {
+	var f keystoreFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, nil, &KeystoreFormatError{Reason: err.Error()}
+	}
+	if f.Version != keystoreVersion {
+		return nil, nil, &KeystoreFormatError{Reason: fmt.Sprintf("not supported version: %d", f.Version)}
+	}
+	if f.Crypto.Cipher != keystoreCipher {
+		return nil, nil, &KeystoreFormatError{Reason: "not supported cipher: " + f.Crypto.Cipher}
+	}
+
+	info := KeystoreInfo{Version: f.Version, KeyType: f.KeyType}
+	var err error
+	if info.Pubkey, err = hex.DecodeString(f.Pubkey); err != nil {
+		return nil, nil, &KeystoreFormatError{Reason: "invalid pubkey"}
+	}
+	if info.UserID, err = hex.DecodeString(f.UserID); err != nil {
+		return nil, nil, &KeystoreFormatError{Reason: "invalid user_id"}
+	}
+	if info.CreatedAt, err = time.Parse(time.RFC3339Nano, f.CreatedAt); err != nil {
+		return nil, nil, &KeystoreFormatError{Reason: "invalid created_at"}
+	}
+	return &f, &info, nil
+}
+
+// ReadKeystoreInfo returns the metadata in the keystore data without the passphrase (the metadata is not authenticated)
+func ReadKeystoreInfo(data []byte) (*KeystoreInfo, error) {
+	_, info, err := parseKeystore(data)
+	return info, err
+}
+
+// DecryptKeystore decrypts the keystore data with the passphrase and returns the KeyPair object and the metadata
+func DecryptKeystore(data []byte, passphrase []byte) (*KeyPair, *KeystoreInfo, error) {
+	f, info, err := parseKeystore(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce, err := hex.DecodeString(f.Crypto.Nonce)
+	if err != nil {
+		return nil, nil, &KeystoreFormatError{Reason: "invalid nonce"}
+	}
+	ciphertext, err := hex.DecodeString(f.Crypto.Ciphertext)
+	if err != nil {
+		return nil, nil, &KeystoreFormatError{Reason: "invalid ciphertext"}
+	}
+	check, err := hex.DecodeString(f.Crypto.Check)
+	if err != nil {
+		return nil, nil, &KeystoreFormatError{Reason: "invalid check"}
+	}
+	kdfDigest, err := hex.DecodeString(f.Crypto.KDFDigest)
+	if err != nil || len(kdfDigest) != sha256.Size {
+		return nil, nil, &KeystoreFormatError{Reason: "invalid kdf_digest"}
+	}
+	if f.Crypto.KDF != KeystoreKDFScrypt && f.Crypto.KDF != KeystoreKDFArgon2id {
+		return nil, nil, &KeystoreFormatError{Reason: "not supported kdf: " + f.Crypto.KDF}
+	}
+	if subtle.ConstantTimeCompare(f.Crypto.kdfDigest(), kdfDigest) != 1 {
+		return nil, nil, &KeystoreTamperedError{Reason: "kdf parameters do not match kdf_digest"}
+	}
+
+	derived, err := f.Crypto.KDFParams.deriveKey(f.Crypto.KDF, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	if subtle.ConstantTimeCompare(keystoreCheck(derived), check) != 1 {
+		return nil, nil, &KeystorePassphraseError{}
+	}
+
+	block, err := aes.NewCipher(derived[:32])
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, nil, &KeystoreFormatError{Reason: "invalid nonce"}
+	}
+	privkey, err := aead.Open(nil, nonce, ciphertext, f.additionalData())
+	if err != nil {
+		return nil, nil, &KeystoreTamperedError{Reason: "authentication failed"}
+	}
+
+	keypair := KeyPair{}
+	if err := keypair.setPrivateKey(info.KeyType, privkey, defaultCompressionMode); err != nil {
+		return nil, nil, &KeystoreTamperedError{Reason: err.Error()}
+	}
+	if !samePublicKey(info.KeyType, keypair.Pubkey, info.Pubkey) {
+		return nil, nil, &KeystoreTamperedError{Reason: "public key does not match the private key"}
+	}
+	keypair.Pubkey = info.Pubkey
+	return &keypair, info, nil
+}
+
+// WriteKeystoreFile encrypts the keypair with the passphrase and writes it in the file (permission is 0600)
+func WriteKeystoreFile(path string, keypair *KeyPair, userID []byte, passphrase []byte, params *KeystoreParams) error {
+	data, err := EncryptKeystore(keypair, userID, passphrase, params)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// ReadKeystoreFile reads the keystore file and decrypts it with the passphrase
+func ReadKeystoreFile(path string, passphrase []byte) (*KeyPair, *KeystoreInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return DecryptKeystore(data, passphrase)
+}
@@ -0,0 +1,206 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	testScryptParams = KeystoreParams{KDF: KeystoreKDFScrypt, ScryptN: 1 << 10, ScryptR: 8, ScryptP: 1}
+	testArgon2Params = KeystoreParams{KDF: KeystoreKDFArgon2id, Argon2Time: 1, Argon2Memory: 1024, Argon2Threads: 1}
+)
+
+func TestKeystoreEncryptDecrypt(t *testing.T) {
+	userID := GetIdentifierWithTimestamp("user1", defaultIDLength)
+	passphrase := []byte("correct horse battery staple")
+
+	for _, params := range []KeystoreParams{testScryptParams, testArgon2Params} {
+		for keyType := KeyTypeEcdsaSECP256k1; keyType <= KeyTypeEd25519; keyType++ {
+			t.Run(params.KDF, func(t *testing.T) {
+				keypair := GenerateKeypair(keyType, CompressionModeCompressed)
+				params := params
+				data, err := EncryptKeystore(&keypair, userID, passphrase, &params)
+				if err != nil {
+					t.Fatalf("failed to encrypt keystore (%v)", err)
+				}
+				t.Logf("keystore: %s", data)
+				if bytes.Contains(data, []byte(hex.EncodeToString(keypair.Privkey))) {
+					t.Fatal("private key is stored in plain text")
+				}
+
+				keypair2, info, err := DecryptKeystore(data, passphrase)
+				if err != nil {
+					t.Fatalf("failed to decrypt keystore (%v)", err)
+				}
+				if !sameKeyPair(&keypair, keypair2) {
+					t.Fatal("Not recovered correctly...")
+				}
+				if !bytes.Equal(info.UserID, userID) || info.KeyType != keyType || time.Since(info.CreatedAt) > time.Minute {
+					t.Fatal("metadata is not recovered correctly")
+				}
+			})
+		}
+	}
+}
+
+func TestKeystoreFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key.json")
+	keypair := GenerateKeypair(KeyTypeEcdsaP256v1, defaultCompressionMode)
+	userID := GetIdentifierWithTimestamp("user1", defaultIDLength)
+
+	if err := WriteKeystoreFile(path, &keypair, userID, []byte("pass"), &testScryptParams); err != nil {
+		t.Fatalf("failed to write keystore file (%v)", err)
+	}
+	if st, err := os.Stat(path); err != nil || st.Mode().Perm() != 0600 {
+		t.Fatalf("invalid file permission (%v)", err)
+	}
+	keypair2, info, err := ReadKeystoreFile(path, []byte("pass"))
+	if err != nil || !sameKeyPair(&keypair, keypair2) || !bytes.Equal(info.UserID, userID) {
+		t.Fatalf("failed to read keystore file (%v)", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	info2, err := ReadKeystoreInfo(data)
+	if err != nil || !bytes.Equal(info2.Pubkey, keypair.Pubkey) {
+		t.Fatalf("failed to read metadata (%v)", err)
+	}
+}
+
+func TestKeystoreErrors(t *testing.T) {
+	keypair := GenerateKeypair(KeyTypeEcdsaSECP256k1, defaultCompressionMode)
+	userID := GetIdentifierWithTimestamp("user1", defaultIDLength)
+	passphrase := []byte("pass")
+	data, err := EncryptKeystore(&keypair, userID, passphrase, &testArgon2Params)
+	if err != nil {
+		t.Fatalf("failed to encrypt keystore (%v)", err)
+	}
+
+	modify := func(f func(m map[string]interface{})) []byte {
+		var m map[string]interface{}
+		json.Unmarshal(data, &m)
+		f(m)
+		dat, _ := json.Marshal(m)
+		return dat
+	}
+	other := GenerateKeypair(KeyTypeEcdsaSECP256k1, defaultCompressionMode)
+	otherUserID := GetIdentifierWithTimestamp("user2", defaultIDLength)
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		_, _, err := DecryptKeystore(data, []byte("wrong"))
+		var e *KeystorePassphraseError
+		if !errors.As(err, &e) {
+			t.Fatalf("unexpected error (%v)", err)
+		}
+	})
+
+	tampered := map[string][]byte{
+		"ciphertext": modify(func(m map[string]interface{}) {
+			c := m["crypto"].(map[string]interface{})
+			ct := []byte(c["ciphertext"].(string))
+			if ct[0] == '0' {
+				ct[0] = '1'
+			} else {
+				ct[0] = '0'
+			}
+			c["ciphertext"] = string(ct)
+		}),
+		"user_id": modify(func(m map[string]interface{}) {
+			m["user_id"] = hex.EncodeToString(otherUserID)
+		}),
+		"pubkey": modify(func(m map[string]interface{}) {
+			m["pubkey"] = hex.EncodeToString(other.Pubkey)
+		}),
+		"created_at": modify(func(m map[string]interface{}) {
+			m["created_at"] = time.Now().Add(-time.Hour).UTC().Format(time.RFC3339Nano)
+		}),
+		"kdf": modify(func(m map[string]interface{}) {
+			c := m["crypto"].(map[string]interface{})
+			c["kdf"] = KeystoreKDFScrypt
+			c["kdfparams"] = map[string]interface{}{"salt": c["kdfparams"].(map[string]interface{})["salt"], "n": 1024, "r": 8, "p": 1}
+		}),
+		"salt": modify(func(m map[string]interface{}) {
+			m["crypto"].(map[string]interface{})["kdfparams"].(map[string]interface{})["salt"] = hex.EncodeToString(GetRandomValue(keystoreSaltLength))
+		}),
+		"kdfparams": modify(func(m map[string]interface{}) {
+			m["crypto"].(map[string]interface{})["kdfparams"].(map[string]interface{})["time"] = 2
+		}),
+	}
+	for name, dat := range tampered {
+		t.Run("tampered "+name, func(t *testing.T) {
+			_, _, err := DecryptKeystore(dat, passphrase)
+			var e *KeystoreTamperedError
+			if !errors.As(err, &e) {
+				t.Fatalf("unexpected error (%v)", err)
+			}
+		})
+	}
+
+	malformed := map[string][]byte{
+		"json":    []byte("{"),
+		"version": modify(func(m map[string]interface{}) { m["version"] = 99 }),
+		"kdf": modify(func(m map[string]interface{}) {
+			m["crypto"].(map[string]interface{})["kdf"] = "pbkdf2"
+		}),
+		"kdf_digest": modify(func(m map[string]interface{}) {
+			m["crypto"].(map[string]interface{})["kdf_digest"] = "xyz"
+		}),
+	}
+	for name, dat := range malformed {
+		t.Run("malformed "+name, func(t *testing.T) {
+			_, _, err := DecryptKeystore(dat, passphrase)
+			var e *KeystoreFormatError
+			if !errors.As(err, &e) {
+				t.Fatalf("unexpected error (%v)", err)
+			}
+		})
+	}
+}
+
+func TestKeystoreScryptMemoryLimit(t *testing.T) {
+	keypair := GenerateKeypair(KeyTypeEcdsaSECP256k1, defaultCompressionMode)
+	passphrase := []byte("pass")
+	data, err := EncryptKeystore(&keypair, nil, passphrase, &testScryptParams)
+	if err != nil {
+		t.Fatalf("failed to encrypt keystore (%v)", err)
+	}
+
+	// each of N and r is acceptable alone, but 128*N*r is 32GiB
+	params := KeystoreParams{KDF: KeystoreKDFScrypt, ScryptN: 1 << 20, ScryptR: 256, ScryptP: 1}
+	if _, err := EncryptKeystore(&keypair, nil, passphrase, &params); err == nil {
+		t.Fatal("too much memory must be rejected")
+	}
+
+	var f keystoreFile
+	json.Unmarshal(data, &f)
+	f.Crypto.KDFParams.N = 1 << 20
+	f.Crypto.KDFParams.R = 256
+	f.Crypto.KDFDigest = hex.EncodeToString(f.Crypto.kdfDigest())
+	crafted, _ := json.Marshal(&f)
+	_, _, err = DecryptKeystore(crafted, passphrase)
+	var e *KeystoreFormatError
+	if !errors.As(err, &e) {
+		t.Fatalf("KeystoreFormatError is expected (%v)", err)
+	}
+}
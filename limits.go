@@ -0,0 +1,99 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"fmt"
+)
+
+/*
+DecodeLimits definition
+
+DecodeLimits restricts the resources consumed by decoding data received from untrusted peers (see DeserializeWithLimits).
+A field with 0 means no limit.
+
+"MaxSize" is the maximum size of the serialized data (or the packed transaction given to UnpackWithLimits).
+"MaxDecompressedSize" is the maximum size of the packed transaction after zlib decompression.
+"MaxCount" is the maximum number of elements in every list, e.g., Events, SigIndices in a BBcReference and Pointers in a BBcRelation.
+"MaxAssetBodySize" is the maximum size of AssetBody in a BBcAsset.
+MaxCount and MaxAssetBodySize are checked as soon as the count prefix or the size field is read, before the elements are unpacked.
+
+DecodeLimitError is returned if the data exceeds a limit.
+*/
+type (
+	DecodeLimits struct {
+		MaxSize             int
+		MaxDecompressedSize int
+		MaxCount            int
+		MaxAssetBodySize    int
+	}
+
+	// DecodeLimitError reports which limit is exceeded
+	DecodeLimitError struct {
+		Field string
+		Value int64
+		Limit int64
+	}
+)
+
+// DefaultDecodeLimits is used if limits is nil in DeserializeWithLimits
+var DefaultDecodeLimits = DecodeLimits{
+	MaxSize:             16 * 1024 * 1024,
+	MaxDecompressedSize: 16 * 1024 * 1024,
+	MaxCount:            4096,
+	MaxAssetBodySize:    16 * 1024 * 1024,
+}
+
+func (e *DecodeLimitError) Error() string {
+	return fmt.Sprintf("decode limit exceeded: %s is %d (limit %d)", e.Field, e.Value, e.Limit)
+}
+
+// checkLimit returns DecodeLimitError if value exceeds limit (0 means no limit)
+func checkLimit(field string, value int, limit int) error {
+	if limit > 0 && value > limit {
+		return &DecodeLimitError{Field: field, Value: int64(value), Limit: int64(limit)}
+	}
+	return nil
+}
+
+// checkCount returns DecodeLimitError if the number of elements read from a count prefix exceeds MaxCount (no limit if l is nil)
+//
+// It is called before the elements are unpacked, so that a hostile count does not cause any allocation.
+func (l *DecodeLimits) checkCount(field string, num int) error {
+	if l == nil {
+		return nil
+	}
+	return checkLimit(field, num, l.MaxCount)
+}
+
+// checkAssetBodySize returns DecodeLimitError if the asset body size read from the data exceeds MaxAssetBodySize (no limit if l is nil)
+//
+// The size is compared in int64, so that a 4-byte size field is not truncated on 32-bit platforms.
+func (l *DecodeLimits) checkAssetBodySize(size uint32) error {
+	if l == nil || l.MaxAssetBodySize <= 0 || int64(size) <= int64(l.MaxAssetBodySize) {
+		return nil
+	}
+	return &DecodeLimitError{Field: "AssetBody", Value: int64(size), Limit: int64(l.MaxAssetBodySize)}
+}
+
+// limitErrorAt prefixes the field of DecodeLimitError with the path of the object which contains it
+func limitErrorAt(path string, err error) error {
+	if e, ok := err.(*DecodeLimitError); ok {
+		return &DecodeLimitError{Field: path + "." + e.Field, Value: e.Value, Limit: e.Limit}
+	}
+	return err
+}
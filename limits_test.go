@@ -0,0 +1,150 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"testing"
+)
+
+// makeDecodeTestTransaction makes a transaction which has all kinds of objects
+func makeDecodeTestTransaction(t testing.TB) *BBcTransaction {
+	u1, u2 := testUsers[0], testUsers[1]
+	txobj := makeTestTransaction(t, func(b *TransactionBuilder) {
+		b.Event(testAssetGroupID).Asset(u1).BodyString("teststring!!!!!").Approver(u1)
+	}, 0)
+	return makeTestTransaction(t, func(b *TransactionBuilder) {
+		b.Event(testAssetGroupID).Asset(u2).BodyObject(map[string]string{"param1": "aaa"}).OptionApprovers(1, u1, u2).
+			Reference(testAssetGroupID, txobj, 0).ReferenceApprover(u1).
+			Relation(testAssetGroupID).Asset(u2).BodyString("relation").Pointer(txobj.TransactionID, txobj.Events[0].Asset.AssetID).
+			Witness(u2).
+			CrossRef(GetIdentifier("dummy domain", DomainIDLength), txobj.TransactionID)
+	}, 0, 1)
+}
+
+func TestDeserializeWithLimits(t *testing.T) {
+	txobj := makeDecodeTestTransaction(t)
+	plain, _ := Serialize(txobj, FormatPlain)
+	compressed, _ := Serialize(txobj, FormatZlib)
+
+	t.Run("within limits", func(t *testing.T) {
+		for _, dat := range [][]byte{plain, compressed} {
+			obj, err := DeserializeWithLimits(dat, nil)
+			if err != nil {
+				t.Fatalf("failed to deserialize (%v)", err)
+			}
+			if !bytes.Equal(obj.TransactionID, txobj.TransactionID) {
+				t.Fatal("Not recovered correctly...")
+			}
+		}
+	})
+
+	t.Run("exceeding limits", func(t *testing.T) {
+		cases := []struct {
+			dat    []byte
+			limits DecodeLimits
+			field  string
+		}{
+			{plain, DecodeLimits{MaxSize: 100}, "serialized size"},
+			{compressed, DecodeLimits{MaxDecompressedSize: 100}, "decompressed size"},
+			{plain, DecodeLimits{MaxCount: 1}, "Events[0].OptionApprovers"},
+			{compressed, DecodeLimits{MaxAssetBodySize: 8}, "Events[0].Asset.AssetBody"},
+		}
+		for _, c := range cases {
+			_, err := DeserializeWithLimits(c.dat, &c.limits)
+			if e, ok := err.(*DecodeLimitError); !ok || e.Field != c.field {
+				t.Fatalf("DecodeLimitError for %s must be returned (%v)", c.field, err)
+			}
+		}
+	})
+
+	t.Run("hostile length prefix", func(t *testing.T) {
+		// the size of the first event claims 4GB
+		dat := append([]byte{}, plain...)
+		offset := 2 + 4 + 8 + 2 + 2
+		copy(dat[offset:offset+4], []byte{0xff, 0xff, 0xff, 0xff})
+		if _, err := DeserializeWithLimits(dat, nil); err == nil {
+			t.Fatal("must fail")
+		}
+
+		sig := BBcSignature{}
+		sigdat := []byte{0x01, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff}
+		if err := sig.Unpack(&sigdat); err == nil {
+			t.Fatal("must fail")
+		}
+	})
+
+	t.Run("hostile count prefix", func(t *testing.T) {
+		// the header of version 1 followed by 0xffff events without any data
+		buf := new(bytes.Buffer)
+		Put2byte(buf, FormatPlain)
+		Put4byte(buf, TransactionVersion1)
+		Put8byte(buf, 0)
+		Put2byte(buf, uint16(defaultIDLength))
+		Put2byte(buf, 0xffff)
+		dat := buf.Bytes()
+		if _, err := DeserializeWithLimits(dat, nil); err == nil {
+			t.Fatal("must fail")
+		}
+		_, err := DeserializeWithLimits(dat, &DefaultDecodeLimits)
+		if e, ok := err.(*DecodeLimitError); !ok || e.Field != "Events" || e.Value != 0xffff {
+			t.Fatalf("DecodeLimitError for Events must be returned before reading the events (%v)", err)
+		}
+
+		// an event which claims 0xffff mandatory approvers
+		buf = new(bytes.Buffer)
+		agid := GetIdentifier("asset_group_id_decode", defaultIDLength)
+		PutBigInt(buf, &agid, defaultIDLength)
+		Put2byte(buf, 0)
+		Put2byte(buf, 0xffff)
+		evtdat := buf.Bytes()
+		evt := BBcEvent{IDLength: defaultIDLength}
+		err = evt.unpack(&evtdat, &DefaultDecodeLimits)
+		if e, ok := err.(*DecodeLimitError); !ok || e.Field != "MandatoryApprovers" {
+			t.Fatalf("DecodeLimitError for MandatoryApprovers must be returned (%v)", err)
+		}
+		if evt.MandatoryApprovers != nil {
+			t.Fatal("MandatoryApprovers must not be allocated")
+		}
+
+		// an asset which claims a 4GB body
+		ast := BBcAsset{IDLength: defaultIDLength, form: assetFormExtended}
+		ast.Add(&agid)
+		astdat, _ := ast.Pack()
+		copy(astdat[len(astdat)-4:], []byte{0xff, 0xff, 0xff, 0xff})
+		obj := BBcAsset{IDLength: defaultIDLength, form: assetFormExtended}
+		err = obj.unpack(&astdat, &DefaultDecodeLimits)
+		if e, ok := err.(*DecodeLimitError); !ok || e.Field != "AssetBody" || e.Value != 0xffffffff {
+			t.Fatalf("DecodeLimitError for AssetBody must be returned (%v)", err)
+		}
+		if obj.AssetBody != nil {
+			t.Fatal("AssetBody must not be allocated")
+		}
+	})
+
+	t.Run("truncated nested object", func(t *testing.T) {
+		rtn := txobj.Relations[0]
+		dat, _ := rtn.Pack()
+		for _, size := range []int{len(dat) - 1, len(dat) - 20, 40} {
+			truncated := dat[:size]
+			obj := BBcRelation{IDLength: defaultIDLength}
+			if err := obj.Unpack(&truncated); err == nil {
+				t.Fatalf("must fail with %d bytes", size)
+			}
+		}
+	})
+}
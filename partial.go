@@ -0,0 +1,354 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+/*
+PartiallySignedTransaction definition
+
+PartiallySignedTransaction is a container for exchanging a transaction among the parties who sign it.
+It carries the transaction without signatures (Transaction), the users expected to sign it (Signers) and the signatures collected so far (Signatures).
+
+Signers are taken from SigIndices of the transaction made locally, which includes the users in BBcWitness and the approvers added by BBcReference.AddApprover.
+Signers[i] corresponds to Signatures[i] of the finalized transaction, and Signatures[i] is nil until the user signs.
+
+A typical workflow is as follows:
+ 1. The creator makes a PartiallySignedTransaction by NewPartiallySignedTransaction and sends the packed data (Pack) to the signers
+ 2. Each party unpacks it (UnpackPartiallySignedTransaction), checks the content, signs it by Sign and sends it back
+ 3. The creator merges the returned objects by Merge, and gets the signed transaction by Finalize when MissingSigners is empty
+
+Every signature is verified with TransactionID when it is added, so a signature made over a different TransactionID is rejected.
+"PubkeyResolver" (see ApprovalVerifier) must be supplied when the object is made or unpacked, and a signature is also rejected
+if its public key is not one of the keys returned by PubkeyResolver for the signer, so that nobody can fill the slot of another signer with their own key.
+The resolver is not packed.
+
+Packed format:
+
+	format_version(2) | transaction_id | transaction size(4) | transaction | signer num(2) | [user_id | signature size(4) | signature]...
+
+transaction_id and user_id are packed by PutBigInt, and a missing signature is packed as BBcSignature with KeyTypeNotInitialized.
+*/
+type (
+	PartiallySignedTransaction struct {
+		TransactionID  []byte
+		Transaction    *BBcTransaction
+		Signers        [][]byte
+		Signatures     []*BBcSignature
+		PubkeyResolver PubkeyResolver
+	}
+)
+
+const partiallySignedFormatVersion = 1
+
+// NewPartiallySignedTransaction makes a PartiallySignedTransaction object from the transaction which SigIndices is set (valid signatures of the signers in the transaction are taken over)
+func NewPartiallySignedTransaction(transaction *BBcTransaction, resolver PubkeyResolver) (*PartiallySignedTransaction, error) {
+	if transaction == nil {
+		return nil, errors.New("transaction is nil")
+	}
+	if resolver == nil {
+		return nil, errors.New("PubkeyResolver must be set")
+	}
+	if len(transaction.SigIndices) == 0 {
+		return nil, errors.New("no signer is specified in the transaction (SigIndices is empty)")
+	}
+	if len(transaction.SigIndices) != len(transaction.Signatures) {
+		return nil, fmt.Errorf("SigIndices (%d) does not match Signatures (%d)", len(transaction.SigIndices), len(transaction.Signatures))
+	}
+	dat, err := transaction.Pack()
+	if err != nil {
+		return nil, err
+	}
+	txobj := BBcTransaction{}
+	if err := txobj.Unpack(&dat); err != nil {
+		return nil, err
+	}
+
+	p := PartiallySignedTransaction{TransactionID: txobj.TransactionID, Transaction: &txobj, PubkeyResolver: resolver}
+	for i := range transaction.SigIndices {
+		p.Signers = append(p.Signers, transaction.sigUserID(transaction.SigIndices[i]))
+		p.Signatures = append(p.Signatures, nil)
+		txobj.Signatures[i] = &BBcSignature{KeyType: KeyTypeNotInitialized}
+	}
+	txobj.SigIndices = p.Signers
+
+	for i, sig := range transaction.Signatures {
+		if _, err := p.checkSignature(p.Signers[i], sig); err == nil {
+			p.Signatures[i] = sig
+		}
+	}
+	return &p, nil
+}
+
+// Stringer outputs the content of the object
+func (p *PartiallySignedTransaction) Stringer() string {
+	ret := "------- Dump of the partially signed transaction ------\n"
+	ret += fmt.Sprintf("* transaction_id: %x\n", p.TransactionID)
+	ret += fmt.Sprintf("Signers[]: %d\n", len(p.Signers))
+	for i := range p.Signers {
+		if p.Signatures[i] == nil {
+			ret += fmt.Sprintf(" [%d] %x: not signed\n", i, p.Signers[i])
+		} else {
+			ret += fmt.Sprintf(" [%d] %x: signed\n", i, p.Signers[i])
+		}
+	}
+	return ret
+}
+
+// signerIndex returns the position of the userID in Signers (-1 if not found)
+func (p *PartiallySignedTransaction) signerIndex(userID []byte) int {
+	for i := range p.Signers {
+		if len(userID) >= len(p.Signers[i]) && bytes.Equal(p.Signers[i], userID[:len(p.Signers[i])]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkSignature checks that the signature of the user can be added to the object and returns the position in Signers
+func (p *PartiallySignedTransaction) checkSignature(userID []byte, sig *BBcSignature) (int, error) {
+	idx := p.signerIndex(userID)
+	if idx < 0 {
+		return -1, fmt.Errorf("user %x is not a signer of the transaction", userID)
+	}
+	if sig == nil || sig.KeyType == KeyTypeNotInitialized {
+		return -1, fmt.Errorf("no signature is given for user %x", userID)
+	}
+	if !sig.Verify(p.TransactionID) {
+		return -1, fmt.Errorf("signature of user %x is not made over TransactionID %x", userID, p.TransactionID)
+	}
+	if p.PubkeyResolver == nil {
+		return -1, errors.New("PubkeyResolver must be set")
+	}
+	if !p.PubkeyResolver.owns(p.Signers[idx], sig) {
+		return -1, fmt.Errorf("public key of the signature does not belong to user %x", p.Signers[idx])
+	}
+	return idx, nil
+}
+
+// AddSignature sets the signature of the user after verifying it with TransactionID and checking the public key by PubkeyResolver
+func (p *PartiallySignedTransaction) AddSignature(userID []byte, sig *BBcSignature) error {
+	idx, err := p.checkSignature(userID, sig)
+	if err != nil {
+		return err
+	}
+	p.Signatures[idx] = sig
+	return nil
+}
+
+// Sign signs TransactionID with the signer (e.g., KeyPair object) and adds the signature of the user
+func (p *PartiallySignedTransaction) Sign(userID []byte, signer Signer) error {
+	if signer == nil {
+		return errors.New("signer must be set")
+	}
+	signature, err := signer.Sign(p.TransactionID)
+	if err != nil {
+		return fmt.Errorf("fail to sign (%v)", err)
+	}
+	sig := BBcSignature{}
+	sig.SetPublicKeyBySigner(signer)
+	sig.SetSignature(&signature)
+	return p.AddSignature(userID, &sig)
+}
+
+// Merge takes the signatures in other which this object does not have yet
+//
+// The signatures are checked by PubkeyResolver of this object (not of other).
+// An error is returned and nothing is merged if other is for a different transaction or includes an invalid signature or a signature by a key of another user.
+func (p *PartiallySignedTransaction) Merge(other *PartiallySignedTransaction) error {
+	if other == nil {
+		return errors.New("nothing to merge")
+	}
+	if !bytes.Equal(p.TransactionID, other.TransactionID) {
+		return fmt.Errorf("TransactionID mismatch (%x and %x)", p.TransactionID, other.TransactionID)
+	}
+	if len(p.Signers) != len(other.Signers) {
+		return errors.New("signers mismatch")
+	}
+	for i := range p.Signers {
+		if !bytes.Equal(p.Signers[i], other.Signers[i]) {
+			return fmt.Errorf("signers mismatch at %d (%x and %x)", i, p.Signers[i], other.Signers[i])
+		}
+	}
+
+	for i, sig := range other.Signatures {
+		if sig == nil {
+			continue
+		}
+		if _, err := p.checkSignature(other.Signers[i], sig); err != nil {
+			return err
+		}
+	}
+	for i, sig := range other.Signatures {
+		if sig != nil && p.Signatures[i] == nil {
+			p.Signatures[i] = sig
+		}
+	}
+	return nil
+}
+
+// MissingSigners returns the users who have not signed yet
+func (p *PartiallySignedTransaction) MissingSigners() [][]byte {
+	var ret [][]byte
+	for i := range p.Signers {
+		if p.Signatures[i] == nil {
+			ret = append(ret, p.Signers[i])
+		}
+	}
+	return ret
+}
+
+// IsComplete returns true if all signers have signed
+func (p *PartiallySignedTransaction) IsComplete() bool {
+	return len(p.MissingSigners()) == 0
+}
+
+// Finalize checks all signatures again (Signatures may be set directly) and returns the transaction with them
+func (p *PartiallySignedTransaction) Finalize() (*BBcTransaction, error) {
+	if missing := p.MissingSigners(); len(missing) > 0 {
+		return nil, fmt.Errorf("%d signature(s) missing (e.g., user %x)", len(missing), missing[0])
+	}
+	for i := range p.Signers {
+		if _, err := p.checkSignature(p.Signers[i], p.Signatures[i]); err != nil {
+			return nil, err
+		}
+	}
+	txobj := p.Transaction
+	for i := range p.Signers {
+		txobj.AddSignature(&p.Signers[i], p.Signatures[i])
+	}
+	if txobj.Digest(); !bytes.Equal(txobj.TransactionID, p.TransactionID) {
+		return nil, errors.New("TransactionID of the transaction is changed")
+	}
+	if ret, idx := txobj.VerifyAll(); !ret {
+		return nil, fmt.Errorf("failed to verify Signatures[%d]", idx)
+	}
+	return txobj, nil
+}
+
+// Pack returns the binary data of the PartiallySignedTransaction object
+func (p *PartiallySignedTransaction) Pack() ([]byte, error) {
+	if p.Transaction == nil || len(p.Signers) != len(p.Signatures) {
+		return nil, errors.New("invalid partially signed transaction")
+	}
+	buf := new(bytes.Buffer)
+	Put2byte(buf, partiallySignedFormatVersion)
+	PutBigInt(buf, &p.TransactionID, len(p.TransactionID))
+
+	dat, err := p.Transaction.Pack()
+	if err != nil {
+		return nil, err
+	}
+	Put4byte(buf, uint32(len(dat)))
+	if err := binary.Write(buf, binary.LittleEndian, dat); err != nil {
+		return nil, err
+	}
+
+	Put2byte(buf, uint16(len(p.Signers)))
+	for i := range p.Signers {
+		PutBigInt(buf, &p.Signers[i], len(p.Signers[i]))
+		sig := p.Signatures[i]
+		if sig == nil {
+			sig = &BBcSignature{KeyType: KeyTypeNotInitialized}
+		}
+		dat, err := sig.Pack()
+		if err != nil {
+			return nil, err
+		}
+		Put4byte(buf, uint32(len(dat)))
+		if err := binary.Write(buf, binary.LittleEndian, dat); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// UnpackPartiallySignedTransaction makes a PartiallySignedTransaction object from the binary data and checks the signatures in it with the resolver
+func UnpackPartiallySignedTransaction(dat []byte, resolver PubkeyResolver) (*PartiallySignedTransaction, error) {
+	if resolver == nil {
+		return nil, errors.New("PubkeyResolver must be set")
+	}
+	buf := bytes.NewBuffer(dat)
+	version, err := Get2byte(buf)
+	if err != nil {
+		return nil, err
+	}
+	if version != partiallySignedFormatVersion {
+		return nil, fmt.Errorf("not supported format version of partially signed transaction: %d", version)
+	}
+	p := PartiallySignedTransaction{PubkeyResolver: resolver}
+	if p.TransactionID, err = GetBigInt(buf); err != nil {
+		return nil, err
+	}
+
+	size, err := Get4byte(buf)
+	if err != nil {
+		return nil, err
+	}
+	txdat, err := GetBytes(buf, int(size))
+	if err != nil {
+		return nil, err
+	}
+	p.Transaction = &BBcTransaction{}
+	if err := p.Transaction.Unpack(&txdat); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(p.Transaction.TransactionID, p.TransactionID) {
+		return nil, fmt.Errorf("TransactionID mismatch (%x is given but %x is calculated)", p.TransactionID, p.Transaction.TransactionID)
+	}
+
+	num, err := Get2byte(buf)
+	if err != nil {
+		return nil, err
+	}
+	if int(num) != len(p.Transaction.Signatures) {
+		return nil, fmt.Errorf("%d signers for %d signature slots in the transaction", num, len(p.Transaction.Signatures))
+	}
+	for i := 0; i < int(num); i++ {
+		userID, err := GetBigInt(buf)
+		if err != nil {
+			return nil, err
+		}
+		size, err := Get4byte(buf)
+		if err != nil {
+			return nil, err
+		}
+		sigdat, err := GetBytes(buf, int(size))
+		if err != nil {
+			return nil, err
+		}
+		sig := BBcSignature{}
+		if err := sig.Unpack(&sigdat); err != nil {
+			return nil, err
+		}
+		p.Signers = append(p.Signers, userID)
+		p.Signatures = append(p.Signatures, nil)
+		p.Transaction.Signatures[i] = &BBcSignature{KeyType: KeyTypeNotInitialized}
+		if sig.KeyType != KeyTypeNotInitialized {
+			if err := p.AddSignature(userID, &sig); err != nil {
+				return nil, err
+			}
+		}
+	}
+	p.Transaction.SigIndices = p.Signers
+	return &p, nil
+}
@@ -0,0 +1,220 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"testing"
+)
+
+// makePartialTestTransaction makes a transaction which needs signatures of testUsers[0] (witness), testUsers[1] and testUsers[2] (approvers of the reference)
+func makePartialTestTransaction(t *testing.T) *BBcTransaction {
+	users := testUsers
+	reftx := makeTestTransaction(t, func(b *TransactionBuilder) {
+		b.Event(testAssetGroupID).Asset(users[1]).BodyString("reftx").Approver(users[1], users[2])
+	})
+	return makeTestTransaction(t, func(b *TransactionBuilder) {
+		b.Event(testAssetGroupID).Asset(users[0]).BodyString("partial").Approver(users[0]).
+			Reference(testAssetGroupID, reftx, 0).ReferenceApprover(users[1], users[2]).
+			Witness(users[0])
+	})
+}
+
+func TestPartiallySignedTransaction(t *testing.T) {
+	users, keypairs, resolver := testUsers, testKeypairs, testPubkeyResolver
+	txobj := makePartialTestTransaction(t)
+	SignToTransaction(txobj, &users[0], &keypairs[0])
+
+	pst, err := NewPartiallySignedTransaction(txobj, resolver)
+	if err != nil {
+		t.Fatalf("failed to make (%v)", err)
+	}
+	if len(pst.Signers) != 3 || len(pst.MissingSigners()) != 2 || pst.IsComplete() {
+		t.Fatalf("unexpected state\n%s", pst.Stringer())
+	}
+
+	dat, err := pst.Pack()
+	if err != nil {
+		t.Fatalf("failed to pack (%v)", err)
+	}
+
+	// each party signs its own copy
+	var returned []*PartiallySignedTransaction
+	for i := 1; i < 3; i++ {
+		obj, err := UnpackPartiallySignedTransaction(dat, resolver)
+		if err != nil {
+			t.Fatalf("failed to unpack (%v)", err)
+		}
+		if !bytes.Equal(obj.TransactionID, txobj.TransactionID) {
+			t.Fatal("TransactionID mismatch")
+		}
+		if err := obj.Sign(users[i], &keypairs[i]); err != nil {
+			t.Fatalf("failed to sign (%v)", err)
+		}
+		ret, _ := obj.Pack()
+		obj2, err := UnpackPartiallySignedTransaction(ret, resolver)
+		if err != nil {
+			t.Fatalf("failed to unpack (%v)", err)
+		}
+		returned = append(returned, obj2)
+	}
+
+	if _, err := pst.Finalize(); err == nil {
+		t.Fatal("Finalize must fail before all signatures are collected")
+	}
+	for _, obj := range returned {
+		if err := pst.Merge(obj); err != nil {
+			t.Fatalf("failed to merge (%v)", err)
+		}
+	}
+	if !pst.IsComplete() {
+		t.Fatalf("signatures are missing\n%s", pst.Stringer())
+	}
+
+	signed, err := pst.Finalize()
+	if err != nil {
+		t.Fatalf("failed to finalize (%v)", err)
+	}
+	serialized, _ := Serialize(signed, FormatZlib)
+	obj, err := Deserialize(serialized)
+	if err != nil {
+		t.Fatalf("failed to deserialize (%v)", err)
+	}
+	if ret, idx := obj.VerifyAll(); !ret {
+		t.Fatalf("failed to verify Signatures[%d]", idx)
+	}
+	for i := range obj.Signatures {
+		if obj.Signatures[i].KeyType == KeyTypeNotInitialized {
+			t.Fatalf("Signatures[%d] is not set", i)
+		}
+	}
+	if report := obj.VerifyWitness(nil); !report.Valid() {
+		t.Fatal(report.Stringer())
+	}
+}
+
+func TestPartiallySignedTransactionRejection(t *testing.T) {
+	users, keypairs, resolver := testUsers, testKeypairs, testPubkeyResolver
+	txobj := makePartialTestTransaction(t)
+	pst, err := NewPartiallySignedTransaction(txobj, resolver)
+	if err != nil {
+		t.Fatalf("failed to make (%v)", err)
+	}
+
+	t.Run("signature over a different TransactionID", func(t *testing.T) {
+		other := makePartialTestTransaction(t)
+		otherPst, _ := NewPartiallySignedTransaction(other, resolver)
+		otherPst.Sign(users[0], &keypairs[0])
+		if err := pst.Merge(otherPst); err == nil {
+			t.Fatal("merging a different transaction must fail")
+		}
+
+		otherPst.TransactionID = pst.TransactionID
+		otherPst.Signers = pst.Signers
+		if err := pst.Merge(otherPst); err == nil {
+			t.Fatal("signature over a different TransactionID must be rejected")
+		}
+		if len(pst.MissingSigners()) != 3 {
+			t.Fatal("nothing should be merged")
+		}
+
+		sig := otherPst.Signatures[0]
+		if err := pst.AddSignature(users[0], sig); err == nil {
+			t.Fatal("signature over a different TransactionID must be rejected")
+		}
+	})
+
+	t.Run("signature by a key of another user", func(t *testing.T) {
+		stranger := GenerateKeypair(KeyTypeEcdsaP256v1, defaultCompressionMode)
+		if err := pst.Sign(users[1], &stranger); err == nil {
+			t.Fatal("signature by a key which does not belong to the signer must be rejected")
+		}
+		if err := pst.Sign(users[1], &keypairs[2]); err == nil {
+			t.Fatal("signature by a key of another signer must be rejected")
+		}
+
+		sign := func(keypair *KeyPair) *BBcSignature {
+			signature, _ := keypair.Sign(pst.TransactionID)
+			sig := BBcSignature{}
+			sig.SetPublicKeyBySigner(keypair)
+			sig.SetSignature(&signature)
+			return &sig
+		}
+
+		// a forged entry in the container to merge
+		dat, _ := pst.Pack()
+		forged, _ := UnpackPartiallySignedTransaction(dat, resolver)
+		forged.Signatures[1] = sign(&stranger)
+		if err := pst.Merge(forged); err == nil {
+			t.Fatal("forged signature must not be merged")
+		}
+		if pst.Signatures[1] != nil {
+			t.Fatal("nothing should be merged")
+		}
+		forgedDat, _ := forged.Pack()
+		if _, err := UnpackPartiallySignedTransaction(forgedDat, resolver); err == nil {
+			t.Fatal("forged signature must be rejected by unpacking")
+		}
+
+		// Finalize checks the signatures set directly
+		forged.Signatures[0] = sign(&keypairs[0])
+		forged.Signatures[2] = sign(&keypairs[2])
+		if _, err := forged.Finalize(); err == nil {
+			t.Fatal("forged signature must be rejected by Finalize")
+		}
+	})
+
+	t.Run("resolver is required", func(t *testing.T) {
+		if _, err := NewPartiallySignedTransaction(txobj, nil); err == nil {
+			t.Fatal("PubkeyResolver must be required")
+		}
+		dat, _ := pst.Pack()
+		if _, err := UnpackPartiallySignedTransaction(dat, nil); err == nil {
+			t.Fatal("PubkeyResolver must be required")
+		}
+	})
+
+	t.Run("unknown signer", func(t *testing.T) {
+		unknown := GetIdentifier("unknown_user", defaultIDLength)
+		if err := pst.Sign(unknown, &keypairs[0]); err == nil {
+			t.Fatal("unknown signer must be rejected")
+		}
+	})
+
+	t.Run("tampered data", func(t *testing.T) {
+		pst.Sign(users[0], &keypairs[0])
+		dat, _ := pst.Pack()
+		tampered := make([]byte, len(dat))
+		copy(tampered, dat)
+		tampered[len(tampered)-10] ^= 0xff
+		if _, err := UnpackPartiallySignedTransaction(tampered, resolver); err == nil {
+			t.Fatal("tampered signature must be rejected")
+		}
+		if _, err := UnpackPartiallySignedTransaction(dat[:len(dat)/2], resolver); err == nil {
+			t.Fatal("truncated data must be rejected")
+		}
+	})
+
+	t.Run("transaction without SigIndices", func(t *testing.T) {
+		dat, _ := txobj.Pack()
+		obj := BBcTransaction{}
+		obj.Unpack(&dat)
+		if _, err := NewPartiallySignedTransaction(&obj, resolver); err == nil {
+			t.Fatal("SigIndices is required")
+		}
+	})
+}
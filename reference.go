@@ -63,7 +63,7 @@ func (p *BBcReference) SetTransaction(txobj *BBcTransaction) {
 	p.Transaction = txobj
 }
 
-// Add sets essential information to the BBcReference object
+// Add sets essential information to the BBcReference object (RefEvent is left empty if eventIdx is out of range of refTransaction, which Validate reports)
 func (p *BBcReference) Add(assetGroupID *[]byte, refTransaction *BBcTransaction, eventIdx int) {
 	if assetGroupID != nil {
 		p.AssetGroupID = make([]byte, p.IDLength)
@@ -75,7 +75,9 @@ func (p *BBcReference) Add(assetGroupID *[]byte, refTransaction *BBcTransaction,
 	if refTransaction != nil {
 		p.RefTransaction = refTransaction
 		p.TransactionID = refTransaction.TransactionID[:p.IDLength]
-		p.RefEvent = *p.RefTransaction.Events[p.EventIndexInRef]
+		if int(p.EventIndexInRef) < len(p.RefTransaction.Events) && p.RefTransaction.Events[p.EventIndexInRef] != nil {
+			p.RefEvent = *p.RefTransaction.Events[p.EventIndexInRef]
+		}
 	}
 }
 
@@ -138,6 +140,11 @@ func (p *BBcReference) Pack() ([]byte, error) {
 
 // Unpack the BBcReference object to the binary data
 func (p *BBcReference) Unpack(dat *[]byte) error {
+	return p.unpack(dat, nil)
+}
+
+// unpack the binary data to the BBcReference object within the limits (no limit if limits is nil)
+func (p *BBcReference) unpack(dat *[]byte, limits *DecodeLimits) error {
 	var err error
 	buf := bytes.NewBuffer(*dat)
 
@@ -160,6 +167,9 @@ func (p *BBcReference) Unpack(dat *[]byte) error {
 	if err != nil {
 		return err
 	}
+	if err = limits.checkCount("SigIndices", int(sigNum)); err != nil {
+		return err
+	}
 	for i := 0; i < int(sigNum); i++ {
 		idx, err := Get2byte(buf)
 		if err != nil {
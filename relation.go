@@ -40,6 +40,7 @@ type (
 		AssetGroupID []byte
 		Pointers     []*BBcPointer
 		Asset        *BBcAsset
+		assetForm    int
 	}
 )
 
@@ -115,6 +116,11 @@ func (p *BBcRelation) Pack() ([]byte, error) {
 
 // Unpack the BBcRelation object to the binary data
 func (p *BBcRelation) Unpack(dat *[]byte) error {
+	return p.unpack(dat, nil)
+}
+
+// unpack the binary data to the BBcRelation object within the limits (no limit if limits is nil)
+func (p *BBcRelation) unpack(dat *[]byte, limits *DecodeLimits) error {
 	var err error
 	buf := bytes.NewBuffer(*dat)
 
@@ -127,14 +133,22 @@ func (p *BBcRelation) Unpack(dat *[]byte) error {
 	if err != nil {
 		return err
 	}
+	if err = limits.checkCount("Pointers", int(numPointers)); err != nil {
+		return err
+	}
 	for i := 0; i < int(numPointers); i++ {
 		size, err2 := Get2byte(buf)
 		if err2 != nil {
 			return err2
 		}
-		ptr, _ := GetBytes(buf, int(size))
+		ptr, err2 := GetBytes(buf, int(size))
+		if err2 != nil {
+			return err2
+		}
 		pointer := BBcPointer{IDLength: p.IDLength}
-		pointer.Unpack(&ptr)
+		if err2 = pointer.Unpack(&ptr); err2 != nil {
+			return err2
+		}
 		p.Pointers = append(p.Pointers, &pointer)
 	}
 
@@ -147,8 +161,10 @@ func (p *BBcRelation) Unpack(dat *[]byte) error {
 		if err != nil {
 			return err
 		}
-		p.Asset = &BBcAsset{IDLength: p.IDLength}
-		p.Asset.Unpack(&ast)
+		p.Asset = &BBcAsset{IDLength: p.IDLength, form: p.assetForm}
+		if err := p.Asset.unpack(&ast, limits); err != nil {
+			return limitErrorAt("Asset", err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,235 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+/*
+Reference resolution
+
+A deserialized transaction has only the IDs of the past transactions in BBcReference and BBcPointer objects.
+ResolveReferences looks up the past transactions through TransactionLookup (e.g., a TransactionStore) and
+  - sets "Transaction", "RefTransaction" and "RefEvent" of every BBcReference object, so that AddApprover and ApprovalVerifier can be used
+  - returns PointerTarget objects for the BBcPointer objects, which have the transaction and the BBcAsset object (if AssetID is given) pointed by them
+
+A reference or a pointer which cannot be resolved is reported as DanglingReferenceError, and all of them are returned together as DanglingReferenceErrors.
+The resolvable ones are resolved even if some are dangling. An error of the lookup other than ErrTransactionNotFound is returned as is.
+*/
+type (
+	TransactionLookup interface {
+		Get(transactionID []byte) (*BBcTransaction, error)
+	}
+
+	// PointerTarget is the transaction and the asset pointed by the BBcPointer at Relations[RelationIndex].Pointers[PointerIndex]
+	PointerTarget struct {
+		RelationIndex int
+		PointerIndex  int
+		Transaction   *BBcTransaction
+		Asset         *BBcAsset
+	}
+
+	// DanglingReferenceError describes a BBcReference or a BBcPointer which cannot be resolved
+	DanglingReferenceError struct {
+		Path          string
+		TransactionID []byte
+		AssetID       []byte
+		Reason        int
+	}
+
+	// DanglingReferenceErrors is the list of DanglingReferenceError returned by ResolveReferences
+	DanglingReferenceErrors []*DanglingReferenceError
+)
+
+// Reasons of DanglingReferenceError
+const (
+	DanglingTransactionNotFound = iota
+	DanglingEventNotFound
+	DanglingAssetGroupMismatch
+	DanglingAssetNotFound
+)
+
+var danglingReasons = map[int]string{
+	DanglingTransactionNotFound: "transaction not found",
+	DanglingEventNotFound:       "event not found in the transaction",
+	DanglingAssetGroupMismatch:  "asset group of the event differs",
+	DanglingAssetNotFound:       "asset not found in the transaction",
+}
+
+func (e *DanglingReferenceError) Error() string {
+	if e.AssetID != nil {
+		return fmt.Sprintf("%s: %s (transaction_id=%x, asset_id=%x)", e.Path, danglingReasons[e.Reason], e.TransactionID, e.AssetID)
+	}
+	return fmt.Sprintf("%s: %s (transaction_id=%x)", e.Path, danglingReasons[e.Reason], e.TransactionID)
+}
+
+func (e DanglingReferenceErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].Error()
+	}
+	return fmt.Sprintf("%d dangling reference(s): %s", len(e), strings.Join(msgs, "; "))
+}
+
+// referenceResolver caches the looked-up transactions during ResolveReferences
+type referenceResolver struct {
+	lookup   TransactionLookup
+	cache    map[string]*BBcTransaction
+	dangling DanglingReferenceErrors
+}
+
+// get returns the transaction of the transactionID (nil if not found)
+func (r *referenceResolver) get(transactionID []byte) (*BBcTransaction, error) {
+	if txobj, ok := r.cache[string(transactionID)]; ok {
+		return txobj, nil
+	}
+	txobj, err := r.lookup.Get(transactionID)
+	if err == ErrTransactionNotFound {
+		txobj, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if txobj != nil {
+		if txobj.TransactionID == nil {
+			txobj.Digest()
+		}
+		if len(transactionID) == 0 || !bytes.HasPrefix(txobj.TransactionID, transactionID) {
+			txobj = nil
+		}
+	}
+	r.cache[string(transactionID)] = txobj
+	return txobj, nil
+}
+
+func (r *referenceResolver) addDangling(path string, transactionID, assetID []byte, reason int) {
+	r.dangling = append(r.dangling, &DanglingReferenceError{Path: path, TransactionID: transactionID, AssetID: assetID, Reason: reason})
+}
+
+// findAsset returns the BBcAsset object of the assetID in the transaction
+func findAsset(transaction *BBcTransaction, assetID []byte) *BBcAsset {
+	match := func(asset *BBcAsset) bool {
+		if asset == nil {
+			return false
+		}
+		if asset.AssetID == nil {
+			asset.Digest()
+		}
+		return bytes.Equal(asset.AssetID, assetID)
+	}
+	for _, evt := range transaction.Events {
+		if evt != nil && match(evt.Asset) {
+			return evt.Asset
+		}
+	}
+	for _, rtn := range transaction.Relations {
+		if rtn != nil && match(rtn.Asset) {
+			return rtn.Asset
+		}
+	}
+	return nil
+}
+
+func (r *referenceResolver) resolveReference(path string, transaction *BBcTransaction, ref *BBcReference) error {
+	ref.Transaction = transaction
+	refTx, err := r.get(ref.TransactionID)
+	if err != nil {
+		return err
+	}
+	if refTx == nil {
+		r.addDangling(path, ref.TransactionID, nil, DanglingTransactionNotFound)
+		return nil
+	}
+	if int(ref.EventIndexInRef) >= len(refTx.Events) || refTx.Events[ref.EventIndexInRef] == nil {
+		r.addDangling(path, ref.TransactionID, nil, DanglingEventNotFound)
+		return nil
+	}
+	evt := refTx.Events[ref.EventIndexInRef]
+	if !bytes.Equal(evt.AssetGroupID, ref.AssetGroupID) {
+		r.addDangling(path, ref.TransactionID, nil, DanglingAssetGroupMismatch)
+		return nil
+	}
+	ref.RefTransaction = refTx
+	ref.RefEvent = *evt
+	return nil
+}
+
+func (r *referenceResolver) resolvePointer(path string, ptr *BBcPointer) (*PointerTarget, error) {
+	refTx, err := r.get(ptr.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+	if refTx == nil {
+		r.addDangling(path, ptr.TransactionID, ptr.AssetID, DanglingTransactionNotFound)
+		return nil, nil
+	}
+	target := PointerTarget{Transaction: refTx}
+	if ptr.AssetID != nil {
+		if target.Asset = findAsset(refTx, ptr.AssetID); target.Asset == nil {
+			r.addDangling(path, ptr.TransactionID, ptr.AssetID, DanglingAssetNotFound)
+			return nil, nil
+		}
+	}
+	return &target, nil
+}
+
+// ResolveReferences resolves the BBcReference and BBcPointer objects in the transaction with the lookup
+//
+// PointerTarget objects of the resolved pointers are returned. The error is DanglingReferenceErrors if some of them cannot be resolved.
+func ResolveReferences(transaction *BBcTransaction, lookup TransactionLookup) ([]*PointerTarget, error) {
+	if transaction == nil || lookup == nil {
+		return nil, fmt.Errorf("transaction and lookup must be set")
+	}
+	r := referenceResolver{lookup: lookup, cache: make(map[string]*BBcTransaction)}
+
+	for i, ref := range transaction.References {
+		if ref == nil {
+			continue
+		}
+		if err := r.resolveReference(fmt.Sprintf("References[%d]", i), transaction, ref); err != nil {
+			return nil, err
+		}
+	}
+
+	var targets []*PointerTarget
+	for i, rtn := range transaction.Relations {
+		if rtn == nil {
+			continue
+		}
+		for j, ptr := range rtn.Pointers {
+			if ptr == nil {
+				continue
+			}
+			target, err := r.resolvePointer(fmt.Sprintf("Relations[%d].Pointers[%d]", i, j), ptr)
+			if err != nil {
+				return nil, err
+			}
+			if target != nil {
+				target.RelationIndex, target.PointerIndex = i, j
+				targets = append(targets, target)
+			}
+		}
+	}
+
+	if len(r.dangling) > 0 {
+		return targets, r.dangling
+	}
+	return targets, nil
+}
@@ -0,0 +1,138 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type failingLookup struct{}
+
+func (failingLookup) Get(transactionID []byte) (*BBcTransaction, error) {
+	return nil, errors.New("I/O error")
+}
+
+// makeResolverTestTransactions makes a transaction and the transaction which refers to its event and points to it from a relation
+func makeResolverTestTransactions(t *testing.T) (*BBcTransaction, *BBcTransaction) {
+	reftx := makeTestTransaction(t, func(b *TransactionBuilder) {
+		b.Event(testAssetGroupID).Asset(testUsers[0]).BodyString("event").Approver(testUsers[0]).
+			Relation(testAssetGroupID).Asset(testUsers[1]).BodyString("relation")
+	})
+	txobj := makeTestTransaction(t, func(b *TransactionBuilder) {
+		b.Event(testAssetGroupID).Asset(testUsers[1]).BodyString("new event").Approver(testUsers[1]).
+			Reference(testAssetGroupID, reftx, 0).ReferenceApprover(testUsers[0]).
+			Relation(testAssetGroupID).Pointer(reftx.TransactionID, reftx.Relations[0].Asset.AssetID).Pointer(reftx.TransactionID, nil)
+	}, 0)
+	return reftx, txobj
+}
+
+func TestResolveReferences(t *testing.T) {
+	reftx, txobj := makeResolverTestTransactions(t)
+	store := NewMemoryTransactionStore(FormatPlain)
+	store.Put(reftx)
+
+	dat, _ := Serialize(txobj, FormatPlain)
+	received, _ := Deserialize(dat)
+	if received.References[0].RefTransaction != nil {
+		t.Fatal("RefTransaction should be empty after Deserialize")
+	}
+
+	targets, err := ResolveReferences(received, store)
+	if err != nil {
+		t.Fatalf("failed to resolve (%v)", err)
+	}
+	ref := received.References[0]
+	if ref.RefTransaction == nil || !bytes.Equal(ref.RefTransaction.TransactionID, reftx.TransactionID) || ref.Transaction != received {
+		t.Fatal("reference is not resolved")
+	}
+	if !ref.RefEvent.Equal(reftx.Events[0]) {
+		t.Fatalf("RefEvent differs: %v", ref.RefEvent.Diff(reftx.Events[0]))
+	}
+	if len(targets) != 2 {
+		t.Fatalf("2 pointer targets are expected but %d", len(targets))
+	}
+	if targets[0].RelationIndex != 0 || targets[0].PointerIndex != 0 || !targets[0].Asset.Equal(reftx.Relations[0].Asset) {
+		t.Fatal("pointer with AssetID is not resolved")
+	}
+	if targets[1].PointerIndex != 1 || targets[1].Asset != nil || !bytes.Equal(targets[1].Transaction.TransactionID, reftx.TransactionID) {
+		t.Fatal("pointer without AssetID is not resolved")
+	}
+
+	// the resolved reference can be used for approval checks without giving the past transactions
+	verifier := ApprovalVerifier{PubkeyResolver: testPubkeyResolver}
+	report, err := verifier.Verify(received, nil)
+	if err != nil || !report.Approved() {
+		t.Fatalf("approval check failed (%v)", err)
+	}
+}
+
+func TestResolveDanglingReferences(t *testing.T) {
+	reftx, txobj := makeResolverTestTransactions(t)
+
+	t.Run("transaction not found", func(t *testing.T) {
+		targets, err := ResolveReferences(txobj.Clone(), NewMemoryTransactionStore(FormatPlain))
+		errs, ok := err.(DanglingReferenceErrors)
+		if !ok || len(errs) != 3 || len(targets) != 0 {
+			t.Fatalf("3 dangling references are expected (%v)", err)
+		}
+		for _, e := range errs {
+			if e.Reason != DanglingTransactionNotFound {
+				t.Fatalf("unexpected reason: %v", e)
+			}
+		}
+		if errs[0].Path != "References[0]" || errs[2].Path != "Relations[0].Pointers[1]" {
+			t.Fatalf("unexpected paths: %v", err)
+		}
+	})
+
+	t.Run("event and asset not found", func(t *testing.T) {
+		store := NewMemoryTransactionStore(FormatPlain)
+		store.Put(reftx)
+		obj := txobj.Clone()
+		obj.References[0].EventIndexInRef = 5
+		obj.Relations[0].Pointers[0].AssetID = GetIdentifier("unknown asset", defaultIDLength)
+		targets, err := ResolveReferences(obj, store)
+		errs, ok := err.(DanglingReferenceErrors)
+		if !ok || len(errs) != 2 || errs[0].Reason != DanglingEventNotFound || errs[1].Reason != DanglingAssetNotFound {
+			t.Fatalf("unexpected errors (%v)", err)
+		}
+		if len(targets) != 1 || targets[0].PointerIndex != 1 {
+			t.Fatal("resolvable pointer should be resolved")
+		}
+		t.Log(err)
+	})
+
+	t.Run("asset group mismatch", func(t *testing.T) {
+		store := NewMemoryTransactionStore(FormatPlain)
+		store.Put(reftx)
+		obj := txobj.Clone()
+		obj.References[0].AssetGroupID = GetIdentifier("another group", defaultIDLength)
+		_, err := ResolveReferences(obj, store)
+		if errs, ok := err.(DanglingReferenceErrors); !ok || len(errs) != 1 || errs[0].Reason != DanglingAssetGroupMismatch {
+			t.Fatalf("unexpected errors (%v)", err)
+		}
+	})
+
+	t.Run("lookup error", func(t *testing.T) {
+		_, err := ResolveReferences(txobj.Clone(), failingLookup{})
+		if _, ok := err.(DanglingReferenceErrors); ok || err == nil {
+			t.Fatalf("lookup error should be returned as is (%v)", err)
+		}
+	})
+}
@@ -16,7 +16,6 @@ limitations under the License.
 
 package bbclib
 
-import "C"
 import (
 	"bytes"
 	"encoding/binary"
@@ -58,8 +57,13 @@ func (p *BBcSignature) SetPublicKey(keyType uint32, pubkey *[]byte) {
 
 // SetPublicKeyByKeypair sets public key (in keypair object) in the object
 func (p *BBcSignature) SetPublicKeyByKeypair(keypair *KeyPair) {
-	p.KeyType = uint32(keypair.CurveType)
-	p.Pubkey = keypair.Pubkey
+	p.SetPublicKeyBySigner(keypair)
+}
+
+// SetPublicKeyBySigner sets public key of the signer in the object
+func (p *BBcSignature) SetPublicKeyBySigner(signer Signer) {
+	p.KeyType = signer.KeyType()
+	p.Pubkey = signer.PublicKey()
 	p.PubkeyLen = uint32(len(p.Pubkey) * 8)
 }
 
@@ -114,17 +118,17 @@ func (p *BBcSignature) Unpack(dat *[]byte) error {
 	if err != nil {
 		return err
 	}
-	p.Pubkey = make([]byte, int(p.PubkeyLen/8))
-	p.Pubkey, _ = GetBytes(buf, int(p.PubkeyLen/8))
+	p.Pubkey, err = GetBytes(buf, int(p.PubkeyLen/8))
+	if err != nil {
+		return err
+	}
 
 	p.SignatureLen, err = Get4byte(buf)
 	if err != nil {
 		return err
 	}
-	p.Signature = make([]byte, int(p.SignatureLen/8))
-	p.Signature, _ = GetBytes(buf, int(p.SignatureLen/8))
-
-	return nil
+	p.Signature, err = GetBytes(buf, int(p.SignatureLen/8))
+	return err
 }
 
 // RecoverSignatureObject is a utility for recovering signature data into BBcSignature object
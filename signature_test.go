@@ -77,4 +77,27 @@ func TestSignaturePackUnpack(t *testing.T) {
 			t.Fatal("Not recovered correctly...")
 		}
 	})
+	t.Run("simple creation (Ed25519)", func(t *testing.T) {
+		keypair := GenerateKeypair(KeyTypeEd25519, defaultCompressionMode)
+		sig := BBcSignature{}
+		sig.SetPublicKeyByKeypair(&keypair)
+		digest := GetRandomValue(32)
+		signature, _ := keypair.Sign(digest)
+		sig.SetSignature(&signature)
+		if sig.PubkeyLen != 256 || sig.SignatureLen != 512 {
+			t.Fatalf("invalid length: PubkeyLen=%d, SignatureLen=%d", sig.PubkeyLen, sig.SignatureLen)
+		}
+
+		dat, err := sig.Pack()
+		if err != nil {
+			t.Fatalf("failed to serialize signature object (%v)", err)
+		}
+		t.Logf("Packed data: %x", dat)
+
+		obj2 := RecoverSignatureObject(&dat)
+		if obj2.KeyType != KeyTypeEd25519 || bytes.Compare(sig.Pubkey, obj2.Pubkey) != 0 ||
+			bytes.Compare(sig.Signature, obj2.Signature) != 0 || !obj2.Verify(digest) {
+			t.Fatal("Not recovered correctly...")
+		}
+	})
 }
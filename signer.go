@@ -0,0 +1,111 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"sync"
+)
+
+/*
+Signer definition
+
+A Signer holds (or has an access to) a private key and signs to a digest, e.g., TransactionID.
+The private key itself is not needed to be exported, so that a key held in an agent process, an HSM or a remote service can be used for signing.
+KeyPair is the simplest implementation of Signer.
+
+"KeyType" returns the key type of BBcSignature (KeyTypeEcdsaSECP256k1, KeyTypeEcdsaP256v1 or KeyTypeEd25519).
+"Sign" returns an error if it fails to sign (e.g., the signing daemon is not reachable).
+*/
+type (
+	Signer interface {
+		KeyType() uint32
+		PublicKey() []byte
+		Sign(digest []byte) ([]byte, error)
+	}
+)
+
+/*
+Verifier definition
+
+A Verifier verifies a signature for a digest with a public key. A Verifier is registered for each KeyType of BBcSignature,
+and VerifyBBcSignature looks up the Verifier in the registry according to the KeyType of the given BBcSignature object.
+*/
+type (
+	Verifier interface {
+		Verify(pubkey []byte, digest []byte, sig []byte) bool
+	}
+
+	// VerifierFunc is an adapter to use an ordinary function as a Verifier
+	VerifierFunc func(pubkey []byte, digest []byte, sig []byte) bool
+)
+
+// The registry of Verifiers (KeyType -> Verifier)
+var (
+	verifierLock sync.RWMutex
+	verifiers    = make(map[uint32]Verifier)
+)
+
+func init() {
+	for _, keyType := range []int{KeyTypeEcdsaSECP256k1, KeyTypeEcdsaP256v1, KeyTypeEd25519} {
+		curveType := keyType
+		RegisterVerifier(uint32(curveType), VerifierFunc(func(pubkey []byte, digest []byte, sig []byte) bool {
+			return verify(curveType, pubkey, digest, sig)
+		}))
+	}
+}
+
+// Verify calls f(pubkey, digest, sig)
+func (f VerifierFunc) Verify(pubkey []byte, digest []byte, sig []byte) bool {
+	return f(pubkey, digest, sig)
+}
+
+// RegisterVerifier sets the Verifier for the keyType in the registry (the existing one is replaced)
+func RegisterVerifier(keyType uint32, verifier Verifier) {
+	verifierLock.Lock()
+	defer verifierLock.Unlock()
+	if verifier == nil {
+		delete(verifiers, keyType)
+		return
+	}
+	verifiers[keyType] = verifier
+}
+
+// GetVerifier returns the Verifier for the keyType (nil if not registered)
+func GetVerifier(keyType uint32) Verifier {
+	verifierLock.RLock()
+	defer verifierLock.RUnlock()
+	return verifiers[keyType]
+}
+
+// VerifyBBcSignature verifies a given digest with BBcSignature object
+func VerifyBBcSignature(digest []byte, sig *BBcSignature) bool {
+	verifier := GetVerifier(sig.KeyType)
+	if verifier == nil {
+		return false
+	}
+	return verifier.Verify(sig.Pubkey, digest, sig.Signature)
+}
+
+// KeyType returns the key type for BBcSignature (KeyPair implements Signer)
+func (k *KeyPair) KeyType() uint32 {
+	return uint32(k.CurveType)
+}
+
+// PublicKey returns the public key (KeyPair implements Signer)
+func (k *KeyPair) PublicKey() []byte {
+	return k.Pubkey
+}
@@ -0,0 +1,91 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"testing"
+)
+
+const keyTypeTestHmac = 0xff00
+
+// hmacSigner is a Signer for testing, which "signs" with HMAC-SHA256 and uses the key as the "public key"
+type hmacSigner struct {
+	key []byte
+}
+
+func (s *hmacSigner) KeyType() uint32 {
+	return keyTypeTestHmac
+}
+
+func (s *hmacSigner) PublicKey() []byte {
+	return s.key
+}
+
+func (s *hmacSigner) Sign(digest []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(digest)
+	return mac.Sum(nil), nil
+}
+
+func TestKeyPair_Signer(t *testing.T) {
+	keypair := GenerateKeypair(KeyTypeEcdsaP256v1, defaultCompressionMode)
+	var signer Signer = &keypair
+	if signer.KeyType() != KeyTypeEcdsaP256v1 || !bytes.Equal(signer.PublicKey(), keypair.Pubkey) {
+		t.Fatal("KeyPair does not work as Signer")
+	}
+	if GetVerifier(KeyTypeEcdsaP256v1) == nil || GetVerifier(KeyTypeEcdsaSECP256k1) == nil {
+		t.Fatal("default verifiers are not registered")
+	}
+}
+
+func TestRegisterVerifier(t *testing.T) {
+	signer := &hmacSigner{key: GetRandomValue(32)}
+	keypair := GenerateKeypair(KeyTypeEcdsaSECP256k1, defaultCompressionMode)
+	assetGroupID := GetIdentifierWithTimestamp("assetGroupID", defaultIDLength)
+	user1 := GetIdentifierWithTimestamp("user1", defaultIDLength)
+	user2 := GetIdentifierWithTimestamp("user2", defaultIDLength)
+	RegisterVerifier(keyTypeTestHmac, VerifierFunc(func(pubkey []byte, digest []byte, sig []byte) bool {
+		s := hmacSigner{key: pubkey}
+		mac, _ := s.Sign(digest)
+		return hmac.Equal(mac, sig)
+	}))
+
+	txobj := MakeTransaction(1, 0, false, 32)
+	AddEventAssetBodyString(txobj, 0, &assetGroupID, &user1, "teststring!!!!!")
+	if err := SignToTransaction(txobj, &user1, signer); err != nil {
+		t.Fatalf("failed to sign (%v)", err)
+	}
+	if err := SignToTransaction(txobj, &user2, &keypair); err != nil {
+		t.Fatalf("failed to sign (%v)", err)
+	}
+	if result, _ := txobj.VerifyAll(); !result {
+		t.Fatal("Verification failed..")
+	}
+
+	RegisterVerifier(keyTypeTestHmac, nil)
+	if result, idx := txobj.VerifyAll(); result || idx != 0 {
+		t.Fatal("Verify returns true but not correct...")
+	}
+	t.Log("Verify failed as expected")
+
+	if _, err := txobj.Sign(nil); err == nil {
+		t.Fatal("Sign without signer must fail")
+	}
+}
@@ -0,0 +1,274 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+/*
+UnixSocketSigner definition
+
+UnixSocketSigner is a Signer that asks a signing daemon listening on a local Unix domain socket to sign a digest,
+so that the private key never leaves the daemon process. "KeyName" specifies which key in the daemon is used.
+SignerServer is the daemon side, which serves Signer objects (e.g., KeyPair objects) with names.
+
+Protocol
+
+One connection carries one request and one response. Each message is prepended a 4-byte length (little endian).
+Variable length fields are packed in the same manner as PutBigInt (2-byte length + data).
+  request:  command (2 bytes) | key name | digest (sign command only)
+  response: status (2 bytes) | payload
+    public key command: key type (4 bytes) | public key
+    sign command:       signature
+    error:              error message
+*/
+type (
+	UnixSocketSigner struct {
+		SocketPath string
+		KeyName    string
+		Timeout    time.Duration
+		keyType    uint32
+		pubkey     []byte
+	}
+
+	SignerServer struct {
+		lock    sync.RWMutex
+		signers map[string]Signer
+	}
+)
+
+// Commands and status codes of the signing daemon protocol
+const (
+	signerCommandPublicKey = 0x0001
+	signerCommandSign      = 0x0002
+
+	signerStatusOK    = 0x0000
+	signerStatusError = 0x0001
+
+	signerMaxMessageSize = 0x10000
+	defaultSignerTimeout = 5 * time.Second
+)
+
+// writeSignerMessage sends a message with the length header
+func writeSignerMessage(w io.Writer, msg []byte) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(msg))); err != nil {
+		return err
+	}
+	_, err := w.Write(msg)
+	return err
+}
+
+// readSignerMessage receives a message with the length header
+func readSignerMessage(r io.Reader) (*bytes.Buffer, error) {
+	var size uint32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return nil, err
+	}
+	if size > signerMaxMessageSize {
+		return nil, errors.New("too large message")
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(msg), nil
+}
+
+// NewUnixSocketSigner connects to the signing daemon and returns a UnixSocketSigner for the key specified by keyName
+func NewUnixSocketSigner(socketPath string, keyName string) (*UnixSocketSigner, error) {
+	s := &UnixSocketSigner{SocketPath: socketPath, KeyName: keyName, Timeout: defaultSignerTimeout}
+	resp, err := s.request(signerCommandPublicKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.keyType, err = Get4byte(resp); err != nil {
+		return nil, err
+	}
+	if s.pubkey, err = GetBigInt(resp); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// KeyType returns the key type of the key in the daemon
+func (s *UnixSocketSigner) KeyType() uint32 {
+	return s.keyType
+}
+
+// PublicKey returns the public key of the key in the daemon
+func (s *UnixSocketSigner) PublicKey() []byte {
+	return s.pubkey
+}
+
+// Sign asks the daemon to sign to a given digest
+func (s *UnixSocketSigner) Sign(digest []byte) ([]byte, error) {
+	resp, err := s.request(signerCommandSign, digest)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := GetBigInt(resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(sig) == 0 {
+		return nil, errors.New("signing daemon returns an empty signature")
+	}
+	return sig, nil
+}
+
+// request sends a command to the daemon and returns the payload of the response
+func (s *UnixSocketSigner) request(command uint16, digest []byte) (*bytes.Buffer, error) {
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = defaultSignerTimeout
+	}
+	conn, err := net.DialTimeout("unix", s.SocketPath, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	Put2byte(buf, command)
+	keyName := []byte(s.KeyName)
+	PutBigInt(buf, &keyName, len(keyName))
+	if command == signerCommandSign {
+		PutBigInt(buf, &digest, len(digest))
+	}
+	if err := writeSignerMessage(conn, buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	resp, err := readSignerMessage(conn)
+	if err != nil {
+		return nil, err
+	}
+	status, err := Get2byte(resp)
+	if err != nil {
+		return nil, err
+	}
+	if status != signerStatusOK {
+		msg, _ := GetBigInt(resp)
+		return nil, fmt.Errorf("signing daemon returns an error: %s", msg)
+	}
+	return resp, nil
+}
+
+// NewSignerServer returns a SignerServer object without any signer
+func NewSignerServer() *SignerServer {
+	return &SignerServer{signers: make(map[string]Signer)}
+}
+
+// AddSigner registers the signer with the name in the server
+func (s *SignerServer) AddSigner(name string, signer Signer) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.signers[name] = signer
+}
+
+// RemoveSigner unregisters the signer with the name
+func (s *SignerServer) RemoveSigner(name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.signers, name)
+}
+
+// Serve accepts connections on the listener (e.g., net.Listen("unix", path)) and handles requests until the listener is closed
+func (s *SignerServer) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle processes a request in the connection
+func (s *SignerServer) handle(conn net.Conn) {
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(defaultSignerTimeout)); err != nil {
+		return
+	}
+
+	resp, err := s.process(conn)
+	buf := new(bytes.Buffer)
+	if err != nil {
+		msg := []byte(err.Error())
+		Put2byte(buf, signerStatusError)
+		PutBigInt(buf, &msg, len(msg))
+	} else {
+		Put2byte(buf, signerStatusOK)
+		buf.Write(resp)
+	}
+	writeSignerMessage(conn, buf.Bytes())
+}
+
+// process reads a request and returns the payload of the response
+func (s *SignerServer) process(r io.Reader) ([]byte, error) {
+	req, err := readSignerMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	command, err := Get2byte(req)
+	if err != nil {
+		return nil, err
+	}
+	keyName, err := GetBigInt(req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	signer, ok := s.signers[string(keyName)]
+	s.lock.RUnlock()
+	if !ok {
+		return nil, errors.New("no such key")
+	}
+
+	buf := new(bytes.Buffer)
+	switch command {
+	case signerCommandPublicKey:
+		pubkey := signer.PublicKey()
+		Put4byte(buf, signer.KeyType())
+		PutBigInt(buf, &pubkey, len(pubkey))
+	case signerCommandSign:
+		digest, err := GetBigInt(req)
+		if err != nil {
+			return nil, err
+		}
+		sig, err := signer.Sign(digest)
+		if err != nil {
+			return nil, err
+		}
+		PutBigInt(buf, &sig, len(sig))
+	default:
+		return nil, errors.New("unknown command")
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func startSignerServer(t *testing.T) (string, net.Listener) {
+	socketPath := filepath.Join(t.TempDir(), "signer.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("failed to listen (%v)", err)
+	}
+	server := NewSignerServer()
+	kp1 := GenerateKeypair(KeyTypeEcdsaP256v1, defaultCompressionMode)
+	kp2 := GenerateKeypair(KeyTypeEcdsaSECP256k1, defaultCompressionMode)
+	server.AddSigner("user1", &kp1)
+	server.AddSigner("user2", &kp2)
+	go server.Serve(listener)
+	return socketPath, listener
+}
+
+func TestUnixSocketSigner(t *testing.T) {
+	socketPath, listener := startSignerServer(t)
+	defer listener.Close()
+	assetGroupID := GetIdentifierWithTimestamp("assetGroupID", defaultIDLength)
+	u1 := GetIdentifierWithTimestamp("user1", defaultIDLength)
+	u2 := GetIdentifierWithTimestamp("user2", defaultIDLength)
+
+	signer1, err := NewUnixSocketSigner(socketPath, "user1")
+	if err != nil {
+		t.Fatalf("failed to connect to the daemon (%v)", err)
+	}
+	signer2, err := NewUnixSocketSigner(socketPath, "user2")
+	if err != nil {
+		t.Fatalf("failed to connect to the daemon (%v)", err)
+	}
+	if signer1.KeyType() != KeyTypeEcdsaP256v1 || signer2.KeyType() != KeyTypeEcdsaSECP256k1 || len(signer1.PublicKey()) != 65 {
+		t.Fatal("failed to get the public key")
+	}
+
+	t.Run("sign transaction", func(t *testing.T) {
+		txobj := MakeTransaction(1, 0, true, 32)
+		AddEventAssetBodyString(txobj, 0, &assetGroupID, &u1, "teststring!!!!!")
+		txobj.Witness.AddWitness(&u1)
+		txobj.Witness.AddWitness(&u2)
+		if err := SignToTransaction(txobj, &u1, signer1); err != nil {
+			t.Fatalf("failed to sign (%v)", err)
+		}
+		if err := SignToTransaction(txobj, &u2, signer2); err != nil {
+			t.Fatalf("failed to sign (%v)", err)
+		}
+
+		dat, err := txobj.Pack()
+		if err != nil {
+			t.Fatalf("failed to pack transaction object (%v)", err)
+		}
+		obj2 := BBcTransaction{}
+		obj2.Unpack(&dat)
+		if result, _ := obj2.VerifyAll(); !result {
+			t.Fatal("Verification failed..")
+		}
+		if !bytes.Equal(obj2.Signatures[1].Pubkey, signer2.PublicKey()) {
+			t.Fatal("Not recovered correctly...")
+		}
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		if _, err := NewUnixSocketSigner(socketPath, "user3"); err == nil {
+			t.Fatal("unknown key must not be available")
+		}
+		signer3 := UnixSocketSigner{SocketPath: socketPath, KeyName: "user3"}
+		if _, err := signer3.Sign(GetRandomValue(32)); err == nil || !strings.Contains(err.Error(), "no such key") {
+			t.Fatalf("error of the daemon must be returned (%v)", err)
+		}
+		t.Log("failed as expected")
+	})
+
+	t.Run("daemon stopped", func(t *testing.T) {
+		listener.Close()
+		txobj := MakeTransaction(1, 0, false, 32)
+		AddEventAssetBodyString(txobj, 0, &assetGroupID, &u1, "teststring!!!!!")
+		if sig, err := signer1.Sign(txobj.Digest()); sig != nil || err == nil {
+			t.Fatal("Sign returns signature but daemon is stopped")
+		} else {
+			t.Logf("error: %v", err)
+		}
+		if err := SignToTransaction(txobj, &u1, signer1); err == nil {
+			t.Fatal("SignToTransaction must fail")
+		}
+		t.Log("failed as expected")
+	})
+}
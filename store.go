@@ -0,0 +1,236 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+)
+
+/*
+TransactionStore definition
+
+TransactionStore keeps transactions and finds them by TransactionID, AssetID, AssetGroupID and UserID.
+Implementations store the output of Serialize and keep secondary indices, and must be safe for concurrent use.
+
+The indices are made from the BBcAsset objects and the asset groups in BBcEvent and BBcRelation objects:
+  - AssetID: AssetID of the BBcAsset objects
+  - AssetGroupID: AssetGroupID of the BBcEvent and BBcRelation objects
+  - UserID: UserID of the BBcAsset objects (i.e., the owners of the assets)
+
+The lookup methods return the transactions in the order of TransactionID, and an empty list if nothing is found.
+Get returns ErrTransactionNotFound if the transaction is not stored.
+Putting a transaction with the same TransactionID again replaces the stored data.
+
+MemoryTransactionStore (in this file) keeps everything in memory, and BoltTransactionStore (store_bolt.go) keeps it in a bbolt file.
+*/
+type (
+	TransactionStore interface {
+		Put(transaction *BBcTransaction) error
+		Get(transactionID []byte) (*BBcTransaction, error)
+		GetByAssetID(assetID []byte) ([]*BBcTransaction, error)
+		GetByAssetGroupID(assetGroupID []byte) ([]*BBcTransaction, error)
+		GetByUserID(userID []byte) ([]*BBcTransaction, error)
+		Close() error
+	}
+
+	// MemoryTransactionStore is a TransactionStore in memory
+	MemoryTransactionStore struct {
+		formatType   uint16
+		lock         sync.RWMutex
+		transactions map[string][]byte
+		indices      map[string]map[string]map[string]struct{}
+	}
+
+	// transactionIndex holds the keys of the secondary indices of a transaction
+	transactionIndex struct {
+		name string
+		keys [][]byte
+	}
+)
+
+// ErrTransactionNotFound is returned by TransactionStore.Get if the transaction is not stored
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// names of the secondary indices
+const (
+	indexAssetID      = "asset_id"
+	indexAssetGroupID = "asset_group_id"
+	indexUserID       = "user_id"
+)
+
+// appendKey appends the key to the list if it is not empty and not in the list
+func appendKey(keys [][]byte, key []byte) [][]byte {
+	if len(key) == 0 {
+		return keys
+	}
+	for _, k := range keys {
+		if bytes.Equal(k, key) {
+			return keys
+		}
+	}
+	return append(keys, key)
+}
+
+// makeTransactionIndices returns the keys of the secondary indices of the transaction
+func makeTransactionIndices(transaction *BBcTransaction) []transactionIndex {
+	var assetIDs, assetGroupIDs, userIDs [][]byte
+	addAsset := func(asset *BBcAsset) {
+		if asset == nil {
+			return
+		}
+		if asset.AssetID == nil {
+			asset.Digest()
+		}
+		assetIDs = appendKey(assetIDs, asset.AssetID)
+		userIDs = appendKey(userIDs, asset.UserID)
+	}
+	for _, evt := range transaction.Events {
+		if evt != nil {
+			assetGroupIDs = appendKey(assetGroupIDs, evt.AssetGroupID)
+			addAsset(evt.Asset)
+		}
+	}
+	for _, rtn := range transaction.Relations {
+		if rtn != nil {
+			assetGroupIDs = appendKey(assetGroupIDs, rtn.AssetGroupID)
+			addAsset(rtn.Asset)
+		}
+	}
+	return []transactionIndex{
+		{indexAssetID, assetIDs},
+		{indexAssetGroupID, assetGroupIDs},
+		{indexUserID, userIDs},
+	}
+}
+
+// NewMemoryTransactionStore returns a TransactionStore in memory, which stores the transactions serialized in formatType
+func NewMemoryTransactionStore(formatType uint16) *MemoryTransactionStore {
+	return &MemoryTransactionStore{
+		formatType:   formatType,
+		transactions: make(map[string][]byte),
+		indices: map[string]map[string]map[string]struct{}{
+			indexAssetID:      make(map[string]map[string]struct{}),
+			indexAssetGroupID: make(map[string]map[string]struct{}),
+			indexUserID:       make(map[string]map[string]struct{}),
+		},
+	}
+}
+
+// Put stores the transaction
+func (s *MemoryTransactionStore) Put(transaction *BBcTransaction) error {
+	if transaction == nil {
+		return errors.New("transaction is nil")
+	}
+	dat, err := Serialize(transaction, s.formatType)
+	if err != nil {
+		return err
+	}
+	txid := string(transaction.TransactionID)
+	indices := makeTransactionIndices(transaction)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if old, ok := s.transactions[txid]; ok {
+		if oldTx, err := Deserialize(old); err == nil {
+			s.removeIndices(txid, makeTransactionIndices(oldTx))
+		}
+	}
+	s.transactions[txid] = dat
+	for _, idx := range indices {
+		for _, key := range idx.keys {
+			txids, ok := s.indices[idx.name][string(key)]
+			if !ok {
+				txids = make(map[string]struct{})
+				s.indices[idx.name][string(key)] = txids
+			}
+			txids[txid] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// removeIndices removes the transaction from the secondary indices
+func (s *MemoryTransactionStore) removeIndices(txid string, indices []transactionIndex) {
+	for _, idx := range indices {
+		for _, key := range idx.keys {
+			txids := s.indices[idx.name][string(key)]
+			delete(txids, txid)
+			if len(txids) == 0 {
+				delete(s.indices[idx.name], string(key))
+			}
+		}
+	}
+}
+
+// Get returns the transaction of the transactionID
+func (s *MemoryTransactionStore) Get(transactionID []byte) (*BBcTransaction, error) {
+	s.lock.RLock()
+	dat, ok := s.transactions[string(transactionID)]
+	s.lock.RUnlock()
+	if !ok {
+		return nil, ErrTransactionNotFound
+	}
+	return Deserialize(dat)
+}
+
+// getByIndex returns the transactions which have the key in the index
+func (s *MemoryTransactionStore) getByIndex(name string, key []byte) ([]*BBcTransaction, error) {
+	s.lock.RLock()
+	txids := make([]string, 0, len(s.indices[name][string(key)]))
+	for txid := range s.indices[name][string(key)] {
+		txids = append(txids, txid)
+	}
+	sort.Strings(txids)
+	data := make([][]byte, len(txids))
+	for i, txid := range txids {
+		data[i] = s.transactions[txid]
+	}
+	s.lock.RUnlock()
+
+	ret := make([]*BBcTransaction, 0, len(data))
+	for _, dat := range data {
+		txobj, err := Deserialize(dat)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, txobj)
+	}
+	return ret, nil
+}
+
+// GetByAssetID returns the transactions which include the asset
+func (s *MemoryTransactionStore) GetByAssetID(assetID []byte) ([]*BBcTransaction, error) {
+	return s.getByIndex(indexAssetID, assetID)
+}
+
+// GetByAssetGroupID returns the transactions which include the asset group
+func (s *MemoryTransactionStore) GetByAssetGroupID(assetGroupID []byte) ([]*BBcTransaction, error) {
+	return s.getByIndex(indexAssetGroupID, assetGroupID)
+}
+
+// GetByUserID returns the transactions which include the assets of the user
+func (s *MemoryTransactionStore) GetByUserID(userID []byte) ([]*BBcTransaction, error) {
+	return s.getByIndex(indexUserID, userID)
+}
+
+// Close does nothing for MemoryTransactionStore
+func (s *MemoryTransactionStore) Close() error {
+	return nil
+}
@@ -0,0 +1,193 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+/*
+BoltTransactionStore definition
+
+BoltTransactionStore is a TransactionStore in a file of bbolt (an embedded key-value store, https://github.com/etcd-io/bbolt).
+
+The file has a bucket for the transactions (TransactionID -> serialized data) and a bucket for each secondary index.
+The key of an index entry is "length of the key (1 byte) | key | TransactionID" and the value is empty,
+so that the transactions of a key are found by a prefix scan in the order of TransactionID.
+*/
+type (
+	BoltTransactionStore struct {
+		formatType uint16
+		db         *bolt.DB
+	}
+)
+
+var boltTransactionBucket = []byte("transactions")
+
+// boltIndexPrefix returns the prefix of the index entries of the key
+func boltIndexPrefix(key []byte) ([]byte, error) {
+	if len(key) > 0xff {
+		return nil, fmt.Errorf("too long key for an index (%d bytes)", len(key))
+	}
+	return append([]byte{byte(len(key))}, key...), nil
+}
+
+// OpenBoltTransactionStore opens (or creates) the bbolt file and returns a TransactionStore which stores the transactions serialized in formatType
+func OpenBoltTransactionStore(path string, formatType uint16) (*BoltTransactionStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(btx *bolt.Tx) error {
+		for _, name := range []string{string(boltTransactionBucket), indexAssetID, indexAssetGroupID, indexUserID} {
+			if _, err := btx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltTransactionStore{formatType: formatType, db: db}, nil
+}
+
+// updateBoltIndices puts (or deletes if remove is true) the index entries of the transaction
+func updateBoltIndices(btx *bolt.Tx, txid []byte, indices []transactionIndex, remove bool) error {
+	for _, idx := range indices {
+		bucket := btx.Bucket([]byte(idx.name))
+		for _, key := range idx.keys {
+			prefix, err := boltIndexPrefix(key)
+			if err != nil {
+				return err
+			}
+			entry := append(prefix, txid...)
+			if remove {
+				err = bucket.Delete(entry)
+			} else {
+				err = bucket.Put(entry, []byte{})
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Put stores the transaction
+func (s *BoltTransactionStore) Put(transaction *BBcTransaction) error {
+	if transaction == nil {
+		return errors.New("transaction is nil")
+	}
+	dat, err := Serialize(transaction, s.formatType)
+	if err != nil {
+		return err
+	}
+	txid := transaction.TransactionID
+	indices := makeTransactionIndices(transaction)
+
+	return s.db.Update(func(btx *bolt.Tx) error {
+		bucket := btx.Bucket(boltTransactionBucket)
+		if old := bucket.Get(txid); old != nil {
+			if oldTx, err := Deserialize(old); err == nil {
+				if err := updateBoltIndices(btx, txid, makeTransactionIndices(oldTx), true); err != nil {
+					return err
+				}
+			}
+		}
+		if err := bucket.Put(txid, dat); err != nil {
+			return err
+		}
+		return updateBoltIndices(btx, txid, indices, false)
+	})
+}
+
+// Get returns the transaction of the transactionID
+func (s *BoltTransactionStore) Get(transactionID []byte) (*BBcTransaction, error) {
+	var dat []byte
+	err := s.db.View(func(btx *bolt.Tx) error {
+		if v := btx.Bucket(boltTransactionBucket).Get(transactionID); v != nil {
+			dat = append([]byte{}, v...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if dat == nil {
+		return nil, ErrTransactionNotFound
+	}
+	return Deserialize(dat)
+}
+
+// getByIndex returns the transactions which have the key in the index
+func (s *BoltTransactionStore) getByIndex(name string, key []byte) ([]*BBcTransaction, error) {
+	prefix, err := boltIndexPrefix(key)
+	if err != nil {
+		return nil, err
+	}
+	var data [][]byte
+	err = s.db.View(func(btx *bolt.Tx) error {
+		transactions := btx.Bucket(boltTransactionBucket)
+		c := btx.Bucket([]byte(name)).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			if v := transactions.Get(k[len(prefix):]); v != nil {
+				data = append(data, append([]byte{}, v...))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*BBcTransaction, 0, len(data))
+	for _, dat := range data {
+		txobj, err := Deserialize(dat)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, txobj)
+	}
+	return ret, nil
+}
+
+// GetByAssetID returns the transactions which include the asset
+func (s *BoltTransactionStore) GetByAssetID(assetID []byte) ([]*BBcTransaction, error) {
+	return s.getByIndex(indexAssetID, assetID)
+}
+
+// GetByAssetGroupID returns the transactions which include the asset group
+func (s *BoltTransactionStore) GetByAssetGroupID(assetGroupID []byte) ([]*BBcTransaction, error) {
+	return s.getByIndex(indexAssetGroupID, assetGroupID)
+}
+
+// GetByUserID returns the transactions which include the assets of the user
+func (s *BoltTransactionStore) GetByUserID(userID []byte) ([]*BBcTransaction, error) {
+	return s.getByIndex(indexUserID, userID)
+}
+
+// Close closes the bbolt file
+func (s *BoltTransactionStore) Close() error {
+	return s.db.Close()
+}
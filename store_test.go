@@ -0,0 +1,193 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// makeStoreTestTransaction makes a transaction with an event and a relation, and the asset of the event has the digest of file if it is not nil
+func makeStoreTestTransaction(t *testing.T, version uint32, assetGroupID, userID []byte, body string, file []byte) *BBcTransaction {
+	return makeTestTransaction(t, func(b *TransactionBuilder) {
+		b.Version(version).Event(assetGroupID).Asset(userID).BodyString(body).Approver(userID)
+		if file != nil {
+			b.File(file)
+		}
+		b.Relation(assetGroupID).Asset(userID).BodyString(body + " relation")
+	})
+}
+
+func testTransactionStore(t *testing.T, store TransactionStore) {
+	ag1 := GetIdentifier("asset_group_id_store1", defaultIDLength)
+	ag2 := GetIdentifier("asset_group_id_store2", defaultIDLength)
+	u1 := GetIdentifier("user1_store", defaultIDLength)
+	u2 := GetIdentifier("user2_store", defaultIDLength)
+
+	tx1 := makeStoreTestTransaction(t, DefaultTransactionVersion, ag1, u1, "tx1", nil)
+	tx2 := makeStoreTestTransaction(t, DefaultTransactionVersion, ag1, u2, "tx2", nil)
+	tx3 := makeStoreTestTransaction(t, DefaultTransactionVersion, ag2, u2, "tx3", nil)
+	for _, txobj := range []*BBcTransaction{tx1, tx2, tx3} {
+		if err := store.Put(txobj); err != nil {
+			t.Fatalf("failed to put (%v)", err)
+		}
+	}
+
+	t.Run("get", func(t *testing.T) {
+		obj, err := store.Get(tx2.TransactionID)
+		if err != nil {
+			t.Fatalf("failed to get (%v)", err)
+		}
+		if !obj.Equal(tx2) {
+			t.Fatalf("stored transaction differs: %v", obj.Diff(tx2))
+		}
+		if _, err := store.Get(GetIdentifier("unknown", defaultIDLength)); err != ErrTransactionNotFound {
+			t.Fatalf("ErrTransactionNotFound is expected (%v)", err)
+		}
+	})
+
+	t.Run("lookup", func(t *testing.T) {
+		cases := []struct {
+			name     string
+			lookup   func([]byte) ([]*BBcTransaction, error)
+			key      []byte
+			expected []*BBcTransaction
+		}{
+			{"asset_id", store.GetByAssetID, tx1.Relations[0].Asset.AssetID, []*BBcTransaction{tx1}},
+			{"asset_group_id", store.GetByAssetGroupID, ag1, []*BBcTransaction{tx1, tx2}},
+			{"user_id", store.GetByUserID, u2, []*BBcTransaction{tx2, tx3}},
+			{"unknown", store.GetByUserID, GetIdentifier("unknown", defaultIDLength), nil},
+		}
+		for _, c := range cases {
+			ret, err := c.lookup(c.key)
+			if err != nil {
+				t.Fatalf("%s: failed to lookup (%v)", c.name, err)
+			}
+			if len(ret) != len(c.expected) {
+				t.Fatalf("%s: %d transactions are expected but %d", c.name, len(c.expected), len(ret))
+			}
+			for _, e := range c.expected {
+				found := false
+				for _, obj := range ret {
+					found = found || bytes.Equal(obj.TransactionID, e.TransactionID)
+				}
+				if !found {
+					t.Fatalf("%s: transaction %x is not found", c.name, e.TransactionID)
+				}
+			}
+			for i := 1; i < len(ret); i++ {
+				if bytes.Compare(ret[i-1].TransactionID, ret[i].TransactionID) >= 0 {
+					t.Fatalf("%s: not in the order of TransactionID", c.name)
+				}
+			}
+		}
+	})
+
+	t.Run("concurrent put and get", func(t *testing.T) {
+		ag3 := GetIdentifier("asset_group_id_store3", defaultIDLength)
+		var wg sync.WaitGroup
+		errs := make(chan error, 20)
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			txobj := makeStoreTestTransaction(t, DefaultTransactionVersion, ag3, u1, fmt.Sprintf("concurrent%d", i), nil)
+			go func() {
+				defer wg.Done()
+				errs <- store.Put(txobj)
+			}()
+			go func() {
+				defer wg.Done()
+				_, err := store.GetByAssetGroupID(ag3)
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		ret, _ := store.GetByAssetGroupID(ag3)
+		if len(ret) != 10 {
+			t.Fatalf("10 transactions are expected but %d", len(ret))
+		}
+	})
+}
+
+func TestMemoryTransactionStore(t *testing.T) {
+	store := NewMemoryTransactionStore(FormatZlib)
+	defer store.Close()
+	testTransactionStore(t, store)
+}
+
+func TestBoltTransactionStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transactions.db")
+	store, err := OpenBoltTransactionStore(path, FormatZlib)
+	if err != nil {
+		t.Fatalf("failed to open (%v)", err)
+	}
+	testTransactionStore(t, store)
+
+	ag := GetIdentifier("asset_group_id_store1", defaultIDLength)
+	before, _ := store.GetByAssetGroupID(ag)
+	store.Close()
+
+	store, err = OpenBoltTransactionStore(path, FormatPlain)
+	if err != nil {
+		t.Fatalf("failed to reopen (%v)", err)
+	}
+	defer store.Close()
+	after, err := store.GetByAssetGroupID(ag)
+	if err != nil || len(after) != len(before) {
+		t.Fatalf("transactions are not persisted (%v)", err)
+	}
+}
+
+func TestTransactionStoreReplace(t *testing.T) {
+	boltStore, err := OpenBoltTransactionStore(filepath.Join(t.TempDir(), "replace.db"), FormatPlain)
+	if err != nil {
+		t.Fatalf("failed to open (%v)", err)
+	}
+	defer boltStore.Close()
+
+	for name, store := range map[string]TransactionStore{
+		"memory": NewMemoryTransactionStore(FormatPlain),
+		"bolt":   boltStore,
+	} {
+		ag := GetIdentifier("asset_group_id_replace", defaultIDLength)
+		u1 := GetIdentifier("user1_store", defaultIDLength)
+		u2 := GetIdentifier("user2_store", defaultIDLength)
+		txobj := makeStoreTestTransaction(t, DefaultTransactionVersion, ag, u1, "replace", nil)
+		store.Put(txobj)
+
+		// the same TransactionID with different index keys (not realistic but possible in a broken application)
+		modified := txobj.Clone()
+		modified.Events[0].Asset.UserID = u2
+		modified.Relations[0].Asset.UserID = u2
+		store.Put(modified)
+
+		if ret, _ := store.GetByUserID(u1); len(ret) != 0 {
+			t.Fatalf("%s: stale index entry remains", name)
+		}
+		if ret, _ := store.GetByUserID(u2); len(ret) != 1 {
+			t.Fatalf("%s: index entry is not updated", name)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+/*
+Encoder and Decoder definition
+
+Encoder writes BBcTransaction objects to an io.Writer one after another, and Decoder reads them from an io.Reader.
+Each transaction is written in the following frame:
+
+	format type (2 bytes) | size of payload (4 bytes) | payload
+
+The payload is the packed transaction in FormatPlain, and the packed transaction compressed by the Codec for the format type otherwise (see RegisterCodec),
+i.e., the frame is the serialized data by Serialize with the size of the payload inserted after the format type, so that a Decoder can find the end of each transaction.
+UnsupportedFormatError is returned if no Codec is registered for the format type.
+
+"Limits" of Decoder restricts the resources for decoding. DefaultDecodeLimits is used if it is nil.
+MaxSize is applied to the payload before reading it, and MaxDecompressedSize to the decompressed payload as in DeserializeWithLimits.
+A compressed payload is decompressed by the reader from the Codec while it is read from the stream (see Codec.NewReader).
+
+Decoder never reads beyond the frame which it decodes, so the data after the last frame is left in r.
+Decoder does not buffer r, so wrap r with bufio.Reader if small reads on it are expensive.
+*/
+type (
+	Encoder struct {
+		w          io.Writer
+		formatType uint16
+	}
+
+	Decoder struct {
+		r      io.Reader
+		Limits *DecodeLimits
+	}
+)
+
+// NewEncoder returns an Encoder object which writes transactions in the formatType
+func NewEncoder(w io.Writer, formatType uint16) *Encoder {
+	return &Encoder{w: w, formatType: formatType}
+}
+
+// Encode writes the transaction in the stream
+func (e *Encoder) Encode(transaction *BBcTransaction) error {
+	var codec Codec
+	if e.formatType != FormatPlain {
+		if codec = GetCodec(e.formatType); codec == nil {
+			return &UnsupportedFormatError{FormatType: e.formatType}
+		}
+	}
+	dat, err := transaction.Pack()
+	if err != nil {
+		return err
+	}
+	if codec != nil {
+		if dat, err = codec.Compress(dat); err != nil {
+			return err
+		}
+	}
+
+	var header [6]byte
+	binary.LittleEndian.PutUint16(header[0:2], e.formatType)
+	binary.LittleEndian.PutUint32(header[2:6], uint32(len(dat)))
+	if _, err := e.w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err = e.w.Write(dat)
+	return err
+}
+
+// NewDecoder returns a Decoder object which reads transactions from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode reads the next transaction from the stream (io.EOF is returned at the end of the stream)
+func (d *Decoder) Decode() (*BBcTransaction, error) {
+	limits := d.Limits
+	if limits == nil {
+		limits = &DefaultDecodeLimits
+	}
+
+	var header [6]byte
+	if _, err := io.ReadFull(d.r, header[:]); err != nil {
+		return nil, err
+	}
+	formatType := binary.LittleEndian.Uint16(header[0:2])
+	size := int64(binary.LittleEndian.Uint32(header[2:6]))
+
+	var codec Codec
+	if formatType != FormatPlain {
+		if codec = GetCodec(formatType); codec == nil {
+			return nil, &UnsupportedFormatError{FormatType: formatType}
+		}
+	}
+	// the size is compared in int64, so that it is not truncated on 32-bit platforms
+	if limits.MaxSize > 0 && size > int64(limits.MaxSize) {
+		return nil, &DecodeLimitError{Field: "serialized size", Value: size, Limit: int64(limits.MaxSize)}
+	}
+
+	payload := &io.LimitedReader{R: d.r, N: size}
+	var dat []byte
+	if codec != nil {
+		var err error
+		if dat, err = decompressPayload(codec, payload, limits.MaxDecompressedSize); err != nil {
+			return nil, err
+		}
+	} else {
+		// bytes.Buffer grows according to the data actually read (not to the declared size)
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, payload); err != nil {
+			return nil, err
+		}
+		dat = buf.Bytes()
+	}
+	if payload.N > 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	txobj := BBcTransaction{}
+	if err := txobj.unpack(&dat, limits); err != nil {
+		return nil, err
+	}
+	return &txobj, nil
+}
+
+// decompressPayload decompresses the payload while reading it from the stream
+func decompressPayload(codec Codec, payload *io.LimitedReader, maxSize int) ([]byte, error) {
+	reader, err := codec.NewReader(payload, maxSize)
+	var dat []byte
+	if err == nil {
+		dat, err = readAllWithLimit(reader, maxSize)
+		reader.Close()
+	}
+	// skip the rest of the payload which the decompressor has not read, so that the next frame can be read
+	if _, cerr := io.Copy(io.Discard, payload); cerr != nil {
+		return nil, cerr
+	}
+	if payload.N > 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return dat, err
+}
@@ -0,0 +1,146 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestEncoderDecoder(t *testing.T) {
+	var txobjs []*BBcTransaction
+	for i := 0; i < 3; i++ {
+		txobjs = append(txobjs, makeDecodeTestTransaction(t))
+	}
+
+	t.Run("formats", func(t *testing.T) {
+		RegisterCodec(0x0100, xorCodec{})
+		defer RegisterCodec(0x0100, nil)
+		formatTypes := []uint16{0x0100}
+		for _, f := range compressionFormats {
+			formatTypes = append(formatTypes, f.formatType)
+		}
+
+		buf := new(bytes.Buffer)
+		var expected []*BBcTransaction
+		for _, formatType := range formatTypes {
+			encoder := NewEncoder(buf, formatType)
+			for _, txobj := range txobjs {
+				if err := encoder.Encode(txobj); err != nil {
+					t.Fatalf("failed to encode in 0x%04x (%v)", formatType, err)
+				}
+				expected = append(expected, txobj)
+			}
+		}
+
+		decoder := NewDecoder(buf)
+		for i := range expected {
+			obj, err := decoder.Decode()
+			if err != nil {
+				t.Fatalf("failed to decode transaction[%d] (%v)", i, err)
+			}
+			if !bytes.Equal(obj.TransactionID, expected[i].TransactionID) {
+				t.Fatal("Not recovered correctly...")
+			}
+			if result, idx := obj.VerifyAll(); !result {
+				t.Fatalf("Verification failed..signature[%d]", idx)
+			}
+		}
+		if _, err := decoder.Decode(); err != io.EOF {
+			t.Fatalf("io.EOF must be returned (%v)", err)
+		}
+	})
+
+	t.Run("data after the frames", func(t *testing.T) {
+		for _, f := range compressionFormats {
+			buf := new(bytes.Buffer)
+			NewEncoder(buf, f.formatType).Encode(txobjs[0])
+			buf.WriteString("trailer")
+			decoder := NewDecoder(buf)
+			if _, err := decoder.Decode(); err != nil {
+				t.Fatalf("failed to decode in %s (%v)", f.name, err)
+			}
+			if buf.String() != "trailer" {
+				t.Fatalf("Decoder must not read beyond the frame in %s (%q is left)", f.name, buf.String())
+			}
+		}
+	})
+
+	t.Run("pipe", func(t *testing.T) {
+		r, w := io.Pipe()
+		go func() {
+			encoder := NewEncoder(w, FormatZlib)
+			for _, txobj := range txobjs {
+				encoder.Encode(txobj)
+			}
+			w.Close()
+		}()
+		decoder := NewDecoder(r)
+		num := 0
+		for {
+			_, err := decoder.Decode()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("failed to decode (%v)", err)
+			}
+			num++
+		}
+		if num != len(txobjs) {
+			t.Fatalf("%d transactions are decoded", num)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, f := range compressionFormats {
+			formatType := f.formatType
+			buf := new(bytes.Buffer)
+			NewEncoder(buf, formatType).Encode(txobjs[0])
+			dat := buf.Bytes()
+
+			truncated := NewDecoder(bytes.NewReader(dat[:len(dat)-3]))
+			if _, err := truncated.Decode(); err != io.ErrUnexpectedEOF {
+				t.Fatalf("io.ErrUnexpectedEOF must be returned (%v)", err)
+			}
+
+			limited := NewDecoder(bytes.NewReader(dat))
+			limited.Limits = &DecodeLimits{MaxSize: 100, MaxDecompressedSize: 100}
+			if _, err := limited.Decode(); err == nil {
+				t.Fatal("DecodeLimitError must be returned")
+			} else if _, ok := err.(*DecodeLimitError); !ok {
+				t.Fatalf("DecodeLimitError must be returned (%v)", err)
+			}
+
+			// the declared size is smaller than the actual one
+			dat[2]--
+			if _, err := NewDecoder(bytes.NewReader(dat)).Decode(); err == nil {
+				t.Fatal("must fail")
+			}
+		}
+
+		if err := NewEncoder(new(bytes.Buffer), 0x9999).Encode(txobjs[0]); err == nil {
+			t.Fatal("unknown formatType must be rejected")
+		}
+		dat := []byte{0x99, 0x99, 0x00, 0x00, 0x00, 0x10}
+		if _, err := NewDecoder(bytes.NewReader(dat)).Decode(); err == nil {
+			t.Fatal("unknown formatType must be rejected")
+		} else if e, ok := err.(*UnsupportedFormatError); !ok || e.FormatType != 0x9999 {
+			t.Fatalf("UnsupportedFormatError must be returned (%v)", err)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+#!/usr/bin/env python3
+"""
+Generator of the interoperability test vectors (testdata/vectors/*.json) with the Python bbc1 bbclib
+
+Usage:
+    pip install bbc1==1.2
+    python3 generate.py [output directory]
+
+Each vector file contains the serialized data and the packed data of a transaction made by bbc1.core.bbclib,
+together with the transaction_id and the asset_ids in the transaction. TestInteropVectors (interop_test.go) checks
+that the Go implementation reproduces the same IDs and the identical packed bytes.
+Add a new scenario function in SCENARIOS when the format evolves.
+"""
+import binascii
+import json
+import os
+import sys
+import zlib
+from importlib import metadata
+
+from bbc1.core import bbclib
+
+
+ASSET_GROUP_ID = bbclib.get_new_id("asset_group_id_for_vectors", include_timestamp=False)
+DOMAIN_ID = bbclib.get_new_id("domain_id_for_vectors", include_timestamp=False)
+USER1 = bbclib.get_new_id("user1_for_vectors", include_timestamp=False)
+USER2 = bbclib.get_new_id("user2_for_vectors", include_timestamp=False)
+
+
+def keypair():
+    kp = bbclib.KeyPair()
+    kp.generate()
+    return kp
+
+
+KEYPAIR1 = keypair()
+KEYPAIR2 = keypair()
+
+
+def base_transaction():
+    """The transaction referred by the scenarios (event[0] for USER1, event[1] for USER2)"""
+    txobj = bbclib.make_transaction(event_num=2, witness=True)
+    bbclib.add_event_asset(txobj, event_idx=0, asset_group_id=ASSET_GROUP_ID, user_id=USER1, asset_body=b"event_asset1")
+    txobj.events[0].add(mandatory_approver=USER1)
+    bbclib.add_event_asset(txobj, event_idx=1, asset_group_id=ASSET_GROUP_ID, user_id=USER2, asset_body=b"event_asset2")
+    txobj.events[1].add(mandatory_approver=USER2)
+    txobj.witness.add_witness(USER1)
+    txobj.add_signature(user_id=USER1, signature=txobj.sign(keypair=KEYPAIR1))
+    return txobj
+
+
+def event_reference_plain():
+    """BBcEvent with a mandatory approver, two BBcReference objects with signatures (FormatPlain)"""
+    ref_tx = base_transaction()
+    txobj = bbclib.make_transaction(event_num=1)
+    bbclib.add_event_asset(txobj, event_idx=0, asset_group_id=ASSET_GROUP_ID, user_id=USER1, asset_body=b"event_asset2")
+    txobj.events[0].add(mandatory_approver=USER1)
+    ref1 = bbclib.add_reference_to_transaction(txobj, ASSET_GROUP_ID, ref_tx, 0)
+    ref2 = bbclib.add_reference_to_transaction(txobj, ASSET_GROUP_ID, ref_tx, 1)
+    ref1.add_signature(user_id=USER1, signature=txobj.sign(keypair=KEYPAIR1))
+    ref2.add_signature(user_id=USER2, signature=txobj.sign(keypair=KEYPAIR2))
+    return txobj, bbclib.BBcFormat.FORMAT_PLAIN
+
+
+def relation_pointer_witness_zlib():
+    """two BBcRelation objects with BBcPointer (with and without asset_id), MessagePack asset body, BBcWitness (FormatZlib)"""
+    ref_tx = base_transaction()
+    txobj = bbclib.make_transaction(relation_num=2, witness=True)
+    bbclib.add_relation_asset(txobj, relation_idx=0, asset_group_id=ASSET_GROUP_ID, user_id=USER1, asset_body=b"relation_asset")
+    bbclib.add_relation_pointer(txobj, relation_idx=0, ref_transaction_id=ref_tx.transaction_id)
+    bbclib.add_relation_asset(txobj, relation_idx=1, asset_group_id=ASSET_GROUP_ID, user_id=USER2, asset_body={"abc": 200, "XYZ": 0})
+    bbclib.add_relation_pointer(txobj, relation_idx=1, ref_transaction_id=ref_tx.transaction_id,
+                                ref_asset_id=ref_tx.events[0].asset.asset_id)
+    txobj.witness.add_witness(USER1)
+    txobj.witness.add_witness(USER2)
+    txobj.add_signature(user_id=USER1, signature=txobj.sign(keypair=KEYPAIR1))
+    txobj.add_signature(user_id=USER2, signature=txobj.sign(keypair=KEYPAIR2))
+    return txobj, bbclib.BBcFormat.FORMAT_ZLIB
+
+
+def crossref_plain():
+    """BBcEvent, BBcWitness and BBcCrossRef (FormatPlain)"""
+    ref_tx = base_transaction()
+    txobj = bbclib.make_transaction(event_num=1, witness=True)
+    bbclib.add_event_asset(txobj, event_idx=0, asset_group_id=ASSET_GROUP_ID, user_id=USER1, asset_body=b"crossref_asset")
+    txobj.add(cross_ref=bbclib.BBcCrossRef(domain_id=DOMAIN_ID, transaction_id=ref_tx.transaction_id))
+    txobj.witness.add_witness(USER1)
+    txobj.add_signature(user_id=USER1, signature=txobj.sign(keypair=KEYPAIR1))
+    return txobj, bbclib.BBcFormat.FORMAT_PLAIN
+
+
+SCENARIOS = [event_reference_plain, relation_pointer_witness_zlib, crossref_plain]
+
+
+def hexlify(dat):
+    return binascii.b2a_hex(dat).decode()
+
+
+def asset_ids(txobj):
+    ids = [evt.asset.asset_id for evt in txobj.events if evt.asset is not None]
+    ids += [rtn.asset.asset_id for rtn in txobj.relations if rtn.asset is not None]
+    return [hexlify(i) for i in ids]
+
+
+def main():
+    outdir = sys.argv[1] if len(sys.argv) > 1 else os.path.dirname(os.path.abspath(__file__))
+    for scenario in SCENARIOS:
+        txobj, format_type = scenario()
+        serialized = bbclib.serialize(txobj, format_type=format_type)
+        packed = serialized[2:]
+        if format_type == bbclib.BBcFormat.FORMAT_ZLIB:
+            packed = zlib.decompress(packed)
+        vector = {
+            "name": scenario.__name__,
+            "description": scenario.__doc__,
+            "source": "bbc1 %s (bbc1.core.bbclib)" % metadata.version("bbc1"),
+            "format_type": format_type,
+            "transaction_id": hexlify(txobj.transaction_id),
+            "asset_ids": asset_ids(txobj),
+            "serialized": hexlify(serialized),
+            "packed": hexlify(packed),
+        }
+        with open(os.path.join(outdir, scenario.__name__ + ".json"), "w") as f:
+            f.write(json.dumps(vector, indent=2) + "\n")
+        print("generated", scenario.__name__)
+
+
+if __name__ == "__main__":
+    main()
@@ -22,7 +22,6 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"reflect"
 	"time"
 )
 
@@ -33,6 +32,7 @@ BBcTransaction is just a container of various objects.
 
 Events, References, Relations and Signatures are list of BBcEvent, BBcReference, BBcRelation and BBcSignature objects, respectively.
 "digestCalculating", "TransactionBaseDigest", "TransactionData" and "SigIndices" are not included in the packed data. They are internal use only.
+The layout of the header depends on Version, and Extensions is available only in version 2 or later (see version.go).
 
 Calculating TransactionID
 
@@ -60,6 +60,7 @@ type (
 		Version               uint32
 		Timestamp             int64
 		IDLength              int
+		Extensions            []BBcExtension
 		Events                []*BBcEvent
 		References            []*BBcReference
 		Relations             []*BBcRelation
@@ -79,6 +80,14 @@ func (p *BBcTransaction) Stringer() string {
 	if p.Version != 0 {
 		ret += fmt.Sprintf("id_length: %d\n", p.IDLength)
 	}
+	if p.Version >= TransactionVersion2 {
+		ret += fmt.Sprintf("Extensions[]: %d\n", len(p.Extensions))
+		for i, ext := range p.Extensions {
+			ret += fmt.Sprintf("[%d]\n", i)
+			ret += fmt.Sprintf("  type: %d\n", ext.Type)
+			ret += fmt.Sprintf("  data: %x\n", ext.Data)
+		}
+	}
 
 	ret += fmt.Sprintf("Event[]: %d\n", len(p.Events))
 	for i := range p.Events {
@@ -150,40 +159,61 @@ func (p *BBcTransaction) AddCrossRef(obj *BBcCrossRef) {
 	p.Crossref = obj
 }
 
+// sigUserID returns the userID in the length of IDLength for SigIndices
+func (p *BBcTransaction) sigUserID(userID []byte) []byte {
+	length := len(userID)
+	if p.IDLength > 0 && p.IDLength < length {
+		length = p.IDLength
+	}
+	uid := make([]byte, length)
+	copy(uid, userID)
+	return uid
+}
+
 // AddSignature adds the BBcSignature object for the specified userID in the transaction object
+//
+// The signature is placed at the position reserved by GetSigIndex (or appended if not reserved), and replaces the existing one of the userID.
+// The userID is compared in the length of IDLength. (Before the positions were reserved, signatures were always appended in the order of signing,
+// which did not match the sig_index in BBcWitness and BBcReference if the users signed in a different order.)
 func (p *BBcTransaction) AddSignature(userID *[]byte, sig *BBcSignature) {
+	uid := p.sigUserID(*userID)
 	for i := range p.SigIndices {
-		if reflect.DeepEqual(p.SigIndices[i], userID) {
+		if bytes.Equal(p.SigIndices[i], uid) {
 			p.Signatures[i] = sig
 			return
 		}
 	}
-	uid := make([]byte, int(p.IDLength))
-	copy(uid, *userID)
 	p.SigIndices = append(p.SigIndices, uid)
 	p.Signatures = append(p.Signatures, sig)
 }
 
 // GetSigIndex reserves and returns the position (index) of the corespondent userID in the signature list
+//
+// A not-initialized BBcSignature object is placed at the reserved position until AddSignature is called for the userID,
+// so a reserved but unsigned position is packed as a 4-byte zero key type, in the same manner as get_sig_index of the Python bbclib.
 func (p *BBcTransaction) GetSigIndex(userID []byte) int {
-	var i = -1
-	for i = range p.SigIndices {
-		if reflect.DeepEqual(p.SigIndices[i], userID) {
+	uid := p.sigUserID(userID)
+	for i := range p.SigIndices {
+		if bytes.Equal(p.SigIndices[i], uid) {
 			return i
 		}
 	}
-	p.SigIndices = append(p.SigIndices, userID)
-	return i + 1
+	p.SigIndices = append(p.SigIndices, uid)
+	p.Signatures = append(p.Signatures, &BBcSignature{KeyType: KeyTypeNotInitialized})
+	return len(p.SigIndices) - 1
 }
 
-// Sign TransactionID using private key in the given keypair
-func (p *BBcTransaction) Sign(keypair *KeyPair) ([]byte, error) {
+// Sign TransactionID using the given signer (e.g., KeyPair object)
+func (p *BBcTransaction) Sign(signer Signer) ([]byte, error) {
+	if signer == nil {
+		return nil, errors.New("signer must be set")
+	}
 	if p.TransactionID == nil {
 		p.Digest()
 	}
-	signature := keypair.Sign(p.TransactionID)
-	if signature == nil {
-		return nil, errors.New("fail to sign")
+	signature, err := signer.Sign(p.TransactionID)
+	if err != nil {
+		return nil, fmt.Errorf("fail to sign (%v)", err)
 	}
 	return signature, nil
 }
@@ -254,12 +284,15 @@ func (p *BBcTransaction) packCrossRef(buf *bytes.Buffer) error {
 
 // packBase packs the base part of BBcTransaction object in binary data (from version to witness)
 func (p *BBcTransaction) packBase(buf *bytes.Buffer) error {
-	Put4byte(buf, p.Version)
 	if p.Timestamp == 0 {
 		p.Timestamp = time.Now().UnixNano() / int64(time.Microsecond)
 	}
-	Put8byte(buf, p.Timestamp)
-	Put2byte(buf, uint16(p.IDLength))
+	if err := p.packHeader(buf); err != nil {
+		return err
+	}
+	if err := p.setAssetForm(); err != nil {
+		return err
+	}
 
 	Put2byte(buf, uint16(len(p.Events)))
 	for _, obj := range p.Events {
@@ -323,10 +356,6 @@ func (p *BBcTransaction) Pack() ([]byte, error) {
 		p.Digest()
 	}
 
-	if p.Version == 0 {
-		return nil, errors.New("not support version=0 transaction")
-	}
-
 	buf := new(bytes.Buffer)
 	err := p.packBase(buf)
 	if err != nil {
@@ -353,33 +382,15 @@ func (p *BBcTransaction) Pack() ([]byte, error) {
 	return p.TransactionData, nil
 }
 
-// unpackHeader unpacks the header part of the binary data
-func (p *BBcTransaction) unpackHeader(buf *bytes.Buffer) error {
-	var err error
-	p.Version, err = Get4byte(buf)
-	if err != nil {
-		return err
-	}
-
-	p.Timestamp, err = Get8byte(buf)
-	if err != nil {
-		return err
-	}
-
-	idLen, err := Get2byte(buf)
-	if err != nil {
-		return err
-	}
-	p.IDLength = int(idLen)
-	return nil
-}
-
 // unpackEvent unpacks the events part of the binary data
-func (p *BBcTransaction) unpackEvent(buf *bytes.Buffer) error {
+func (p *BBcTransaction) unpackEvent(buf *bytes.Buffer, limits *DecodeLimits) error {
 	num, err := Get2byte(buf)
 	if err != nil {
 		return err
 	}
+	if err = limits.checkCount("Events", int(num)); err != nil {
+		return err
+	}
 	for i := 0; i < int(num); i++ {
 		size, err2 := Get4byte(buf)
 		if err2 != nil {
@@ -389,19 +400,24 @@ func (p *BBcTransaction) unpackEvent(buf *bytes.Buffer) error {
 		if err2 != nil {
 			return err2
 		}
-		obj := BBcEvent{IDLength: p.IDLength}
-		obj.Unpack(&data)
+		obj := BBcEvent{IDLength: p.IDLength, assetForm: p.assetForm()}
+		if err2 = obj.unpack(&data, limits); err2 != nil {
+			return limitErrorAt(fmt.Sprintf("Events[%d]", i), err2)
+		}
 		p.Events = append(p.Events, &obj)
 	}
 	return nil
 }
 
 // unpackReference unpacks the references part of the binary data
-func (p *BBcTransaction) unpackReference(buf *bytes.Buffer) error {
+func (p *BBcTransaction) unpackReference(buf *bytes.Buffer, limits *DecodeLimits) error {
 	num, err := Get2byte(buf)
 	if err != nil {
 		return err
 	}
+	if err = limits.checkCount("References", int(num)); err != nil {
+		return err
+	}
 	for i := 0; i < int(num); i++ {
 		size, err2 := Get4byte(buf)
 		if err2 != nil {
@@ -412,33 +428,43 @@ func (p *BBcTransaction) unpackReference(buf *bytes.Buffer) error {
 			return err2
 		}
 		obj := BBcReference{IDLength: p.IDLength}
-		obj.Unpack(&data)
+		if err2 = obj.unpack(&data, limits); err2 != nil {
+			return limitErrorAt(fmt.Sprintf("References[%d]", i), err2)
+		}
 		p.References = append(p.References, &obj)
 	}
 	return nil
 }
 
 // unpackRelation unpacks the relations part of the binary data
-func (p *BBcTransaction) unpackRelation(buf *bytes.Buffer) error {
+func (p *BBcTransaction) unpackRelation(buf *bytes.Buffer, limits *DecodeLimits) error {
 	num, err := Get2byte(buf)
 	if err != nil {
 		return err
 	}
+	if err = limits.checkCount("Relations", int(num)); err != nil {
+		return err
+	}
 	for i := 0; i < int(num); i++ {
 		size, err2 := Get4byte(buf)
 		if err2 != nil {
 			return err2
 		}
-		data, _ := GetBytes(buf, int(size))
-		obj := BBcRelation{IDLength: p.IDLength}
-		obj.Unpack(&data)
+		data, err2 := GetBytes(buf, int(size))
+		if err2 != nil {
+			return err2
+		}
+		obj := BBcRelation{IDLength: p.IDLength, assetForm: p.assetForm()}
+		if err2 = obj.unpack(&data, limits); err2 != nil {
+			return limitErrorAt(fmt.Sprintf("Relations[%d]", i), err2)
+		}
 		p.Relations = append(p.Relations, &obj)
 	}
 	return nil
 }
 
 // unpackWitness unpacks the witness part of the binary data
-func (p *BBcTransaction) unpackWitness(buf *bytes.Buffer) error {
+func (p *BBcTransaction) unpackWitness(buf *bytes.Buffer, limits *DecodeLimits) error {
 	num, err := Get2byte(buf)
 	if err != nil {
 		return err
@@ -448,9 +474,14 @@ func (p *BBcTransaction) unpackWitness(buf *bytes.Buffer) error {
 		if err2 != nil {
 			return err2
 		}
-		data, _ := GetBytes(buf, int(size))
+		data, err2 := GetBytes(buf, int(size))
+		if err2 != nil {
+			return err2
+		}
 		p.Witness = &BBcWitness{IDLength: p.IDLength}
-		p.Witness.Unpack(&data)
+		if err2 = p.Witness.unpack(&data, limits); err2 != nil {
+			return limitErrorAt("Witness", err2)
+		}
 	}
 	return nil
 }
@@ -471,17 +502,22 @@ func (p *BBcTransaction) unpackCrossRef(buf *bytes.Buffer) error {
 			return err2
 		}
 		p.Crossref = &BBcCrossRef{IDLength: p.IDLength}
-		p.Crossref.Unpack(&dat)
+		if err2 = p.Crossref.Unpack(&dat); err2 != nil {
+			return err2
+		}
 	}
 	return nil
 }
 
 // unpackSignature unpacks the signatures part of the binary data
-func (p *BBcTransaction) unpackSignature(buf *bytes.Buffer) error {
+func (p *BBcTransaction) unpackSignature(buf *bytes.Buffer, limits *DecodeLimits) error {
 	num, err := Get2byte(buf)
 	if err != nil {
 		return err
 	}
+	if err = limits.checkCount("Signatures", int(num)); err != nil {
+		return err
+	}
 	for i := 0; i < int(num); i++ {
 		size, err2 := Get4byte(buf)
 		if err2 != nil {
@@ -492,7 +528,9 @@ func (p *BBcTransaction) unpackSignature(buf *bytes.Buffer) error {
 			return err2
 		}
 		obj := BBcSignature{}
-		obj.Unpack(&data)
+		if err2 = obj.Unpack(&data); err2 != nil {
+			return err2
+		}
 		p.Signatures = append(p.Signatures, &obj)
 	}
 	return nil
@@ -500,25 +538,41 @@ func (p *BBcTransaction) unpackSignature(buf *bytes.Buffer) error {
 
 // Unpack binary data to BBcTransaction object
 func (p *BBcTransaction) Unpack(dat *[]byte) error {
+	return p.unpack(dat, nil)
+}
+
+// UnpackWithLimits unpacks binary data to BBcTransaction object within the limits (DefaultDecodeLimits is used if limits is nil)
+func (p *BBcTransaction) UnpackWithLimits(dat *[]byte, limits *DecodeLimits) error {
+	if limits == nil {
+		limits = &DefaultDecodeLimits
+	}
+	if err := checkLimit("transaction size", len(*dat), limits.MaxSize); err != nil {
+		return err
+	}
+	return p.unpack(dat, limits)
+}
+
+// unpack binary data to BBcTransaction object (the size of data is not checked here, and no limit is applied if limits is nil)
+func (p *BBcTransaction) unpack(dat *[]byte, limits *DecodeLimits) error {
 	buf := bytes.NewBuffer(*dat)
 
-	if err := p.unpackHeader(buf); err != nil {
+	if err := p.unpackHeader(buf, limits); err != nil {
 		return err
 	}
 
-	if err := p.unpackEvent(buf); err != nil {
+	if err := p.unpackEvent(buf, limits); err != nil {
 		return err
 	}
 
-	if err := p.unpackReference(buf); err != nil {
+	if err := p.unpackReference(buf, limits); err != nil {
 		return err
 	}
 
-	if err := p.unpackRelation(buf); err != nil {
+	if err := p.unpackRelation(buf, limits); err != nil {
 		return err
 	}
 
-	if err := p.unpackWitness(buf); err != nil {
+	if err := p.unpackWitness(buf, limits); err != nil {
 		return err
 	}
 
@@ -526,7 +580,7 @@ func (p *BBcTransaction) Unpack(dat *[]byte) error {
 		return err
 	}
 
-	if err := p.unpackSignature(buf); err != nil {
+	if err := p.unpackSignature(buf, limits); err != nil {
 		return err
 	}
 
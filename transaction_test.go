@@ -18,6 +18,9 @@ package bbclib
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
 	"testing"
 	"time"
 )
@@ -249,3 +252,149 @@ func TestTransactionPackUnpackSimpleWithEvent(t *testing.T) {
 		}
 	})
 }
+
+func TestTransactionVerifyAllMixedKeyTypes(t *testing.T) {
+	txobj := MakeTransaction(1, 0, true, defaultIDLength)
+	assetGroupID := GetIdentifier("asset_group_id1,,,,,,,", defaultIDLength)
+	u1 := GetIdentifierWithTimestamp("user1", defaultIDLength)
+	AddEventAssetBodyString(txobj, 0, &assetGroupID, &u1, "teststring!!!!!")
+
+	var users [][]byte
+	var keypairs []KeyPair
+	for keyType := KeyTypeEcdsaSECP256k1; keyType <= KeyTypeEd25519; keyType++ {
+		u := GetIdentifierWithTimestamp(fmt.Sprintf("user%d", keyType), defaultIDLength)
+		users = append(users, u)
+		keypairs = append(keypairs, GenerateKeypair(keyType, defaultCompressionMode))
+		txobj.Witness.AddWitness(&u)
+	}
+	for i := range users {
+		if err := SignToTransaction(txobj, &users[i], &keypairs[i]); err != nil {
+			t.Fatalf("failed to sign (%v)", err)
+		}
+	}
+
+	dat, err := Serialize(txobj, FormatZlib)
+	if err != nil {
+		t.Fatalf("failed to serialize transaction object (%v)", err)
+	}
+	obj2, err := Deserialize(dat)
+	if err != nil {
+		t.Fatalf("failed to deserialize transaction data (%v)", err)
+	}
+	t.Logf("%v", obj2.Stringer())
+
+	if result, idx := obj2.VerifyAll(); !result {
+		t.Fatalf("Verification failed..signature[%d]", idx)
+	}
+	if obj2.Signatures[2].KeyType != KeyTypeEd25519 || len(obj2.Signatures[2].Pubkey) != 32 {
+		t.Fatal("Not recovered correctly...")
+	}
+
+	obj2.Signatures[2].Signature[0] ^= 0xff
+	if result, idx := obj2.VerifyAll(); result || idx != 2 {
+		t.Fatal("Verify returns true but not correct...")
+	}
+	t.Log("Verify failed as expected")
+}
+
+func TestTransactionSigIndex(t *testing.T) {
+	txobj := MakeTransaction(0, 0, true, 32)
+	u1 := GetIdentifier("user1_789abcdef0123456789abcdef0", defaultIDLength)
+	u2 := GetIdentifier("user2_789abcdef0123456789abcdef0", defaultIDLength)
+	keypair1 := GenerateKeypair(KeyTypeEcdsaP256v1, defaultCompressionMode)
+	keypair2 := GenerateKeypair(KeyTypeEcdsaSECP256k1, defaultCompressionMode)
+
+	txobj.Witness.AddWitness(&u1)
+	txobj.Witness.AddWitness(&u2)
+	if len(txobj.Signatures) != 2 || txobj.Signatures[1].KeyType != KeyTypeNotInitialized {
+		t.Fatal("signature positions must be reserved")
+	}
+
+	SignToTransaction(txobj, &u2, &keypair2)
+	SignToTransaction(txobj, &u1, &keypair1)
+	if len(txobj.Signatures) != 2 || txobj.GetSigIndex(u2) != 1 ||
+		!bytes.Equal(txobj.Signatures[0].Pubkey, keypair1.Pubkey) || !bytes.Equal(txobj.Signatures[1].Pubkey, keypair2.Pubkey) {
+		t.Fatal("signatures must be placed at the reserved positions")
+	}
+	u3 := GetIdentifier("user3_789abcdef0123456789abcdef0", defaultIDLength)
+	if txobj.GetSigIndex(u3) != 2 {
+		t.Fatal("the position reserved after signing must follow the reserved positions")
+	}
+	if result, idx := txobj.VerifyAll(); !result {
+		t.Fatalf("Verification failed..signature[%d]", idx)
+	}
+}
+
+// packSigIndexTestTransaction makes a transaction with deterministic Ed25519 signatures:
+// user1 and user2 are witnesses, user2 signs first, user1 signs twice, and user3 reserves a position without signing
+func packSigIndexTestTransaction(t *testing.T) ([]byte, []KeyPair) {
+	var keypairs []KeyPair
+	var users [][]byte
+	for i := 1; i <= 3; i++ {
+		seed := GetIdentifier(fmt.Sprintf("sig_index_seed%d", i), ed25519.SeedSize)
+		keypairs = append(keypairs, KeyPair{
+			CurveType: KeyTypeEd25519,
+			Privkey:   seed,
+			Pubkey:    ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey),
+		})
+		users = append(users, GetIdentifier(fmt.Sprintf("sig_index_user%d", i), defaultIDLength))
+	}
+
+	txobj := MakeTransaction(0, 0, true, defaultIDLength)
+	txobj.Timestamp = 1500000000000000000
+	txobj.Witness.AddWitness(&users[0])
+	txobj.Witness.AddWitness(&users[1])
+	txobj.GetSigIndex(users[2])
+	for _, i := range []int{1, 0, 0} {
+		if err := SignToTransaction(txobj, &users[i], &keypairs[i]); err != nil {
+			t.Fatalf("failed to sign (%v)", err)
+		}
+	}
+	dat, err := txobj.Pack()
+	if err != nil {
+		t.Fatalf("failed to pack (%v)", err)
+	}
+	return dat, keypairs
+}
+
+// Packed data of packSigIndexTestTransaction before and after GetSigIndex reserves the positions in Signatures.
+//
+// Before: AddSignature never found the reserved position (it compared []byte with *[]byte), so the signatures were appended
+// in the order of signing (user2, user1, user1 again) and the sig_index of user1 in BBcWitness pointed to the signature of user2.
+// After: the signatures are placed at the reserved positions (user1, user2), signing again replaces the signature,
+// and the position of user3, who has not signed, holds a not-initialized BBcSignature (4-byte zero key type) as in the Python bbclib.
+const (
+	sigIndexPackedBefore = "010000000000167b0d12d114200000000000000001004a00000002002000afd867fafd2949cc410f30a63fc649f695c69d207ab9db316c9da4d5a42734ff00002000e2353c4b1b993299d1255c8f8bff07a342648439c5e3ddfa37b43874b15c41230100000003006c00000003000000000100007630ad4deb453655b0b4d2d1304b14b11205ec89e78e385da4975aaf78e403c500020000c68f032f630a85c416b094477332d19bbd6120e69a5a43bf5975f878e5b32efdeb029907d42fe4837dd120d19a2feef711d8f5e7f90cf7e5e0d841403993a9086c0000000300000000010000cc0f80ece4e78ed5e29c20f8f94c1ae2220264e7765b221a8bdbf6a389f840a40002000068bc1402800ace725199e08ee1fa135d8b3c1e17ff9732297c6e425caa1cb012d89cd81d020f67ffdfd745cac20e72ff8e032ff45451595e3afb3508639e050a6c0000000300000000010000cc0f80ece4e78ed5e29c20f8f94c1ae2220264e7765b221a8bdbf6a389f840a40002000068bc1402800ace725199e08ee1fa135d8b3c1e17ff9732297c6e425caa1cb012d89cd81d020f67ffdfd745cac20e72ff8e032ff45451595e3afb3508639e050a"
+	sigIndexPackedAfter  = "010000000000167b0d12d114200000000000000001004a00000002002000afd867fafd2949cc410f30a63fc649f695c69d207ab9db316c9da4d5a42734ff00002000e2353c4b1b993299d1255c8f8bff07a342648439c5e3ddfa37b43874b15c41230100000003006c0000000300000000010000cc0f80ece4e78ed5e29c20f8f94c1ae2220264e7765b221a8bdbf6a389f840a40002000068bc1402800ace725199e08ee1fa135d8b3c1e17ff9732297c6e425caa1cb012d89cd81d020f67ffdfd745cac20e72ff8e032ff45451595e3afb3508639e050a6c00000003000000000100007630ad4deb453655b0b4d2d1304b14b11205ec89e78e385da4975aaf78e403c500020000c68f032f630a85c416b094477332d19bbd6120e69a5a43bf5975f878e5b32efdeb029907d42fe4837dd120d19a2feef711d8f5e7f90cf7e5e0d841403993a9080400000000000000"
+)
+
+func TestTransactionSigIndexPacked(t *testing.T) {
+	dat, keypairs := packSigIndexTestTransaction(t)
+	if hex.EncodeToString(dat) != sigIndexPackedAfter {
+		t.Fatalf("packed data differs: %x", dat)
+	}
+
+	before, _ := hex.DecodeString(sigIndexPackedBefore)
+	after, _ := hex.DecodeString(sigIndexPackedAfter)
+	objBefore := BBcTransaction{}
+	objAfter := BBcTransaction{}
+	if objBefore.Unpack(&before) != nil || objAfter.Unpack(&after) != nil {
+		t.Fatal("failed to unpack")
+	}
+	if !bytes.Equal(objBefore.TransactionID, objAfter.TransactionID) {
+		t.Fatal("TransactionID must not be changed")
+	}
+	if len(objBefore.Signatures) != 3 || !bytes.Equal(objBefore.Signatures[0].Pubkey, keypairs[1].Pubkey) {
+		t.Fatal("unexpected data before the change")
+	}
+	if len(objAfter.Signatures) != 3 || !bytes.Equal(objAfter.Signatures[0].Pubkey, keypairs[0].Pubkey) ||
+		!bytes.Equal(objAfter.Signatures[1].Pubkey, keypairs[1].Pubkey) {
+		t.Fatal("signatures must be placed at the reserved positions")
+	}
+	if objAfter.Signatures[2].KeyType != KeyTypeNotInitialized {
+		t.Fatal("position of user3 must hold a not-initialized signature")
+	}
+	if result, idx := objAfter.VerifyAll(); !result {
+		t.Fatalf("Verification failed..signature[%d]", idx)
+	}
+}
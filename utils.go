@@ -22,6 +22,7 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"time"
 )
 
@@ -37,6 +38,12 @@ func GetIdentifierWithTimestamp(seed string, length int) []byte {
 	return digest[:length]
 }
 
+// GetIdentifierFromPubkey returns a byte data with specified length derived from the public key (e.g., userID of the key owner)
+func GetIdentifierFromPubkey(pubkey []byte, length int) []byte {
+	digest := sha256.Sum256(pubkey)
+	return digest[:length]
+}
+
 // GetRandomValue returns a random byte data with specified length
 func GetRandomValue(length int) []byte {
 	val := make([]byte, length)
@@ -115,7 +122,11 @@ func GetBigInt(buf *bytes.Buffer) ([]byte, error) {
 }
 
 // GetBytes returns binary data with specified length from the buffer
+// (io.ErrUnexpectedEOF is returned without allocating memory if the buffer has less data than length)
 func GetBytes(buf *bytes.Buffer, length int) ([]byte, error) {
+	if length < 0 || length > buf.Len() {
+		return nil, io.ErrUnexpectedEOF
+	}
 	val := make([]byte, length)
 	if err := binary.Read(buf, binary.LittleEndian, val); err != nil {
 		return nil, err
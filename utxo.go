@@ -0,0 +1,320 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+/*
+UTXOSet definition
+
+A BBcEvent object is an output of UTXO (Unspent Transaction Output) and a BBcReference object is an input which consumes an output.
+UTXOSet tracks the outputs over a stream of transactions given to Apply. An output is identified by (TransactionID, index of the event, AssetGroupID).
+
+Apply checks that every BBcReference of the transaction points to a known output which has not been spent, and then
+marks the outputs as spent by the transaction and adds copies of the BBcEvent objects of the transaction as new outputs.
+TransactionID of the transaction must be calculated before Check and Apply, which never modify the transaction.
+If a check fails, UTXOError is returned and the UTXOSet is not changed. Check does the same checks without changing the UTXOSet.
+
+Snapshot, Rollback and Commit allow applications to evaluate candidate transactions without committing them:
+
+	snapshot := utxos.Snapshot()
+	err := utxos.Apply(candidate1)
+	...
+	utxos.Rollback(snapshot)  // all changes after Snapshot() are discarded
+	(or)
+	utxos.Commit(snapshot)    // all changes after Snapshot() are kept
+
+Every snapshot must be released by Rollback or Commit. The changes are recorded in the journal only while a snapshot is held,
+and the journal is truncated to the oldest snapshot held when a snapshot is released.
+Rollback also releases the snapshots taken after the snapshot, because the changes they point to have been discarded.
+
+Spent outputs are kept in the UTXOSet in order to distinguish them from unknown outputs.
+UTXOSet is safe for concurrent use.
+*/
+type (
+	UTXOSet struct {
+		lock    sync.RWMutex
+		outputs map[string]*UTXO
+		applied map[string]bool
+		journal []utxoChange
+
+		// journalBase is the position of journal[0] (the number of changes dropped from the journal)
+		journalBase int
+		snapshots   map[UTXOSnapshot]int
+	}
+
+	// UTXO is an output in UTXOSet (SpentBy is the TransactionID of the transaction which consumes it, or nil if unspent)
+	UTXO struct {
+		TransactionID []byte
+		EventIndex    int
+		AssetGroupID  []byte
+		Event         *BBcEvent
+		SpentBy       []byte
+	}
+
+	// UTXOError describes the BBcReference object which cannot consume the output
+	UTXOError struct {
+		Path          string
+		TransactionID []byte
+		EventIndex    int
+		AssetGroupID  []byte
+		Reason        int
+	}
+
+	// UTXOSnapshot is a point to which UTXOSet can be rolled back (the position in the journal)
+	UTXOSnapshot int
+
+	// utxoChange is an entry of the journal for Rollback
+	utxoChange struct {
+		kind int
+		key  string
+	}
+)
+
+// Reasons of UTXOError
+const (
+	UTXOUnknown = iota
+	UTXOAlreadySpent
+	UTXOSpentTwiceInTransaction
+)
+
+var utxoReasons = map[int]string{
+	UTXOUnknown:                 "unknown output",
+	UTXOAlreadySpent:            "output already spent",
+	UTXOSpentTwiceInTransaction: "output spent twice in the transaction",
+}
+
+// kinds of utxoChange
+const (
+	utxoAdded = iota
+	utxoSpent
+	utxoApplied
+)
+
+func (e *UTXOError) Error() string {
+	return fmt.Sprintf("%s: %s (transaction_id=%x, event_index=%d, asset_group_id=%x)", e.Path, utxoReasons[e.Reason], e.TransactionID, e.EventIndex, e.AssetGroupID)
+}
+
+// utxoKey returns the key of the output in UTXOSet
+func utxoKey(transactionID []byte, eventIndex int, assetGroupID []byte) string {
+	return fmt.Sprintf("%x:%05d:%x", transactionID, eventIndex, assetGroupID)
+}
+
+// NewUTXOSet returns an empty UTXOSet object
+func NewUTXOSet() *UTXOSet {
+	return &UTXOSet{outputs: make(map[string]*UTXO), applied: make(map[string]bool), snapshots: make(map[UTXOSnapshot]int)}
+}
+
+// record appends the change to the journal if a snapshot is held (the lock must be held by the caller)
+func (s *UTXOSet) record(kind int, key string) {
+	if len(s.snapshots) > 0 {
+		s.journal = append(s.journal, utxoChange{kind: kind, key: key})
+	}
+}
+
+// check returns UTXOError if the transaction cannot be applied (the lock must be held by the caller)
+func (s *UTXOSet) check(transaction *BBcTransaction) error {
+	if transaction == nil {
+		return errors.New("transaction is nil")
+	}
+	if transaction.TransactionID == nil {
+		return errors.New("TransactionID is not calculated (call Digest or Build first)")
+	}
+	if s.applied[string(transaction.TransactionID)] {
+		return fmt.Errorf("transaction %x is already applied", transaction.TransactionID)
+	}
+	consumed := make(map[string]bool)
+	for i, ref := range transaction.References {
+		if ref == nil {
+			continue
+		}
+		key := utxoKey(ref.TransactionID, int(ref.EventIndexInRef), ref.AssetGroupID)
+		utxoErr := UTXOError{
+			Path:          fmt.Sprintf("References[%d]", i),
+			TransactionID: ref.TransactionID,
+			EventIndex:    int(ref.EventIndexInRef),
+			AssetGroupID:  ref.AssetGroupID,
+		}
+		output, ok := s.outputs[key]
+		switch {
+		case !ok:
+			utxoErr.Reason = UTXOUnknown
+		case output.SpentBy != nil:
+			utxoErr.Reason = UTXOAlreadySpent
+		case consumed[key]:
+			utxoErr.Reason = UTXOSpentTwiceInTransaction
+		default:
+			consumed[key] = true
+			continue
+		}
+		return &utxoErr
+	}
+	return nil
+}
+
+// Check returns an error if the transaction cannot be applied, without changing the UTXOSet
+func (s *UTXOSet) Check(transaction *BBcTransaction) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.check(transaction)
+}
+
+// Apply consumes the outputs referred by the transaction and adds the events of the transaction as new outputs
+func (s *UTXOSet) Apply(transaction *BBcTransaction) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.check(transaction); err != nil {
+		return err
+	}
+
+	txid := append([]byte{}, transaction.TransactionID...)
+	for _, ref := range transaction.References {
+		if ref == nil {
+			continue
+		}
+		key := utxoKey(ref.TransactionID, int(ref.EventIndexInRef), ref.AssetGroupID)
+		s.outputs[key].SpentBy = txid
+		s.record(utxoSpent, key)
+	}
+	for i, evt := range transaction.Events {
+		if evt == nil {
+			continue
+		}
+		key := utxoKey(txid, i, evt.AssetGroupID)
+		s.outputs[key] = &UTXO{TransactionID: txid, EventIndex: i, AssetGroupID: evt.AssetGroupID, Event: evt.Clone()}
+		s.record(utxoAdded, key)
+	}
+	s.applied[string(txid)] = true
+	s.record(utxoApplied, string(txid))
+	return nil
+}
+
+// Snapshot returns the current point of the UTXOSet for Rollback (it must be released by Rollback or Commit)
+func (s *UTXOSet) Snapshot() UTXOSnapshot {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	snapshot := UTXOSnapshot(s.journalBase + len(s.journal))
+	s.snapshots[snapshot]++
+	return snapshot
+}
+
+// checkSnapshot returns an error if the snapshot is not held (the lock must be held by the caller)
+func (s *UTXOSet) checkSnapshot(snapshot UTXOSnapshot) error {
+	if s.snapshots[snapshot] == 0 {
+		return fmt.Errorf("invalid snapshot %d (already rolled back or committed?)", snapshot)
+	}
+	return nil
+}
+
+// release releases the snapshot and truncates the journal to the oldest snapshot held (the lock must be held by the caller)
+func (s *UTXOSet) release(snapshot UTXOSnapshot) {
+	if s.snapshots[snapshot]--; s.snapshots[snapshot] == 0 {
+		delete(s.snapshots, snapshot)
+	}
+	oldest := s.journalBase + len(s.journal)
+	for held := range s.snapshots {
+		if int(held) < oldest {
+			oldest = int(held)
+		}
+	}
+	if oldest > s.journalBase {
+		s.journal = append([]utxoChange(nil), s.journal[oldest-s.journalBase:]...)
+		s.journalBase = oldest
+	}
+}
+
+// Rollback discards all changes after the snapshot, and releases the snapshot and the snapshots taken after it
+func (s *UTXOSet) Rollback(snapshot UTXOSnapshot) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.checkSnapshot(snapshot); err != nil {
+		return err
+	}
+	pos := int(snapshot) - s.journalBase
+	for i := len(s.journal) - 1; i >= pos; i-- {
+		change := s.journal[i]
+		switch change.kind {
+		case utxoAdded:
+			delete(s.outputs, change.key)
+		case utxoSpent:
+			s.outputs[change.key].SpentBy = nil
+		case utxoApplied:
+			delete(s.applied, change.key)
+		}
+	}
+	s.journal = s.journal[:pos]
+	for held := range s.snapshots {
+		if held > snapshot {
+			delete(s.snapshots, held)
+		}
+	}
+	s.release(snapshot)
+	return nil
+}
+
+// Commit keeps all changes after the snapshot and releases the snapshot
+func (s *UTXOSet) Commit(snapshot UTXOSnapshot) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.checkSnapshot(snapshot); err != nil {
+		return err
+	}
+	s.release(snapshot)
+	return nil
+}
+
+// Get returns a copy of the output (nil if unknown)
+func (s *UTXOSet) Get(transactionID []byte, eventIndex int, assetGroupID []byte) *UTXO {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	output, ok := s.outputs[utxoKey(transactionID, eventIndex, assetGroupID)]
+	if !ok {
+		return nil
+	}
+	ret := *output
+	return &ret
+}
+
+// IsUnspent returns true if the output is known and not spent
+func (s *UTXOSet) IsUnspent(transactionID []byte, eventIndex int, assetGroupID []byte) bool {
+	output := s.Get(transactionID, eventIndex, assetGroupID)
+	return output != nil && output.SpentBy == nil
+}
+
+// Unspent returns copies of the unspent outputs in the asset group (all asset groups if assetGroupID is nil), sorted by TransactionID and EventIndex
+func (s *UTXOSet) Unspent(assetGroupID []byte) []*UTXO {
+	s.lock.RLock()
+	keys := make([]string, 0, len(s.outputs))
+	for key, output := range s.outputs {
+		if output.SpentBy == nil && (assetGroupID == nil || string(output.AssetGroupID) == string(assetGroupID)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	ret := make([]*UTXO, len(keys))
+	for i, key := range keys {
+		output := *s.outputs[key]
+		ret[i] = &output
+	}
+	s.lock.RUnlock()
+	return ret
+}
@@ -0,0 +1,219 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"testing"
+)
+
+// makeUTXOTestTransaction makes a transaction with the events in the asset group, which consumes event[0] of each transaction in refs
+func makeUTXOTestTransaction(t *testing.T, assetGroupID []byte, events int, refs ...*BBcTransaction) *BBcTransaction {
+	return makeTestTransaction(t, func(b *TransactionBuilder) {
+		for i := 0; i < events; i++ {
+			b.Event(assetGroupID).Asset(testUsers[0]).BodyString("utxo").Approver(testUsers[0])
+		}
+		for _, ref := range refs {
+			b.Reference(assetGroupID, ref, 0)
+		}
+	})
+}
+
+func TestUTXOSet(t *testing.T) {
+	ag := GetIdentifier("asset_group_id_utxo", defaultIDLength)
+	utxos := NewUTXOSet()
+
+	genesis := makeUTXOTestTransaction(t, ag, 2)
+	if err := utxos.Apply(genesis); err != nil {
+		t.Fatalf("failed to apply (%v)", err)
+	}
+	if len(utxos.Unspent(ag)) != 2 || !utxos.IsUnspent(genesis.TransactionID, 1, ag) {
+		t.Fatal("outputs of genesis are not added")
+	}
+
+	spend := makeUTXOTestTransaction(t, ag, 1, genesis)
+	if err := utxos.Apply(spend); err != nil {
+		t.Fatalf("failed to apply (%v)", err)
+	}
+	output := utxos.Get(genesis.TransactionID, 0, ag)
+	if output == nil || string(output.SpentBy) != string(spend.TransactionID) {
+		t.Fatal("output is not marked as spent")
+	}
+	unspent := utxos.Unspent(nil)
+	if len(unspent) != 2 {
+		t.Fatalf("2 unspent outputs are expected but %d", len(unspent))
+	}
+
+	t.Run("double spend", func(t *testing.T) {
+		double := makeUTXOTestTransaction(t, ag, 1, genesis)
+		err := utxos.Apply(double)
+		if e, ok := err.(*UTXOError); !ok || e.Reason != UTXOAlreadySpent || e.Path != "References[0]" {
+			t.Fatalf("UTXOAlreadySpent is expected (%v)", err)
+		}
+		if utxos.Check(double) == nil {
+			t.Fatal("Check must report the double spend")
+		}
+	})
+
+	t.Run("unknown output", func(t *testing.T) {
+		other := makeUTXOTestTransaction(t, ag, 1)
+		err := utxos.Apply(makeUTXOTestTransaction(t, ag, 1, other))
+		if e, ok := err.(*UTXOError); !ok || e.Reason != UTXOUnknown {
+			t.Fatalf("UTXOUnknown is expected (%v)", err)
+		}
+
+		wrongGroup := makeUTXOTestTransaction(t, ag, 1, spend)
+		wrongGroup.References[0].AssetGroupID = GetIdentifier("another group", defaultIDLength)
+		err = utxos.Check(wrongGroup)
+		if e, ok := err.(*UTXOError); !ok || e.Reason != UTXOUnknown {
+			t.Fatalf("UTXOUnknown is expected for another asset group (%v)", err)
+		}
+	})
+
+	t.Run("spent twice in a transaction", func(t *testing.T) {
+		twice := makeUTXOTestTransaction(t, ag, 1, spend, spend)
+		before := len(utxos.Unspent(nil))
+		err := utxos.Apply(twice)
+		if e, ok := err.(*UTXOError); !ok || e.Reason != UTXOSpentTwiceInTransaction {
+			t.Fatalf("UTXOSpentTwiceInTransaction is expected (%v)", err)
+		}
+		if len(utxos.Unspent(nil)) != before || !utxos.IsUnspent(spend.TransactionID, 0, ag) {
+			t.Fatal("UTXOSet must not be changed by a rejected transaction")
+		}
+	})
+
+	t.Run("apply twice", func(t *testing.T) {
+		if err := utxos.Apply(genesis); err == nil {
+			t.Fatal("the same transaction must not be applied twice")
+		}
+	})
+
+	t.Run("transaction is not modified", func(t *testing.T) {
+		txobj := makeUTXOTestTransaction(t, ag, 1)
+		txobj.TransactionID = nil
+		if utxos.Check(txobj) == nil || txobj.TransactionID != nil {
+			t.Fatal("Check must fail without TransactionID and must not calculate it")
+		}
+		txobj.Digest()
+		if err := utxos.Apply(txobj); err != nil {
+			t.Fatalf("failed to apply (%v)", err)
+		}
+		txobj.Events[0].AssetGroupID = GetIdentifier("another group", defaultIDLength)
+		if output := utxos.Get(txobj.TransactionID, 0, ag); output == nil || !bytes.Equal(output.Event.AssetGroupID, ag) {
+			t.Fatal("the event in UTXOSet must not be changed by the caller")
+		}
+	})
+}
+
+func TestUTXOSetRollback(t *testing.T) {
+	ag := GetIdentifier("asset_group_id_utxo", defaultIDLength)
+	utxos := NewUTXOSet()
+	genesis := makeUTXOTestTransaction(t, ag, 1)
+	utxos.Apply(genesis)
+
+	snapshot := utxos.Snapshot()
+	candidate1 := makeUTXOTestTransaction(t, ag, 2, genesis)
+	if err := utxos.Apply(candidate1); err != nil {
+		t.Fatalf("failed to apply (%v)", err)
+	}
+	candidate2 := makeUTXOTestTransaction(t, ag, 1, candidate1)
+	if err := utxos.Apply(candidate2); err != nil {
+		t.Fatalf("failed to apply (%v)", err)
+	}
+	if len(utxos.Unspent(ag)) != 2 {
+		t.Fatal("unexpected number of unspent outputs")
+	}
+
+	if err := utxos.Rollback(snapshot); err != nil {
+		t.Fatalf("failed to rollback (%v)", err)
+	}
+	unspent := utxos.Unspent(ag)
+	if len(unspent) != 1 || string(unspent[0].TransactionID) != string(genesis.TransactionID) {
+		t.Fatal("UTXOSet is not rolled back")
+	}
+	if utxos.Get(candidate1.TransactionID, 0, ag) != nil {
+		t.Fatal("outputs of the candidate remain")
+	}
+
+	// another candidate spending the same output is acceptable after the rollback
+	if err := utxos.Apply(makeUTXOTestTransaction(t, ag, 1, genesis)); err != nil {
+		t.Fatalf("failed to apply (%v)", err)
+	}
+	if err := utxos.Apply(candidate1); err == nil {
+		t.Fatal("candidate1 must be rejected (double spend)")
+	}
+	if err := utxos.Rollback(UTXOSnapshot(100)); err == nil {
+		t.Fatal("invalid snapshot must be rejected")
+	}
+}
+
+func TestUTXOSetCommit(t *testing.T) {
+	ag := GetIdentifier("asset_group_id_utxo", defaultIDLength)
+	utxos := NewUTXOSet()
+	genesis := makeUTXOTestTransaction(t, ag, 1)
+	utxos.Apply(genesis)
+	if len(utxos.journal) != 0 {
+		t.Fatal("no change must be recorded without a snapshot")
+	}
+
+	snapshot1 := utxos.Snapshot()
+	candidate1 := makeUTXOTestTransaction(t, ag, 2, genesis)
+	utxos.Apply(candidate1)
+	snapshot2 := utxos.Snapshot()
+	candidate2 := makeUTXOTestTransaction(t, ag, 1, candidate1)
+	utxos.Apply(candidate2)
+	size := len(utxos.journal)
+
+	// the changes before snapshot2 are dropped from the journal
+	if err := utxos.Commit(snapshot1); err != nil {
+		t.Fatalf("failed to commit (%v)", err)
+	}
+	if len(utxos.journal) >= size || len(utxos.journal) == 0 {
+		t.Fatalf("the journal must shrink but keep the changes after snapshot2 (%d -> %d)", size, len(utxos.journal))
+	}
+	if err := utxos.Rollback(snapshot1); err == nil {
+		t.Fatal("committed snapshot must be rejected")
+	}
+	if err := utxos.Rollback(snapshot2); err != nil {
+		t.Fatalf("failed to rollback (%v)", err)
+	}
+	if utxos.Get(candidate2.TransactionID, 0, ag) != nil || utxos.Get(candidate1.TransactionID, 0, ag) == nil {
+		t.Fatal("UTXOSet is not rolled back to snapshot2")
+	}
+	if len(utxos.journal) != 0 {
+		t.Fatal("the journal must be empty when no snapshot is held")
+	}
+
+	// repeated evaluation of candidates does not grow the journal
+	last := candidate1
+	for i := 0; i < 10; i++ {
+		snapshot := utxos.Snapshot()
+		candidate := makeUTXOTestTransaction(t, ag, 1, last)
+		if err := utxos.Apply(candidate); err != nil {
+			t.Fatalf("failed to apply (%v)", err)
+		}
+		if i%2 == 0 {
+			utxos.Rollback(snapshot)
+		} else {
+			utxos.Commit(snapshot)
+			last = candidate
+		}
+	}
+	if len(utxos.journal) != 0 {
+		t.Fatalf("the journal must be empty but %d", len(utxos.journal))
+	}
+}
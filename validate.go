@@ -0,0 +1,286 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+ValidationError definition
+
+ValidationError describes a structural problem of a BBcTransaction object found by Validate.
+"Path" points to the problematic field like "Events[2].OptionApprovers" or "References[0].SigIndices[1]".
+
+ValidationErrors is the list of ValidationError objects, which is returned by Validate as an error.
+*/
+type (
+	ValidationError struct {
+		Path   string
+		Reason string
+	}
+
+	// ValidationErrors is the list of all problems found in a BBcTransaction object
+	ValidationErrors []*ValidationError
+)
+
+// Limits of the number of elements and the size of data in the packed transaction
+const (
+	maxCount                 = 0xffff
+	maxExtendedAssetBodySize = uint64(0xffffffff)
+)
+
+// Error returns the path and the reason of the problem
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Reason
+}
+
+// Error returns all problems in one string
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].Error()
+	}
+	return fmt.Sprintf("%d problem(s) in the transaction: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// validator collects ValidationError objects while walking a BBcTransaction object
+type validator struct {
+	idLength         int
+	numSigs          int
+	maxAssetBodySize uint64
+	maxAssetFileSize uint64
+	errs             ValidationErrors
+}
+
+func (v *validator) add(path string, format string, args ...interface{}) {
+	v.errs = append(v.errs, &ValidationError{Path: path, Reason: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) checkIDLength(path string, idLength int) {
+	if idLength != v.idLength {
+		v.add(path+".IDLength", "%d differs from IDLength of the transaction (%d)", idLength, v.idLength)
+	}
+}
+
+func (v *validator) checkID(path string, id []byte, length int) {
+	if len(id) != length {
+		v.add(path, "length must be %d bytes but %d", length, len(id))
+	}
+}
+
+func (v *validator) checkCount(path string, count int) {
+	if count > maxCount {
+		v.add(path, "too many elements (%d > %d)", count, maxCount)
+	}
+}
+
+func (v *validator) checkIndex(path string, idx int, length int) {
+	if idx < 0 || idx >= length {
+		v.add(path, "index %d out of range [0, %d)", idx, length)
+	}
+}
+
+func (v *validator) checkAsset(path string, p *BBcAsset) {
+	if p == nil {
+		return
+	}
+	v.checkIDLength(path, p.IDLength)
+	if p.AssetID != nil {
+		v.checkID(path+".AssetID", p.AssetID, v.idLength)
+	}
+	v.checkID(path+".UserID", p.UserID, v.idLength)
+	if len(p.Nonce) > maxCount {
+		v.add(path+".Nonce", "too long (%d bytes)", len(p.Nonce))
+	}
+	if p.AssetFileSize > 0 {
+		v.checkID(path+".AssetFileDigest", p.AssetFileDigest, 32)
+	}
+	if p.AssetFileSize > v.maxAssetFileSize {
+		v.add(path+".AssetFileSize", "too large (%d > %d bytes)", p.AssetFileSize, v.maxAssetFileSize)
+	}
+	if uint64(len(p.AssetBody)) > v.maxAssetBodySize {
+		v.add(path+".AssetBody", "too large (%d > %d bytes)", len(p.AssetBody), v.maxAssetBodySize)
+	} else if int(p.AssetBodySize) != len(p.AssetBody) {
+		v.add(path+".AssetBodySize", "%d differs from the length of AssetBody (%d)", p.AssetBodySize, len(p.AssetBody))
+	}
+}
+
+func (v *validator) checkEvent(path string, p *BBcEvent, numRefs int) {
+	v.checkIDLength(path, p.IDLength)
+	v.checkID(path+".AssetGroupID", p.AssetGroupID, v.idLength)
+	v.checkCount(path+".ReferenceIndices", len(p.ReferenceIndices))
+	for i, idx := range p.ReferenceIndices {
+		v.checkIndex(fmt.Sprintf("%s.ReferenceIndices[%d]", path, i), idx, numRefs)
+	}
+	v.checkCount(path+".MandatoryApprovers", len(p.MandatoryApprovers))
+	for i := range p.MandatoryApprovers {
+		v.checkID(fmt.Sprintf("%s.MandatoryApprovers[%d]", path, i), p.MandatoryApprovers[i], v.idLength)
+	}
+	if len(p.OptionApprovers) != int(p.OptionApproverNumDenominator) {
+		v.add(path+".OptionApprovers", "%d approvers but OptionApproverNumDenominator is %d", len(p.OptionApprovers), p.OptionApproverNumDenominator)
+	}
+	if p.OptionApproverNumNumerator > p.OptionApproverNumDenominator {
+		v.add(path+".OptionApproverNumNumerator", "%d exceeds OptionApproverNumDenominator (%d)", p.OptionApproverNumNumerator, p.OptionApproverNumDenominator)
+	}
+	v.checkCount(path+".OptionApprovers", len(p.OptionApprovers))
+	for i := range p.OptionApprovers {
+		v.checkID(fmt.Sprintf("%s.OptionApprovers[%d]", path, i), p.OptionApprovers[i], v.idLength)
+	}
+	v.checkAsset(path+".Asset", p.Asset)
+}
+
+func (v *validator) checkReference(path string, p *BBcReference) {
+	v.checkIDLength(path, p.IDLength)
+	v.checkID(path+".AssetGroupID", p.AssetGroupID, v.idLength)
+	v.checkID(path+".TransactionID", p.TransactionID, v.idLength)
+	if p.RefTransaction != nil {
+		v.checkIndex(path+".EventIndexInRef", int(p.EventIndexInRef), len(p.RefTransaction.Events))
+	}
+	v.checkCount(path+".SigIndices", len(p.SigIndices))
+	for i, idx := range p.SigIndices {
+		v.checkIndex(fmt.Sprintf("%s.SigIndices[%d]", path, i), idx, v.numSigs)
+	}
+}
+
+func (v *validator) checkRelation(path string, p *BBcRelation) {
+	v.checkIDLength(path, p.IDLength)
+	v.checkID(path+".AssetGroupID", p.AssetGroupID, v.idLength)
+	v.checkCount(path+".Pointers", len(p.Pointers))
+	for i, ptr := range p.Pointers {
+		ptrPath := fmt.Sprintf("%s.Pointers[%d]", path, i)
+		if ptr == nil {
+			v.add(ptrPath, "nil")
+			continue
+		}
+		v.checkIDLength(ptrPath, ptr.IDLength)
+		v.checkID(ptrPath+".TransactionID", ptr.TransactionID, v.idLength)
+		if ptr.AssetID != nil {
+			v.checkID(ptrPath+".AssetID", ptr.AssetID, v.idLength)
+		}
+	}
+	v.checkAsset(path+".Asset", p.Asset)
+}
+
+func (v *validator) checkWitness(path string, p *BBcWitness) {
+	v.checkIDLength(path, p.IDLength)
+	if len(p.UserIDs) != len(p.SigIndices) {
+		v.add(path+".SigIndices", "%d indices for %d UserIDs", len(p.SigIndices), len(p.UserIDs))
+	}
+	v.checkCount(path+".UserIDs", len(p.UserIDs))
+	for i := range p.UserIDs {
+		v.checkID(fmt.Sprintf("%s.UserIDs[%d]", path, i), p.UserIDs[i], v.idLength)
+	}
+	for i, idx := range p.SigIndices {
+		v.checkIndex(fmt.Sprintf("%s.SigIndices[%d]", path, i), idx, v.numSigs)
+	}
+}
+
+func (v *validator) checkCrossRef(path string, p *BBcCrossRef) {
+	v.checkID(path+".DomainID", p.DomainID, DomainIDLength)
+	v.checkID(path+".TransactionID", p.TransactionID, 32)
+}
+
+func (v *validator) checkSignature(path string, p *BBcSignature) {
+	if p.KeyType == KeyTypeNotInitialized {
+		return
+	}
+	if len(p.Pubkey) == 0 {
+		v.add(path+".Pubkey", "empty")
+	} else if int(p.PubkeyLen) != len(p.Pubkey)*8 {
+		v.add(path+".PubkeyLen", "%d bits differs from the length of Pubkey (%d bytes)", p.PubkeyLen, len(p.Pubkey))
+	}
+	if len(p.Signature) == 0 {
+		v.add(path+".Signature", "empty")
+	} else if int(p.SignatureLen) != len(p.Signature)*8 {
+		v.add(path+".SignatureLen", "%d bits differs from the length of Signature (%d bytes)", p.SignatureLen, len(p.Signature))
+	}
+}
+
+// Validate walks all objects in the transaction and checks the structural consistency before packing
+//
+// The ID lengths, the index bounds, the number of elements and the asset body size are checked.
+// It returns nil if no problem is found, otherwise ValidationErrors which lists all problems.
+func (p *BBcTransaction) Validate() error {
+	v := validator{idLength: p.IDLength, numSigs: len(p.Signatures), maxAssetBodySize: maxLegacyAssetBodySize, maxAssetFileSize: maxLegacyAssetFileSize}
+	if p.Version >= TransactionVersion2 {
+		v.maxAssetBodySize = maxExtendedAssetBodySize
+	}
+	if p.assetForm() == assetFormLargeFile {
+		v.maxAssetFileSize = maxLargeAssetFileSize
+	}
+	if p.IDLength < 1 || p.IDLength > 32 {
+		v.add("IDLength", "must be between 1 and 32 but %d", p.IDLength)
+	}
+	if err := p.checkVersion(); err != nil {
+		v.add("Version", "%s", err.Error())
+	}
+	v.checkCount("Extensions", len(p.Extensions))
+
+	v.checkCount("Events", len(p.Events))
+	for i, obj := range p.Events {
+		path := fmt.Sprintf("Events[%d]", i)
+		if obj == nil {
+			v.add(path, "nil")
+			continue
+		}
+		v.checkEvent(path, obj, len(p.References))
+	}
+
+	v.checkCount("References", len(p.References))
+	for i, obj := range p.References {
+		path := fmt.Sprintf("References[%d]", i)
+		if obj == nil {
+			v.add(path, "nil")
+			continue
+		}
+		v.checkReference(path, obj)
+	}
+
+	v.checkCount("Relations", len(p.Relations))
+	for i, obj := range p.Relations {
+		path := fmt.Sprintf("Relations[%d]", i)
+		if obj == nil {
+			v.add(path, "nil")
+			continue
+		}
+		v.checkRelation(path, obj)
+	}
+
+	if p.Witness != nil {
+		v.checkWitness("Witness", p.Witness)
+	}
+	if p.Crossref != nil {
+		v.checkCrossRef("Crossref", p.Crossref)
+	}
+
+	v.checkCount("Signatures", len(p.Signatures))
+	for i, obj := range p.Signatures {
+		path := fmt.Sprintf("Signatures[%d]", i)
+		if obj == nil {
+			v.add(path, "nil")
+			continue
+		}
+		v.checkSignature(path, obj)
+	}
+
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTransactionValidate(t *testing.T) {
+	assetGroupID := GetIdentifier("asset_group_id_validate", defaultIDLength)
+	u1 := GetIdentifier("user1_789abcdef0123456789abcdef0", defaultIDLength)
+	u2 := GetIdentifier("user2_789abcdef0123456789abcdef0", defaultIDLength)
+	keypair1 := GenerateKeypair(KeyTypeEcdsaP256v1, defaultCompressionMode)
+
+	makeTransactions := func() (*BBcTransaction, *BBcTransaction) {
+		txobj := MakeTransaction(1, 0, false, defaultIDLength)
+		AddEventAssetBodyString(txobj, 0, &assetGroupID, &u1, "teststring!!!!!")
+		txobj.Events[0].AddMandatoryApprover(&u1)
+		SignToTransaction(txobj, &u1, &keypair1)
+
+		txobj2 := MakeTransaction(1, 1, true, defaultIDLength)
+		AddEventAssetBodyString(txobj2, 0, &assetGroupID, &u2, "teststring2")
+		AddReference(txobj2, &assetGroupID, txobj, 0)
+		txobj2.References[0].AddApprover(&u1)
+		AddRelationAssetBodyString(txobj2, 0, &assetGroupID, &u2, "relation")
+		AddRelationPointer(txobj2, 0, &txobj.TransactionID, nil)
+		txobj2.Witness.AddWitness(&u2)
+		crs := BBcCrossRef{}
+		txobj2.AddCrossRef(&crs)
+		dom := GetIdentifier("dummy domain", DomainIDLength)
+		dummyTxid := GetIdentifier("dummytxid", defaultIDLength)
+		crs.Add(&dom, &dummyTxid)
+		SignToTransaction(txobj2, &u1, &keypair1)
+		SignToTransaction(txobj2, &u2, &keypair1)
+		return txobj, txobj2
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		txobj, txobj2 := makeTransactions()
+		if err := txobj.Validate(); err != nil {
+			t.Fatalf("must be valid (%v)", err)
+		}
+		if err := txobj2.Validate(); err != nil {
+			t.Fatalf("must be valid (%v)", err)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, txobj2 := makeTransactions()
+		txobj2.Events[0].AddOptionApprover(&u1)
+		txobj2.Events[0].AddReferenceIndex(3)
+		txobj2.Events[0].Asset.AssetBody = bytes.Repeat([]byte("a"), 0x10000)
+		txobj2.References[0].SigIndices = append(txobj2.References[0].SigIndices, 5)
+		txobj2.References[0].AssetGroupID = txobj2.References[0].AssetGroupID[:8]
+		txobj2.Relations[0].Pointers[0].IDLength = 8
+		txobj2.Witness.UserIDs = append(txobj2.Witness.UserIDs, u1)
+		txobj2.Crossref.TransactionID = txobj2.Crossref.TransactionID[:16]
+		txobj2.Signatures[1].PubkeyLen = 8
+
+		err := txobj2.Validate()
+		errs, ok := err.(ValidationErrors)
+		if !ok {
+			t.Fatalf("ValidationErrors must be returned (%v)", err)
+		}
+		t.Logf("%v", err)
+		expected := []string{
+			"Events[0].ReferenceIndices[0]",
+			"Events[0].OptionApprovers",
+			"Events[0].Asset.AssetBody",
+			"References[0].AssetGroupID",
+			"References[0].SigIndices[1]",
+			"Relations[0].Pointers[0].IDLength",
+			"Witness.SigIndices",
+			"Crossref.TransactionID",
+			"Signatures[1].PubkeyLen",
+		}
+		if len(errs) != len(expected) {
+			t.Fatalf("unexpected number of problems: %d", len(errs))
+		}
+		for i := range expected {
+			if errs[i].Path != expected[i] {
+				t.Fatalf("unexpected path: %s (expected %s)", errs[i].Path, expected[i])
+			}
+		}
+	})
+
+	t.Run("out of range event index", func(t *testing.T) {
+		txobj, txobj2 := makeTransactions()
+		ref := BBcReference{}
+		txobj2.AddReference(&ref)
+		ref.Add(&assetGroupID, txobj, 3)
+		err := txobj2.Validate()
+		if errs, ok := err.(ValidationErrors); !ok || len(errs) != 1 || errs[0].Path != "References[1].EventIndexInRef" {
+			t.Fatalf("EventIndexInRef must be reported (%v)", err)
+		}
+	})
+}
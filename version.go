@@ -0,0 +1,276 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+/*
+Transaction header versions
+
+The layout of the packed BBcTransaction is selected by Version in the header.
+
+Version 0 is the legacy layout of BBc-1 before 1.2. The header has no IDLength field and all IDs are 32 bytes long.
+
+	version(4) | timestamp(8) | events ...
+
+Version 1 is the layout of BBc-1 1.2, in which the length of IDs is given in the header.
+
+	version(4) | timestamp(8) | id_length(2) | events ...
+
+Version 2 adds an extension area to the header of version 1 so that newer formats can carry additional information without changing the version again.
+
+	version(4) | timestamp(8) | id_length(2) | extension_num(2) | [type(2) | size(4) | data]... | events ...
+
+An extension whose Type is not known is kept in Extensions as it is, so the packed data and the TransactionID are reproduced when the transaction is packed again.
+Extensions are included in the digest calculation (TransactionBaseDigest).
+In version 2 or later, BBcAsset objects are packed in the extended form, in which the asset body size is 4 bytes (see asset.go).
+
+The extension types below are defined by this library (the other types are for applications):
+  - ExtensionLargeAssetFile (no data): the asset file size of every BBcAsset object is packed in 8 bytes instead of 4 bytes (the large file form),
+    which is required for an asset file of 4GiB or larger
+
+ReadableVersions and WritableVersions report the supported versions, and NegotiateVersion picks the version to be used with a peer.
+*/
+type (
+	BBcExtension struct {
+		Type uint16
+		Data []byte
+	}
+)
+
+const (
+	TransactionVersionLegacy  = uint32(0)
+	TransactionVersion1       = uint32(1)
+	TransactionVersion2       = uint32(2)
+	DefaultTransactionVersion = TransactionVersion1
+
+	legacyIDLength = 32
+)
+
+// Extension types defined by this library
+const (
+	ExtensionLargeAssetFile = uint16(0x0100)
+)
+
+// ReadableVersions returns the transaction header versions that Unpack can read
+func ReadableVersions() []uint32 {
+	return []uint32{TransactionVersionLegacy, TransactionVersion1, TransactionVersion2}
+}
+
+// WritableVersions returns the transaction header versions that Pack can write
+func WritableVersions() []uint32 {
+	return []uint32{TransactionVersionLegacy, TransactionVersion1, TransactionVersion2}
+}
+
+// CanReadVersion returns true if Unpack supports the version
+func CanReadVersion(version uint32) bool {
+	return containsVersion(ReadableVersions(), version)
+}
+
+// CanWriteVersion returns true if Pack supports the version
+func CanWriteVersion(version uint32) bool {
+	return containsVersion(WritableVersions(), version)
+}
+
+// NegotiateVersion returns the highest version that this library can write and the peer can read
+func NegotiateVersion(peerReadable []uint32) (uint32, error) {
+	found := false
+	var ret uint32
+	for _, v := range WritableVersions() {
+		if containsVersion(peerReadable, v) && (!found || v > ret) {
+			ret = v
+			found = true
+		}
+	}
+	if !found {
+		return 0, fmt.Errorf("no common transaction version with the peer (peer can read %v)", peerReadable)
+	}
+	return ret, nil
+}
+
+func containsVersion(versions []uint32, version uint32) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// checkVersion checks that the header fields are consistent with Version for packing
+func (p *BBcTransaction) checkVersion() error {
+	if !CanWriteVersion(p.Version) {
+		return fmt.Errorf("not support version=%d transaction", p.Version)
+	}
+	if p.Version == TransactionVersionLegacy && p.IDLength != legacyIDLength {
+		return fmt.Errorf("IDLength must be %d in version=0 transaction but %d", legacyIDLength, p.IDLength)
+	}
+	if p.Version < TransactionVersion2 && len(p.Extensions) > 0 {
+		return fmt.Errorf("extensions are not supported in version=%d transaction", p.Version)
+	}
+	return nil
+}
+
+// packHeader packs the header part of BBcTransaction object in binary data according to Version
+func (p *BBcTransaction) packHeader(buf *bytes.Buffer) error {
+	if err := p.checkVersion(); err != nil {
+		return err
+	}
+	Put4byte(buf, p.Version)
+	Put8byte(buf, p.Timestamp)
+	if p.Version == TransactionVersionLegacy {
+		return nil
+	}
+	Put2byte(buf, uint16(p.IDLength))
+	if p.Version == TransactionVersion1 {
+		return nil
+	}
+
+	if len(p.Extensions) > 0xffff {
+		return fmt.Errorf("too many extensions (%d)", len(p.Extensions))
+	}
+	Put2byte(buf, uint16(len(p.Extensions)))
+	for _, ext := range p.Extensions {
+		Put2byte(buf, ext.Type)
+		Put4byte(buf, uint32(len(ext.Data)))
+		if err := binary.Write(buf, binary.LittleEndian, ext.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unpackHeader unpacks the header part of the binary data according to the version in it
+func (p *BBcTransaction) unpackHeader(buf *bytes.Buffer, limits *DecodeLimits) error {
+	var err error
+	p.Version, err = Get4byte(buf)
+	if err != nil {
+		return err
+	}
+	if !CanReadVersion(p.Version) {
+		return fmt.Errorf("not support version=%d transaction", p.Version)
+	}
+
+	p.Timestamp, err = Get8byte(buf)
+	if err != nil {
+		return err
+	}
+
+	if p.Version == TransactionVersionLegacy {
+		p.IDLength = legacyIDLength
+		return nil
+	}
+	idLen, err := Get2byte(buf)
+	if err != nil {
+		return err
+	}
+	p.IDLength = int(idLen)
+	if p.Version == TransactionVersion1 {
+		return nil
+	}
+
+	num, err := Get2byte(buf)
+	if err != nil {
+		return err
+	}
+	if err = limits.checkCount("Extensions", int(num)); err != nil {
+		return err
+	}
+	p.Extensions = nil
+	for i := 0; i < int(num); i++ {
+		typ, err := Get2byte(buf)
+		if err != nil {
+			return err
+		}
+		size, err := Get4byte(buf)
+		if err != nil {
+			return err
+		}
+		data, err := GetBytes(buf, int(size))
+		if err != nil {
+			return err
+		}
+		p.Extensions = append(p.Extensions, BBcExtension{Type: typ, Data: data})
+	}
+	return nil
+}
+
+// assetForm returns the form of the BBcAsset objects in the transaction according to Version and Extensions
+func (p *BBcTransaction) assetForm() int {
+	switch {
+	case p.Version < TransactionVersion2:
+		return assetFormLegacy
+	case p.hasExtension(ExtensionLargeAssetFile):
+		return assetFormLargeFile
+	}
+	return assetFormExtended
+}
+
+// setAssetForm selects the form of the BBcAsset objects in the transaction according to Version and Extensions
+func (p *BBcTransaction) setAssetForm() error {
+	form := p.assetForm()
+	for i, obj := range p.Events {
+		if obj != nil {
+			obj.assetForm = form
+			if obj.Asset != nil {
+				if err := obj.Asset.setForm(form); err != nil {
+					return fmt.Errorf("Events[%d].Asset: %v", i, err)
+				}
+			}
+		}
+	}
+	for i, obj := range p.Relations {
+		if obj != nil {
+			obj.assetForm = form
+			if obj.Asset != nil {
+				if err := obj.Asset.setForm(form); err != nil {
+					return fmt.Errorf("Relations[%d].Asset: %v", i, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// AddExtension appends an extension to the header (Version must be 2 or later when packing)
+func (p *BBcTransaction) AddExtension(extType uint16, data []byte) {
+	p.Extensions = append(p.Extensions, BBcExtension{Type: extType, Data: data})
+}
+
+// hasExtension returns true if the header has an extension with the type (the data may be empty)
+func (p *BBcTransaction) hasExtension(extType uint16) bool {
+	for _, ext := range p.Extensions {
+		if ext.Type == extType {
+			return true
+		}
+	}
+	return false
+}
+
+// GetExtension returns the data of the first extension with the type, or nil if not found
+func (p *BBcTransaction) GetExtension(extType uint16) []byte {
+	for _, ext := range p.Extensions {
+		if ext.Type == extType {
+			return ext.Data
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+/*
+Copyright (c) 2018 Zettant Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bbclib
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func makeVersionTestTransaction(t *testing.T, version uint32) *BBcTransaction {
+	return makeTestTransaction(t, func(b *TransactionBuilder) {
+		b.Version(version).Event(testAssetGroupID).Asset(testUsers[0]).BodyString("version test").Approver(testUsers[0]).Witness(testUsers[0])
+	})
+}
+
+func TestTransactionVersions(t *testing.T) {
+	for _, version := range WritableVersions() {
+		txobj := makeVersionTestTransaction(t, version)
+		if version >= TransactionVersion2 {
+			txobj.AddExtension(1, []byte("extension1"))
+			txobj.AddExtension(0x1234, []byte{})
+			txobj.Digest()
+		}
+		if err := SignToTransaction(txobj, &testUsers[0], &testKeypairs[0]); err != nil {
+			t.Fatalf("version=%d: failed to sign (%v)", version, err)
+		}
+
+		dat, err := Serialize(txobj, FormatZlib)
+		if err != nil {
+			t.Fatalf("version=%d: failed to serialize (%v)", version, err)
+		}
+		obj, err := Deserialize(dat)
+		if err != nil {
+			t.Fatalf("version=%d: failed to deserialize (%v)", version, err)
+		}
+		if obj.Version != version || obj.IDLength != defaultIDLength {
+			t.Fatalf("version=%d: header mismatch (version=%d, id_length=%d)", version, obj.Version, obj.IDLength)
+		}
+		if !bytes.Equal(obj.TransactionID, txobj.TransactionID) {
+			t.Fatalf("version=%d: TransactionID mismatch", version)
+		}
+		if len(obj.Extensions) != len(txobj.Extensions) {
+			t.Fatalf("version=%d: %d extensions are unpacked but %d", version, len(obj.Extensions), len(txobj.Extensions))
+		}
+		if ret, _ := obj.VerifyAll(); !ret {
+			t.Fatalf("version=%d: failed to verify", version)
+		}
+		if err := obj.Validate(); err != nil {
+			t.Fatalf("version=%d: %v", version, err)
+		}
+		t.Log(obj.Stringer())
+	}
+}
+
+func TestTransactionVersionLayout(t *testing.T) {
+	packed := make(map[uint32][]byte)
+	for _, version := range WritableVersions() {
+		txobj := makeVersionTestTransaction(t, version)
+		txobj.Timestamp = 1543757089
+		txobj.Events[0].Asset.Nonce = []byte("fixed nonce")
+		txobj.Events[0].Asset.AssetID = nil
+		dat, err := txobj.Pack()
+		if err != nil {
+			t.Fatalf("version=%d: failed to pack (%v)", version, err)
+		}
+		packed[version] = dat
+	}
+
+	t.Run("version 0 has no id_length", func(t *testing.T) {
+		v0, v1 := packed[TransactionVersionLegacy], packed[TransactionVersion1]
+		if len(v1)-len(v0) != 2 {
+			t.Fatalf("unexpected length difference (v0=%d, v1=%d)", len(v0), len(v1))
+		}
+		if !bytes.Equal(v0[4:12], v1[4:12]) || !bytes.Equal(v0[12:], v1[14:]) {
+			t.Fatal("version 0 layout differs from version 1 except for id_length")
+		}
+	})
+
+	t.Run("version 2 has the extension area and the extended asset", func(t *testing.T) {
+		v1, v2 := packed[TransactionVersion1], packed[TransactionVersion2]
+		if !bytes.Equal(v2[4:14], v1[4:14]) || !bytes.Equal(v2[14:16], []byte{0, 0}) {
+			t.Fatal("version 2 header should differ from version 1 only by extension_num")
+		}
+		// extension_num (2 bytes) and the asset body size in 4 bytes (2 more bytes than version 1)
+		if len(v2)-len(v1) != 4 {
+			t.Fatalf("unexpected length difference (v1=%d, v2=%d)", len(v1), len(v2))
+		}
+	})
+
+	t.Run("unknown extensions are preserved", func(t *testing.T) {
+		txobj := makeVersionTestTransaction(t, TransactionVersion2)
+		txobj.AddExtension(0xfffe, []byte("from the future"))
+		txid := txobj.Digest()
+		dat, _ := txobj.Pack()
+
+		obj := BBcTransaction{}
+		if err := obj.Unpack(&dat); err != nil {
+			t.Fatalf("failed to unpack (%v)", err)
+		}
+		if !bytes.Equal(obj.GetExtension(0xfffe), []byte("from the future")) || obj.GetExtension(1) != nil {
+			t.Fatal("extension is not preserved")
+		}
+		dat2, _ := obj.Pack()
+		if !bytes.Equal(dat, dat2) || !bytes.Equal(obj.TransactionID, txid) {
+			t.Fatal("repacked data differs")
+		}
+
+		txobj.Extensions[0].Data = []byte("modified")
+		if bytes.Equal(txobj.Digest(), txid) {
+			t.Fatal("extension must be included in the digest")
+		}
+	})
+}
+
+func TestTransactionVersionErrors(t *testing.T) {
+	t.Run("version 0 with short IDs", func(t *testing.T) {
+		txobj := BBcTransaction{Version: TransactionVersionLegacy, IDLength: 16}
+		if _, err := txobj.Pack(); err == nil {
+			t.Fatal("version 0 transaction must have 32-byte IDs")
+		}
+		if err := txobj.Validate(); err == nil {
+			t.Fatal("Validate should report the version problem")
+		}
+	})
+
+	t.Run("extensions in version 1", func(t *testing.T) {
+		txobj := makeVersionTestTransaction(t, TransactionVersion1)
+		txobj.AddExtension(1, []byte("ext"))
+		if _, err := txobj.Pack(); err == nil {
+			t.Fatal("extensions must be rejected in version 1")
+		}
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		txobj := makeVersionTestTransaction(t, TransactionVersion1)
+		dat, _ := txobj.Pack()
+		txobj.Version = 99
+		if _, err := txobj.Pack(); err == nil {
+			t.Fatal("unknown version must be rejected in packing")
+		}
+
+		dat[0] = 99
+		obj := BBcTransaction{}
+		if err := obj.Unpack(&dat); err == nil {
+			t.Fatal("unknown version must be rejected in unpacking")
+		}
+	})
+
+	t.Run("truncated extension", func(t *testing.T) {
+		txobj := makeVersionTestTransaction(t, TransactionVersion2)
+		txobj.AddExtension(1, []byte("extension"))
+		dat, _ := txobj.Pack()
+		dat = dat[:20]
+		obj := BBcTransaction{}
+		if err := obj.Unpack(&dat); err == nil {
+			t.Fatal("truncated data must be rejected")
+		}
+	})
+}
+
+func TestNegotiateVersion(t *testing.T) {
+	for _, v := range ReadableVersions() {
+		if !CanReadVersion(v) {
+			t.Fatalf("version=%d should be readable", v)
+		}
+	}
+	if CanReadVersion(99) || CanWriteVersion(99) {
+		t.Fatal("version=99 should not be supported")
+	}
+
+	cases := []struct {
+		peer     []uint32
+		expected uint32
+	}{
+		{[]uint32{0, 1}, TransactionVersion1},
+		{[]uint32{1, 2, 3}, TransactionVersion2},
+		{[]uint32{0}, TransactionVersionLegacy},
+	}
+	for _, c := range cases {
+		v, err := NegotiateVersion(c.peer)
+		if err != nil || v != c.expected {
+			t.Fatalf("peer=%v: expected %d but %d (%v)", c.peer, c.expected, v, err)
+		}
+	}
+	if _, err := NegotiateVersion([]uint32{3, 4}); err == nil {
+		t.Fatal("negotiation should fail without a common version")
+	}
+}
+
+func TestTransactionVersionJSON(t *testing.T) {
+	txobj := makeVersionTestTransaction(t, TransactionVersion2)
+	txobj.AddExtension(7, []byte("json"))
+	txobj.Digest()
+	dat, err := json.Marshal(txobj)
+	if err != nil {
+		t.Fatalf("failed to marshal (%v)", err)
+	}
+	obj := BBcTransaction{}
+	if err := json.Unmarshal(dat, &obj); err != nil {
+		t.Fatalf("failed to unmarshal (%v)", err)
+	}
+	if !bytes.Equal(obj.GetExtension(7), []byte("json")) {
+		t.Fatal("extension is lost in the JSON round trip")
+	}
+}
@@ -43,6 +43,45 @@ type (
 	}
 )
 
+/*
+WitnessReport definition
+
+WitnessReport is the result of BBcTransaction.VerifyWitness. Each problem found in the BBcWitness object is listed in "Issues" as a WitnessIssue.
+"Index" of a WitnessIssue is the position of the entry in UserIDs (and SigIndices) of the BBcWitness object.
+
+The PubkeyResolver (see approval.go) for VerifyWitness is supplied by the caller (e.g., from a user directory).
+*/
+type (
+	WitnessReport struct {
+		Issues []WitnessIssue
+	}
+
+	// WitnessIssue describes a problem of an entry in the BBcWitness object
+	WitnessIssue struct {
+		Index    int
+		UserID   []byte
+		SigIndex int
+		Reason   int
+	}
+)
+
+// Reasons of WitnessIssue
+const (
+	WitnessIssueOutOfRange = iota + 1
+	WitnessIssueNotInitialized
+	WitnessIssueDuplicateUserID
+	WitnessIssueInvalidSignature
+	WitnessIssuePubkeyMismatch
+)
+
+var witnessIssueReasons = map[int]string{
+	WitnessIssueOutOfRange:       "sig_index out of range",
+	WitnessIssueNotInitialized:   "signature not initialized",
+	WitnessIssueDuplicateUserID:  "duplicate user_id",
+	WitnessIssueInvalidSignature: "invalid signature",
+	WitnessIssuePubkeyMismatch:   "public key does not belong to user_id",
+}
+
 // Stringer outputs the content of the object
 func (p *BBcWitness) Stringer() string {
 	ret := "Witness:\n"
@@ -99,6 +138,11 @@ func (p *BBcWitness) Pack() ([]byte, error) {
 
 // Unpack the BBcWitness object to the binary data
 func (p *BBcWitness) Unpack(dat *[]byte) error {
+	return p.unpack(dat, nil)
+}
+
+// unpack the binary data to the BBcWitness object within the limits (no limit if limits is nil)
+func (p *BBcWitness) unpack(dat *[]byte, limits *DecodeLimits) error {
 	var err error
 	buf := bytes.NewBuffer(*dat)
 
@@ -106,6 +150,9 @@ func (p *BBcWitness) Unpack(dat *[]byte) error {
 	if err != nil {
 		return err
 	}
+	if err = limits.checkCount("UserIDs", int(userNum)); err != nil {
+		return err
+	}
 	for i := 0; i < int(userNum); i++ {
 		userID, err2 := GetBigInt(buf)
 		if err2 != nil {
@@ -122,3 +169,68 @@ func (p *BBcWitness) Unpack(dat *[]byte) error {
 
 	return nil
 }
+
+// Valid returns true if no issue is found
+func (r *WitnessReport) Valid() bool {
+	return len(r.Issues) == 0
+}
+
+// Stringer outputs the content of the object
+func (r *WitnessReport) Stringer() string {
+	if r.Valid() {
+		return "Witness: valid\n"
+	}
+	ret := "Witness: invalid\n"
+	for _, issue := range r.Issues {
+		ret += fmt.Sprintf(" [%d] user_id: %x, sig_index: %d, %s\n", issue.Index, issue.UserID, issue.SigIndex, witnessIssueReasons[issue.Reason])
+	}
+	return ret
+}
+
+// VerifyWitness checks every entry of the BBcWitness object against the Signatures of the transaction
+//
+// An entry is reported if its sig_index is out of range, the signature is not initialized (not signed yet) or invalid,
+// or the userID appears more than once. If resolver is not nil, an entry is also reported when the public key in the signature is not one of the keys returned by resolver for the userID.
+func (p *BBcTransaction) VerifyWitness(resolver PubkeyResolver) *WitnessReport {
+	report := WitnessReport{}
+	if p.Witness == nil {
+		return &report
+	}
+	digest := p.Digest()
+
+	addIssue := func(i int, userID []byte, sigIndex int, reason int) {
+		report.Issues = append(report.Issues, WitnessIssue{Index: i, UserID: userID, SigIndex: sigIndex, Reason: reason})
+	}
+	for i, userID := range p.Witness.UserIDs {
+		for j := 0; j < i; j++ {
+			if bytes.Equal(p.Witness.UserIDs[j], userID) {
+				addIssue(i, userID, -1, WitnessIssueDuplicateUserID)
+				break
+			}
+		}
+
+		if i >= len(p.Witness.SigIndices) {
+			addIssue(i, userID, -1, WitnessIssueOutOfRange)
+			continue
+		}
+		idx := p.Witness.SigIndices[i]
+		if idx < 0 || idx >= len(p.Signatures) || p.Signatures[idx] == nil {
+			addIssue(i, userID, idx, WitnessIssueOutOfRange)
+			continue
+		}
+		sig := p.Signatures[idx]
+		if sig.KeyType == KeyTypeNotInitialized {
+			addIssue(i, userID, idx, WitnessIssueNotInitialized)
+			continue
+		}
+		if digest == nil || !VerifyBBcSignature(digest, sig) {
+			addIssue(i, userID, idx, WitnessIssueInvalidSignature)
+			continue
+		}
+
+		if resolver != nil && !resolver.owns(userID, sig) {
+			addIssue(i, userID, idx, WitnessIssuePubkeyMismatch)
+		}
+	}
+	return &report
+}
@@ -76,3 +76,74 @@ func TestWitnessInvalidAccess(t *testing.T) {
 		}
 	})
 }
+
+func TestWitnessVerify(t *testing.T) {
+	u1 := GetIdentifier("user1_789abcdef0123456789abcdef0", defaultIDLength)
+	u2 := GetIdentifier("user2_789abcdef0123456789abcdef0", defaultIDLength)
+	keypair1 := GenerateKeypair(KeyTypeEcdsaP256v1, defaultCompressionMode)
+	keypair2 := GenerateKeypair(KeyTypeEd25519, defaultCompressionMode)
+	pubkeys := map[string][]byte{string(u1): keypair1.Pubkey, string(u2): keypair2.Pubkey}
+	resolver := func(userID []byte) [][]byte {
+		return [][]byte{pubkeys[string(userID)]}
+	}
+
+	makeTransaction := func() *BBcTransaction {
+		txobj := MakeTransaction(0, 0, true, 32)
+		txobj.Witness.AddWitness(&u1)
+		txobj.Witness.AddWitness(&u2)
+		SignToTransaction(txobj, &u1, &keypair1)
+		SignToTransaction(txobj, &u2, &keypair2)
+		return txobj
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		txobj := makeTransaction()
+		if report := txobj.VerifyWitness(resolver); !report.Valid() {
+			t.Fatalf("witness must be valid\n%s", report.Stringer())
+		}
+		compressed, _ := ConvertPublicKey(KeyTypeEcdsaP256v1, keypair1.Pubkey, CompressionModeCompressed)
+		pubkeys[string(u1)] = compressed
+		defer func() { pubkeys[string(u1)] = keypair1.Pubkey }()
+		if report := txobj.VerifyWitness(resolver); !report.Valid() {
+			t.Fatalf("compression mode of public key must be ignored\n%s", report.Stringer())
+		}
+	})
+
+	t.Run("issues", func(t *testing.T) {
+		txobj := MakeTransaction(0, 0, true, 32)
+		txobj.Witness.AddWitness(&u1)
+		txobj.Witness.AddWitness(&u2)
+		txobj.Witness.UserIDs = append(txobj.Witness.UserIDs, u1, u2)
+		txobj.Witness.SigIndices = append(txobj.Witness.SigIndices, 0, 5)
+		SignToTransaction(txobj, &u1, &keypair1)
+
+		report := txobj.VerifyWitness(nil)
+		t.Logf("%s", report.Stringer())
+		expected := []WitnessIssue{
+			{Index: 1, UserID: u2, SigIndex: 1, Reason: WitnessIssueNotInitialized},
+			{Index: 2, UserID: u1, SigIndex: -1, Reason: WitnessIssueDuplicateUserID},
+			{Index: 3, UserID: u2, SigIndex: -1, Reason: WitnessIssueDuplicateUserID},
+			{Index: 3, UserID: u2, SigIndex: 5, Reason: WitnessIssueOutOfRange},
+		}
+		if len(report.Issues) != len(expected) {
+			t.Fatalf("unexpected number of issues: %d", len(report.Issues))
+		}
+		for i := range expected {
+			if report.Issues[i].Index != expected[i].Index || report.Issues[i].Reason != expected[i].Reason ||
+				report.Issues[i].SigIndex != expected[i].SigIndex || !bytes.Equal(report.Issues[i].UserID, expected[i].UserID) {
+				t.Fatalf("unexpected issue: %v", report.Issues[i])
+			}
+		}
+	})
+
+	t.Run("invalid signature and public key", func(t *testing.T) {
+		txobj := makeTransaction()
+		txobj.Signatures[0].Signature[3] ^= 0xff
+		txobj.Signatures[1], txobj.Signatures[0] = txobj.Signatures[0], txobj.Signatures[1]
+
+		report := txobj.VerifyWitness(resolver)
+		if len(report.Issues) != 2 || report.Issues[0].Reason != WitnessIssuePubkeyMismatch || report.Issues[1].Reason != WitnessIssueInvalidSignature {
+			t.Fatalf("unexpected issues\n%s", report.Stringer())
+		}
+	})
+}
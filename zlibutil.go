@@ -19,7 +19,6 @@ package bbclib
 import (
 	"bytes"
 	"compress/zlib"
-	"io"
 )
 
 // ZlibCompress compresses the given data using zlib
@@ -35,15 +34,15 @@ func ZlibCompress(dat *[]byte) []byte {
 
 // ZlibDecompress decompresses the given data using zlib
 func ZlibDecompress(dat []byte) ([]byte, error) {
-	var srcbuf bytes.Buffer
-	var dstbuf bytes.Buffer
-	srcbuf.Write(dat)
-	zlibreader, err := zlib.NewReader(&srcbuf)
+	return ZlibDecompressWithLimit(dat, 0)
+}
+
+// ZlibDecompressWithLimit decompresses the given data using zlib, and returns an error if the decompressed data exceeds maxSize bytes (0 means no limit)
+func ZlibDecompressWithLimit(dat []byte, maxSize int) ([]byte, error) {
+	zlibreader, err := zlib.NewReader(bytes.NewReader(dat))
 	if err != nil {
 		return nil, err
 	}
-	io.Copy(&dstbuf, zlibreader)
-	zlibreader.Close()
-
-	return dstbuf.Bytes(), nil
+	defer zlibreader.Close()
+	return readAllWithLimit(zlibreader, maxSize)
 }
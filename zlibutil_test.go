@@ -36,3 +36,19 @@ func TestCompressDecompress(t *testing.T) {
 	}
 	t.Log("Succeeded")
 }
+
+func TestDecompressWithLimit(t *testing.T) {
+	original := make([]byte, 1024*1024)
+	comp := ZlibCompress(&original)
+
+	if _, err := ZlibDecompressWithLimit(comp, len(original)); err != nil {
+		t.Fatalf("failed to decompress (%v)", err)
+	}
+	_, err := ZlibDecompressWithLimit(comp, 1024)
+	if _, ok := err.(*DecodeLimitError); !ok {
+		t.Fatalf("DecodeLimitError must be returned (%v)", err)
+	}
+	if _, err := ZlibDecompress(comp[:len(comp)/2]); err == nil {
+		t.Fatal("truncated data must not be decompressed")
+	}
+}